@@ -9,6 +9,8 @@ import (
 	"time"
 
 	"linkmaster-node/internal/config"
+	"linkmaster-node/internal/dnsprobe"
+	"linkmaster-node/internal/enrich"
 	"linkmaster-node/internal/heartbeat"
 	"linkmaster-node/internal/recovery"
 	"linkmaster-node/internal/server"
@@ -37,6 +39,16 @@ func main() {
 	// 初始化错误恢复
 	recovery.Init()
 
+	// 加载IP信息数据库
+	if err := enrich.Init(cfg); err != nil {
+		logger.Warn("加载IP信息数据库失败", zap.Error(err))
+	}
+
+	// 加载DNSSEC信任锚
+	if err := dnsprobe.SetTrustAnchors(cfg.DNS.TrustAnchors); err != nil {
+		logger.Warn("加载DNSSEC信任锚失败，使用内置的信任锚", zap.Error(err))
+	}
+
 	// 如果配置中没有节点信息，先发送一次心跳获取节点信息
 	if cfg.Node.ID == 0 || cfg.Node.IP == "" {
 		logger.Info("节点信息未配置，发送心跳获取节点信息")
@@ -30,6 +30,8 @@ SOURCE_DIR="/opt/linkmaster-node"  # 源码目录
 BINARY_NAME="linkmaster-node"
 INSTALL_DIR="/usr/local/bin"
 SERVICE_NAME="linkmaster-node"
+GO_MIN_VERSION="1.23"  # 编译所需的最低 Go 版本（与 go.mod 一致）
+GO_INSTALL_DIR="${SOURCE_DIR}/.go"  # 脚本自行下载的 Go 工具链目录，不覆盖系统中已有的 Go
 
 # 获取后端地址参数
 BACKEND_URL="${1:-}"
@@ -90,22 +92,51 @@ install_dependencies() {
     echo -e "${GREEN}✓ 依赖安装完成${NC}"
 }
 
+# 检查 go 命令的版本是否满足 GO_MIN_VERSION
+go_version_ok() {
+    local version
+    version=$(go env GOVERSION 2>/dev/null | sed 's/^go//')
+    [ -n "$version" ] && [ "$(printf '%s\n%s\n' "$GO_MIN_VERSION" "$version" | sort -V | head -1)" = "$GO_MIN_VERSION" ]
+}
+
 # 安装 Go 环境
+# 发行版仓库中的 golang 版本普遍低于 GO_MIN_VERSION，因此从官方下载工具链安装到 GO_INSTALL_DIR
+# GO_INSTALL_DIR 位于源码目录下，只供编译使用，卸载时随源码目录一起删除
 install_go() {
     echo -e "${BLUE}安装 Go 环境...${NC}"
     
-    if [ "$OS" = "ubuntu" ] || [ "$OS" = "debian" ]; then
-        sudo apt-get update -qq
-        sudo apt-get install -y -qq golang-go > /dev/null 2>&1
-    elif [ "$OS" = "centos" ] || [ "$OS" = "rhel" ] || [ "$OS" = "rocky" ] || [ "$OS" = "almalinux" ]; then
-        sudo yum install -y -q golang > /dev/null 2>&1
-    else
-        echo -e "${YELLOW}无法自动安装 Go，请手动安装: https://golang.org/dl/${NC}"
+    # 未指定 GO_INSTALL_VERSION 时使用官方最新的稳定版本
+    local version="${GO_INSTALL_VERSION:-}"
+    if [ -z "$version" ]; then
+        version=$(curl -fsSL "https://go.dev/VERSION?m=text" 2>/dev/null | head -1)
+    fi
+    if [ -z "$version" ]; then
+        echo -e "${RED}无法获取 Go 最新版本号，可通过 GO_INSTALL_VERSION 指定（如 go1.23.12）${NC}"
+        show_build_alternatives
+        exit 1
+    fi
+    case "$version" in
+        go*) ;;
+        *) version="go${version}" ;;
+    esac
+    
+    local tarball="${version}.linux-${ARCH}.tar.gz"
+    local tmp
+    tmp=$(mktemp -d)
+    echo -e "${BLUE}下载 ${tarball}...${NC}"
+    if ! curl -fsSL "https://go.dev/dl/${tarball}" -o "${tmp}/${tarball}"; then
+        rm -rf "$tmp"
+        echo -e "${RED}下载 Go 失败，请检查网络连接${NC}"
         show_build_alternatives
         exit 1
     fi
+    sudo rm -rf "$GO_INSTALL_DIR"
+    sudo mkdir -p "$GO_INSTALL_DIR"
+    sudo tar -C "$GO_INSTALL_DIR" --strip-components=1 -xzf "${tmp}/${tarball}"
+    rm -rf "$tmp"
+    export PATH="${GO_INSTALL_DIR}/bin:$PATH"
     
-    if command -v go > /dev/null 2>&1; then
+    if command -v go > /dev/null 2>&1 && go_version_ok; then
         GO_VERSION=$(go version 2>/dev/null | head -1)
         echo -e "${GREEN}✓ Go 安装完成: ${GO_VERSION}${NC}"
     else
@@ -125,7 +156,7 @@ show_build_alternatives() {
     echo -e "${GREEN}手动编译安装:${NC}"
     echo "  git clone https://github.com/${GITHUB_REPO}.git ${SOURCE_DIR}"
     echo "  cd ${SOURCE_DIR}"
-    echo "  go build -o agent ./cmd/agent"
+    echo "  go build -o agent ./cmd/agent  # 需要 Go ${GO_MIN_VERSION} 及以上: https://go.dev/dl/"
     echo "  sudo cp agent /usr/local/bin/linkmaster-node"
     echo "  sudo chmod +x /usr/local/bin/linkmaster-node"
     echo ""
@@ -207,22 +238,6 @@ uninstall_service() {
 build_from_source() {
     echo -e "${BLUE}从源码编译安装节点端...${NC}"
     
-    # 检查 Go 环境
-    if ! command -v go > /dev/null 2>&1; then
-        echo -e "${BLUE}未检测到 Go 环境，开始安装...${NC}"
-        install_go
-    fi
-    
-    # 检查 Go 版本
-    GO_VERSION=$(go version 2>/dev/null | head -1 || echo "")
-    if [ -z "$GO_VERSION" ]; then
-        echo -e "${RED}无法获取 Go 版本信息${NC}"
-        show_build_alternatives
-        exit 1
-    fi
-    
-    echo -e "${BLUE}检测到 Go 版本: ${GO_VERSION}${NC}"
-    
     # 如果源码目录已存在，删除（卸载函数应该已经删除，这里作为保险）
     if [ -d "$SOURCE_DIR" ]; then
         echo -e "${YELLOW}清理旧的源码目录...${NC}"
@@ -243,13 +258,36 @@ build_from_source() {
     
     cd "$SOURCE_DIR"
     
+    # 检查 Go 环境，系统中的 Go 不满足要求时在源码目录下安装官方工具链（需在克隆之后，克隆前会清空源码目录）
+    if [ -x "${GO_INSTALL_DIR}/bin/go" ]; then
+        export PATH="${GO_INSTALL_DIR}/bin:$PATH"
+    fi
+    if ! command -v go > /dev/null 2>&1; then
+        echo -e "${BLUE}未检测到 Go 环境，开始安装...${NC}"
+        install_go
+    elif ! go_version_ok; then
+        echo -e "${YELLOW}当前 Go 版本低于 ${GO_MIN_VERSION}，开始安装新版本...${NC}"
+        install_go
+    fi
+    GO_BIN=$(command -v go)
+    
+    # 检查 Go 版本
+    GO_VERSION=$(go version 2>/dev/null | head -1 || echo "")
+    if [ -z "$GO_VERSION" ]; then
+        echo -e "${RED}无法获取 Go 版本信息${NC}"
+        show_build_alternatives
+        exit 1
+    fi
+    
+    echo -e "${BLUE}检测到 Go 版本: ${GO_VERSION}${NC}"
+    
     # 配置 Git safe.directory，解决所有权问题
     sudo git config --global --add safe.directory "$SOURCE_DIR" 2>/dev/null || true
     git config --global --add safe.directory "$SOURCE_DIR" 2>/dev/null || true
     
     # 下载依赖（使用 sudo 以 root 用户执行）
     echo -e "${BLUE}下载 Go 依赖...${NC}"
-    if ! sudo bash -c "cd '$SOURCE_DIR' && go mod download" 2>&1; then
+    if ! sudo bash -c "cd '$SOURCE_DIR' && '$GO_BIN' mod download" 2>&1; then
         echo -e "${RED}下载依赖失败${NC}"
         show_build_alternatives
         exit 1
@@ -261,7 +299,7 @@ build_from_source() {
     BINARY_PATH="$SOURCE_DIR/agent"
     
     # 使用 sudo 以 root 用户编译，直接输出到目标位置
-    if sudo bash -c "cd '$SOURCE_DIR' && GOOS=linux GOARCH=${ARCH} CGO_ENABLED=0 go build -buildvcs=false -ldflags='-w -s' -o '$BINARY_PATH' ./cmd/agent" 2>&1; then
+    if sudo bash -c "cd '$SOURCE_DIR' && GOOS=linux GOARCH=${ARCH} CGO_ENABLED=0 '$GO_BIN' build -buildvcs=false -ldflags='-w -s' -o '$BINARY_PATH' ./cmd/agent" 2>&1; then
         if [ -f "$BINARY_PATH" ] && [ -s "$BINARY_PATH" ]; then
             sudo chmod +x "$BINARY_PATH"
             echo -e "${GREEN}✓ 编译成功${NC}"
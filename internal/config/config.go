@@ -23,6 +23,19 @@ type Config struct {
 
 	Debug bool `yaml:"debug"`
 
+	// DNS测试
+	DNS struct {
+		PublicResolvers []string `yaml:"public_resolvers"` // ceDnsCompare 默认对比的公共DNS，格式同 ds 参数
+		RootHints       []string `yaml:"root_hints"`       // ceDnsTrace 使用的根服务器IP，为空时使用内置的根提示
+		TrustAnchors    []string `yaml:"trust_anchors"`    // DNSSEC校验使用的根区信任锚（DS记录），为空时使用内置的信任锚
+	} `yaml:"dns"`
+
+	// 结果IP信息补充（ASN、地理位置、PTR）
+	Enrich struct {
+		Databases    []string `yaml:"databases"`      // 数据库文件路径：MaxMind DB 格式（如 GeoLite2-ASN.mmdb）或 IP2Location/IP2Proxy 的 BIN 格式（.bin）
+		PTRCacheSize int      `yaml:"ptr_cache_size"` // PTR缓存条目上限
+	} `yaml:"enrich"`
+
 	// 节点信息（通过心跳获取并持久化）
 	Node struct {
 		ID       uint   `yaml:"id"`       // 节点ID
@@ -41,6 +54,10 @@ func Load() (*Config, error) {
 	cfg.Server.Port = 2200
 	cfg.Heartbeat.Interval = 60
 	cfg.Debug = false
+	cfg.DNS.PublicResolvers = []string{
+		"223.5.5.5", "119.29.29.29", "114.114.114.114", "8.8.8.8", "1.1.1.1", "9.9.9.9",
+		"2400:3200::1", "2402:4e00::", "2001:4860:4860::8888", "2606:4700:4700::1111",
+	}
 
 	// 从环境变量读取后端URL
 	backendURL := os.Getenv("BACKEND_URL")
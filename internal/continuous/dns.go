@@ -0,0 +1,210 @@
+package continuous
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"linkmaster-node/internal/dnsprobe"
+	"linkmaster-node/internal/stats"
+
+	"go.uber.org/zap"
+)
+
+// DNSTask 持续查询一个域名，每轮推送延迟、响应码和应答，并标记应答是否与上一轮不同
+type DNSTask struct {
+	TaskID      string
+	Target      string
+	Options     dnsprobe.Options // 每轮查询的参数
+	Interval    time.Duration
+	MaxDuration time.Duration
+	StartTime   time.Time
+	LastRequest time.Time
+	StopCh      chan struct{}
+	IsRunning   bool
+	mu          sync.RWMutex
+	logger      *zap.Logger
+	window      *stats.Window      // 最近样本的滚动统计
+	cancelQuery context.CancelFunc // 取消正在执行的查询
+	queryMu     sync.Mutex         // 保护 cancelQuery 的锁
+	cycles      int
+	changes     int    // 应答变化的次数
+	lastKey     string // 上一次成功查询的应答，用于判断是否变化
+	lastAnswers []string
+}
+
+func NewDNSTask(taskID, target string, interval, maxDuration time.Duration, opts dnsprobe.Options) (*DNSTask, error) {
+	logger, _ := zap.NewProduction()
+	return &DNSTask{
+		TaskID:      taskID,
+		Target:      target,
+		Options:     opts,
+		Interval:    interval,
+		MaxDuration: maxDuration,
+		StartTime:   time.Now(),
+		LastRequest: time.Now(),
+		StopCh:      make(chan struct{}),
+		IsRunning:   true,
+		logger:      logger,
+		window:      stats.NewWindow(statsWindowSize),
+	}, nil
+}
+
+func (t *DNSTask) Start(ctx context.Context, resultCallback func(result map[string]interface{})) {
+	resultCallback = withStats(t.window, resultCallback)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.StopCh:
+			return
+		default:
+			// 检查是否超过最大运行时长
+			t.mu.RLock()
+			if time.Since(t.StartTime) > t.MaxDuration {
+				t.mu.RUnlock()
+				t.Stop()
+				return
+			}
+			t.mu.RUnlock()
+
+			// 执行一轮查询
+			result := t.executeQuery()
+
+			// 再次检查任务是否已停止（执行完成后）
+			t.mu.RLock()
+			isRunning := t.IsRunning
+			t.mu.RUnlock()
+			if !isRunning {
+				return
+			}
+
+			if resultCallback != nil {
+				resultCallback(result)
+			}
+
+			// 等待间隔时间后继续下一次测试
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.StopCh:
+				return
+			case <-time.After(t.Interval):
+				// 继续下一次循环
+			}
+		}
+	}
+}
+
+func (t *DNSTask) Stop() {
+	t.mu.Lock()
+	if !t.IsRunning {
+		t.mu.Unlock()
+		return
+	}
+	t.IsRunning = false
+	t.mu.Unlock()
+
+	// 取消正在执行的查询
+	t.queryMu.Lock()
+	if t.cancelQuery != nil {
+		t.cancelQuery()
+		t.cancelQuery = nil
+	}
+	t.queryMu.Unlock()
+
+	// 关闭停止通道
+	select {
+	case <-t.StopCh:
+		// 已经关闭
+	default:
+		close(t.StopCh)
+	}
+
+	t.logger.Info("DNS任务已停止", zap.String("task_id", t.TaskID))
+}
+
+func (t *DNSTask) UpdateLastRequest() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.LastRequest = time.Now()
+}
+
+// executeQuery 执行一轮查询；changed 与上一次成功的查询比较，忽略TTL和记录顺序
+func (t *DNSTask) executeQuery() map[string]interface{} {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.queryMu.Lock()
+	t.cancelQuery = cancel
+	t.queryMu.Unlock()
+	defer func() {
+		t.queryMu.Lock()
+		t.cancelQuery = nil
+		t.queryMu.Unlock()
+		cancel()
+	}()
+
+	resp, err := dnsprobe.Query(ctx, t.Options)
+	var validation map[string]interface{}
+	if err == nil && t.Options.DNSSEC {
+		validation = dnsprobe.Validate(ctx, t.Options, resp).Map()
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cycles++
+	if err != nil {
+		return map[string]interface{}{
+			"timestamp":   time.Now().Unix(),
+			"latency":     -1,
+			"success":     false,
+			"packet_loss": true,
+			"cycles":      t.cycles,
+			"changes":     t.changes,
+			"error":       err.Error(),
+		}
+	}
+
+	msg := resp.Msg
+	answers := make([]string, 0, len(msg.Answer))
+	for _, rr := range msg.Answer {
+		_, text, _ := rr.Fields()
+		answers = append(answers, dnsprobe.TypeString(rr.Type)+" "+text)
+	}
+	key := dnsprobe.AnswerKey(msg, t.Options.Type)
+	changed := t.lastKey != "" && key != t.lastKey
+
+	result := map[string]interface{}{
+		"timestamp":   time.Now().Unix(),
+		"latency":     stats.Round(stats.Milliseconds(resp.Timing.Query)),
+		"success":     true,
+		"packet_loss": false,
+		"server":      resp.Server,
+		"rcode":       dnsprobe.RcodeString(msg.Rcode()),
+		"flags":       msg.Flags(),
+		"answers":     answers,
+		"answer":      dnsprobe.RecordMaps(msg.Answer),
+		"answer_set":  strings.Split(key, "\n"),
+		"min_ttl":     dnsprobe.MinTTL(msg),
+		"changed":     changed,
+		"cycles":      t.cycles,
+	}
+	if changed {
+		t.changes++
+		result["previous_answers"] = t.lastAnswers
+		t.logger.Info("DNS应答发生变化", zap.String("task_id", t.TaskID), zap.String("name", t.Options.Name),
+			zap.String("previous", t.lastKey), zap.String("current", key))
+	}
+	result["changes"] = t.changes
+	t.lastKey = key
+	t.lastAnswers = answers
+
+	// dnssec 参数：只推送校验状态，被篡改的应答会表现为 bogus
+	if validation != nil {
+		result["dnssec"] = validation["status"]
+		if reason, ok := validation["reason"]; ok {
+			result["dnssec_reason"] = reason
+		}
+	}
+	return result
+}
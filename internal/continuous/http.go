@@ -0,0 +1,206 @@
+package continuous
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"linkmaster-node/internal/httpprobe"
+	"linkmaster-node/internal/stats"
+
+	"go.uber.org/zap"
+)
+
+// HTTPTask 持续请求一个URL，每轮推送状态码、各阶段耗时、响应体大小和断言结果
+type HTTPTask struct {
+	TaskID      string
+	Target      string
+	Options     httpprobe.Options // 每轮请求的参数，KeepAlive 时各轮复用连接
+	Interval    time.Duration
+	MaxDuration time.Duration
+	StartTime   time.Time
+	LastRequest time.Time
+	StopCh      chan struct{}
+	IsRunning   bool
+	mu          sync.RWMutex
+	logger      *zap.Logger
+	prober      *httpprobe.Prober
+	window      *stats.Window      // 最近样本的滚动统计
+	newWindow   *stats.Window      // 新建连接的请求
+	warmWindow  *stats.Window      // 复用连接的请求
+	cancelProbe context.CancelFunc // 取消正在执行的请求
+	probeMu     sync.Mutex         // 保护 cancelProbe 的锁
+	cycles      int
+}
+
+func NewHTTPTask(taskID, target string, interval, maxDuration time.Duration, opts httpprobe.Options) (*HTTPTask, error) {
+	logger, _ := zap.NewProduction()
+	return &HTTPTask{
+		TaskID:      taskID,
+		Target:      target,
+		Options:     opts,
+		Interval:    interval,
+		MaxDuration: maxDuration,
+		StartTime:   time.Now(),
+		LastRequest: time.Now(),
+		StopCh:      make(chan struct{}),
+		IsRunning:   true,
+		logger:      logger,
+		prober:      httpprobe.NewProber(opts),
+		window:      stats.NewWindow(statsWindowSize),
+		newWindow:   stats.NewWindow(statsWindowSize),
+		warmWindow:  stats.NewWindow(statsWindowSize),
+	}, nil
+}
+
+func (t *HTTPTask) Start(ctx context.Context, resultCallback func(result map[string]interface{})) {
+	resultCallback = withStats(t.window, resultCallback)
+	defer t.prober.Close()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.StopCh:
+			return
+		default:
+			// 检查是否超过最大运行时长
+			t.mu.RLock()
+			if time.Since(t.StartTime) > t.MaxDuration {
+				t.mu.RUnlock()
+				t.Stop()
+				return
+			}
+			t.mu.RUnlock()
+
+			// 执行一轮请求
+			result := t.executeProbe()
+
+			// 再次检查任务是否已停止（执行完成后）
+			t.mu.RLock()
+			isRunning := t.IsRunning
+			t.mu.RUnlock()
+			if !isRunning {
+				return
+			}
+
+			if resultCallback != nil {
+				resultCallback(result)
+			}
+
+			// 等待间隔时间后继续下一次测试
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.StopCh:
+				return
+			case <-time.After(t.Interval):
+				// 继续下一次循环
+			}
+		}
+	}
+}
+
+func (t *HTTPTask) Stop() {
+	t.mu.Lock()
+	if !t.IsRunning {
+		t.mu.Unlock()
+		return
+	}
+	t.IsRunning = false
+	t.mu.Unlock()
+
+	// 取消正在执行的请求
+	t.probeMu.Lock()
+	if t.cancelProbe != nil {
+		t.cancelProbe()
+		t.cancelProbe = nil
+	}
+	t.probeMu.Unlock()
+
+	// 关闭停止通道
+	select {
+	case <-t.StopCh:
+		// 已经关闭
+	default:
+		close(t.StopCh)
+	}
+
+	t.logger.Info("HTTP任务已停止", zap.String("task_id", t.TaskID))
+}
+
+func (t *HTTPTask) UpdateLastRequest() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.LastRequest = time.Now()
+}
+
+// executeProbe 执行一轮请求；success 要求收到响应且断言（如有）全部通过
+func (t *HTTPTask) executeProbe() map[string]interface{} {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.probeMu.Lock()
+	t.cancelProbe = cancel
+	t.probeMu.Unlock()
+	defer func() {
+		t.probeMu.Lock()
+		t.cancelProbe = nil
+		t.probeMu.Unlock()
+		cancel()
+	}()
+
+	res := t.prober.Probe(ctx)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cycles++
+
+	// 最后一跳是否复用了已有连接，失败时按新建连接统计
+	reused := false
+	if len(res.Hops) > 0 {
+		reused = res.Hops[len(res.Hops)-1].Reused
+	}
+	result := map[string]interface{}{
+		"timestamp": time.Now().Unix(),
+		"cycles":    t.cycles,
+		"reused":    reused,
+		"ip":        res.IP,
+	}
+	if len(t.Options.Assertions) > 0 {
+		result["assertions"] = res.Assertions
+		result["assert_ok"] = res.AssertOK
+	}
+	if res.Err != nil {
+		result["error"] = res.Err.Error()
+	}
+
+	received := res.StatusCode != 0
+	success := received && (len(t.Options.Assertions) == 0 || res.AssertOK)
+	latency := -1.0
+	if received {
+		latency = stats.Round(stats.Milliseconds(res.Total))
+		result["status_code"] = res.StatusCode
+		result["proto"] = res.Proto
+		result["body_size"] = len(res.Body)
+		result["redirect_count"] = max(len(res.Hops)-1, 0)
+		result["dns"] = stats.Round(stats.Milliseconds(res.DNS))
+		result["connect"] = stats.Round(stats.Milliseconds(res.Connect))
+		result["tls"] = stats.Round(stats.Milliseconds(res.TLSHandshake))
+		result["ttfb"] = stats.Round(stats.Milliseconds(res.FirstByte))
+		result["download"] = stats.Round(stats.Milliseconds(res.Download))
+		result["total"] = latency
+	}
+	result["latency"] = latency
+	result["success"] = success
+	result["packet_loss"] = !received
+
+	// 开启 keep_alive 时分别统计新建连接和复用连接的延迟
+	if t.Options.KeepAlive {
+		window := t.newWindow
+		if reused {
+			window = t.warmWindow
+		}
+		window.Add(latency, received)
+		result["stats_new"] = t.newWindow.Fields()
+		result["stats_reused"] = t.warmWindow.Fields()
+	}
+	return result
+}
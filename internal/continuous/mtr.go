@@ -0,0 +1,302 @@
+package continuous
+
+import (
+	"context"
+	"errors"
+	"math"
+	"os/exec"
+	"sort"
+	"sync"
+	"time"
+
+	"linkmaster-node/internal/netutil"
+	"linkmaster-node/internal/stats"
+	"linkmaster-node/internal/traceroute"
+
+	"go.uber.org/zap"
+)
+
+// MTRTask 持续追踪到目标的路径，每轮推送各跳的累计丢包和延迟统计
+type MTRTask struct {
+	TaskID      string
+	Target      string
+	Host        string
+	Options     traceroute.Options // 每轮追踪的参数
+	Interval    time.Duration
+	MaxDuration time.Duration
+	StartTime   time.Time
+	LastRequest time.Time
+	StopCh      chan struct{}
+	IsRunning   bool
+	mu          sync.RWMutex
+	logger      *zap.Logger
+	cancelTrace context.CancelFunc // 取消正在执行的追踪
+	traceMu     sync.Mutex         // 保护 cancelTrace 的锁
+	cycles      int
+	hops        map[int]*mtrHop // TTL -> 累计统计
+}
+
+// mtrHop 一跳的累计统计
+type mtrHop struct {
+	ips      []string
+	sent     int
+	received int
+	last     float64
+	replied  bool // 本轮是否有应答，没有时 last 无效
+	best     float64
+	worst    float64
+	sum      float64
+	sumSq    float64
+}
+
+func NewMTRTask(taskID, target string, interval, maxDuration time.Duration, opts traceroute.Options) (*MTRTask, error) {
+	parsed, err := netutil.ParseTarget(target)
+	if err != nil {
+		return nil, err
+	}
+
+	logger, _ := zap.NewProduction()
+	return &MTRTask{
+		TaskID:      taskID,
+		Target:      target,
+		Host:        parsed.Host,
+		Options:     opts,
+		Interval:    interval,
+		MaxDuration: maxDuration,
+		StartTime:   time.Now(),
+		LastRequest: time.Now(),
+		StopCh:      make(chan struct{}),
+		IsRunning:   true,
+		logger:      logger,
+		hops:        make(map[int]*mtrHop),
+	}, nil
+}
+
+func (t *MTRTask) Start(ctx context.Context, resultCallback func(result map[string]interface{})) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.StopCh:
+			return
+		default:
+			// 检查是否超过最大运行时长
+			t.mu.RLock()
+			if time.Since(t.StartTime) > t.MaxDuration {
+				t.mu.RUnlock()
+				t.Stop()
+				return
+			}
+			t.mu.RUnlock()
+
+			// 执行一轮追踪
+			result := t.executeTrace()
+
+			// 再次检查任务是否已停止（执行完成后）
+			t.mu.RLock()
+			isRunning := t.IsRunning
+			t.mu.RUnlock()
+			if !isRunning {
+				return
+			}
+
+			if resultCallback != nil {
+				resultCallback(result)
+			}
+
+			// 等待间隔时间后继续下一次测试
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.StopCh:
+				return
+			case <-time.After(t.Interval):
+				// 继续下一次循环
+			}
+		}
+	}
+}
+
+func (t *MTRTask) Stop() {
+	t.mu.Lock()
+	if !t.IsRunning {
+		t.mu.Unlock()
+		return
+	}
+	t.IsRunning = false
+	t.mu.Unlock()
+
+	// 取消正在执行的追踪
+	t.traceMu.Lock()
+	if t.cancelTrace != nil {
+		t.cancelTrace()
+		t.cancelTrace = nil
+	}
+	t.traceMu.Unlock()
+
+	// 关闭停止通道
+	select {
+	case <-t.StopCh:
+		// 已经关闭
+	default:
+		close(t.StopCh)
+	}
+
+	t.logger.Info("MTR任务已停止", zap.String("task_id", t.TaskID))
+}
+
+func (t *MTRTask) UpdateLastRequest() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.LastRequest = time.Now()
+}
+
+// executeTrace 执行一轮追踪并返回累计后的各跳统计
+func (t *MTRTask) executeTrace() map[string]interface{} {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.traceMu.Lock()
+	t.cancelTrace = cancel
+	t.traceMu.Unlock()
+	defer func() {
+		t.traceMu.Lock()
+		t.cancelTrace = nil
+		t.traceMu.Unlock()
+		cancel()
+	}()
+
+	res, err := traceroute.Run(ctx, t.Host, t.Options)
+	if errors.Is(err, traceroute.ErrUnavailable) {
+		// 没有原始套接字权限时回退到traceroute命令
+		var output []byte
+		output, err = exec.CommandContext(ctx, "traceroute", append(t.Options.ExecArgs(), t.Host)...).Output()
+		if err == nil {
+			res = traceroute.ParseOutput(string(output))
+		}
+	}
+	if err != nil {
+		return map[string]interface{}{
+			"timestamp":   time.Now().Unix(),
+			"latency":     -1,
+			"success":     false,
+			"packet_loss": true,
+			"error":       err.Error(),
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cycles++
+	for _, hop := range t.hops {
+		hop.replied = false
+	}
+	for _, h := range res.Hops {
+		t.record(h)
+	}
+
+	// 本轮目标所在一跳最后一个应答的延迟作为本轮延迟
+	latency := -1.0
+	if res.Reached && len(res.Hops) > 0 {
+		for _, p := range res.Hops[len(res.Hops)-1].Probes {
+			if !p.Timeout {
+				latency = p.RTT
+			}
+		}
+	}
+	return map[string]interface{}{
+		"timestamp":   time.Now().Unix(),
+		"latency":     latency,
+		"success":     res.Reached,
+		"packet_loss": !res.Reached,
+		"ip":          res.IP,
+		"reached":     res.Reached,
+		"cycles":      t.cycles,
+		"hops":        t.hopResults(len(res.Hops)),
+	}
+}
+
+// record 累计一跳的探测结果；调用方需持有 mu
+func (t *MTRTask) record(h traceroute.Hop) {
+	hop, ok := t.hops[h.TTL]
+	if !ok {
+		hop = &mtrHop{}
+		t.hops[h.TTL] = hop
+	}
+	for _, ip := range h.IPs() {
+		if !containsString(hop.ips, ip) {
+			hop.ips = append(hop.ips, ip)
+		}
+	}
+	for _, p := range h.Probes {
+		hop.sent++
+		if p.Timeout {
+			continue
+		}
+		hop.received++
+		hop.last = p.RTT
+		hop.replied = true
+		if hop.received == 1 || p.RTT < hop.best {
+			hop.best = p.RTT
+		}
+		if p.RTT > hop.worst {
+			hop.worst = p.RTT
+		}
+		hop.sum += p.RTT
+		hop.sumSq += p.RTT * p.RTT
+	}
+}
+
+// hopResults 返回前 count 跳的累计统计（路径变短后不再返回更远的跳）；调用方需持有 mu
+func (t *MTRTask) hopResults(count int) []map[string]interface{} {
+	ttls := make([]int, 0, len(t.hops))
+	for ttl := range t.hops {
+		ttls = append(ttls, ttl)
+	}
+	sort.Ints(ttls)
+
+	results := make([]map[string]interface{}, 0, count)
+	for _, ttl := range ttls {
+		if len(results) >= count {
+			break
+		}
+		hop := t.hops[ttl]
+		// traceroute命令的输出可能没有任何探测，此时按全部丢失处理，避免除零得到NaN导致序列化失败
+		loss := 100.0
+		if hop.sent > 0 {
+			loss = stats.Round(float64(hop.sent-hop.received) / float64(hop.sent) * 100)
+		}
+		result := map[string]interface{}{
+			"hop":      ttl,
+			"ips":      hop.ips,
+			"sent":     hop.sent,
+			"received": hop.received,
+			"loss":     loss,
+		}
+		if hop.received > 0 {
+			avg := hop.sum / float64(hop.received)
+			variance := hop.sumSq/float64(hop.received) - avg*avg
+			if variance < 0 {
+				variance = 0
+			}
+			// 本轮没有应答时不沿用之前轮次的延迟
+			result["last"] = nil
+			if hop.replied {
+				result["last"] = hop.last
+			}
+			result["avg"] = stats.Round(avg)
+			result["best"] = hop.best
+			result["worst"] = hop.worst
+			result["stddev"] = stats.Round(math.Sqrt(variance))
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
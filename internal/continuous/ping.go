@@ -3,18 +3,24 @@ package continuous
 import (
 	"bufio"
 	"context"
+	"errors"
 	"os/exec"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"linkmaster-node/internal/netutil"
+	"linkmaster-node/internal/stats"
+	"linkmaster-node/internal/pinger"
+
 	"go.uber.org/zap"
 )
 
 type PingTask struct {
 	TaskID      string
 	Target      string
+	Host        string // 从Target中解析出的主机名或IP
 	Interval    time.Duration
 	MaxDuration time.Duration
 	StartTime   time.Time
@@ -23,16 +29,26 @@ type PingTask struct {
 	IsRunning   bool
 	mu          sync.RWMutex
 	logger      *zap.Logger
+	window      *stats.Window // 最近样本的滚动统计
+	Options     pinger.Options // 每轮ping的参数（包数、间隔、大小、TTL等）
 	targetIP    string // 存储目标IP，从ping输出中提取
 	currentCmd  *exec.Cmd // 当前正在执行的命令，用于停止时取消
-	cmdMu       sync.Mutex // 保护 currentCmd 的锁
+	cancelPing  context.CancelFunc // 取消正在执行的原生ping
+	cmdMu       sync.Mutex // 保护 currentCmd 和 cancelPing 的锁
 }
 
-func NewPingTask(taskID, target string, interval, maxDuration time.Duration) *PingTask {
+func NewPingTask(taskID, target string, interval, maxDuration time.Duration, opts pinger.Options) (*PingTask, error) {
+	parsed, err := netutil.ParseTarget(target)
+	if err != nil {
+		return nil, err
+	}
+
 	logger, _ := zap.NewProduction()
 	return &PingTask{
 		TaskID:      taskID,
 		Target:      target,
+		Host:        parsed.Host,
+		Options:     opts,
 		Interval:    interval,
 		MaxDuration: maxDuration,
 		StartTime:   time.Now(),
@@ -40,10 +56,12 @@ func NewPingTask(taskID, target string, interval, maxDuration time.Duration) *Pi
 		StopCh:      make(chan struct{}),
 		IsRunning:   true,
 		logger:      logger,
-	}
+		window:      stats.NewWindow(statsWindowSize),
+	}, nil
 }
 
 func (t *PingTask) Start(ctx context.Context, resultCallback func(result map[string]interface{})) {
+	resultCallback = withStats(t.window, resultCallback)
 	for {
 		select {
 		case <-ctx.Done():
@@ -61,7 +79,7 @@ func (t *PingTask) Start(ctx context.Context, resultCallback func(result map[str
 			t.mu.RUnlock()
 
 			// 执行多个ping包测试，每个包完成后立即返回结果
-			// 使用 -c 10 -i 0.5 发送10个包，间隔0.5秒，实时解析每个包的延迟
+			// 按任务参数发送一轮包（默认10个，间隔0.5秒），实时返回每个包的延迟
 			t.executePingWithRealtimeCallback(resultCallback)
 
 			// 等待间隔时间后继续下一次测试（缩短间隔，比如1秒）
@@ -93,6 +111,10 @@ func (t *PingTask) Stop() {
 		t.currentCmd.Process.Kill()
 		t.currentCmd = nil
 	}
+	if t.cancelPing != nil {
+		t.cancelPing()
+		t.cancelPing = nil
+	}
 	t.cmdMu.Unlock()
 	
 	// 关闭停止通道
@@ -120,10 +142,69 @@ func (t *PingTask) executePingWithRealtimeCallback(resultCallback func(result ma
 	if !isRunning {
 		return
 	}
-	
-	// 发送10个ping包，间隔0.5秒，实时解析每个包的延迟
-	// 使用 -c 10 -i 0.5 发送10个包
-	cmd := exec.Command("ping", "-c", "10", "-i", "0.5", t.Target)
+
+	// 优先使用原生ICMP实现，每个包有结果后立即回调
+	ctx, cancel := context.WithCancel(context.Background())
+	t.cmdMu.Lock()
+	t.cancelPing = cancel
+	t.cmdMu.Unlock()
+	defer func() {
+		t.cmdMu.Lock()
+		t.cancelPing = nil
+		t.cmdMu.Unlock()
+		cancel()
+	}()
+
+	res, err := pinger.Ping(ctx, t.Host, t.Options, func(reply pinger.Reply) {
+		if resultCallback == nil || ctx.Err() != nil {
+			return
+		}
+		result := map[string]interface{}{
+			"timestamp":   time.Now().Unix(),
+			"latency":     -1,
+			"success":     false,
+			"packet_loss": true,
+		}
+		if !reply.Lost {
+			result["latency"] = stats.Round(stats.Milliseconds(reply.RTT))
+			result["success"] = true
+			result["packet_loss"] = false
+			result["ip"] = reply.From
+		} else {
+			t.mu.RLock()
+			if t.targetIP != "" {
+				result["ip"] = t.targetIP
+			}
+			t.mu.RUnlock()
+		}
+		resultCallback(result)
+	})
+	if errors.Is(err, pinger.ErrUnavailable) {
+		// 没有ICMP套接字权限时回退到系统ping命令
+		t.executePingExec(resultCallback)
+		return
+	}
+	if err != nil {
+		if resultCallback != nil {
+			resultCallback(map[string]interface{}{
+				"timestamp":   time.Now().Unix(),
+				"latency":     -1,
+				"success":     false,
+				"packet_loss": true,
+				"error":       err.Error(),
+			})
+		}
+		return
+	}
+	t.mu.Lock()
+	t.targetIP = res.IP
+	t.mu.Unlock()
+}
+
+// executePingExec 使用系统ping命令发送一轮ping包，实时解析每个包的延迟
+func (t *PingTask) executePingExec(resultCallback func(result map[string]interface{})) {
+	// 按任务参数发送一轮ping包（默认 -c 10 -i 0.5），实时解析每个包的延迟
+	cmd := exec.Command("ping", append(t.Options.ExecArgs(), t.Host)...)
 	
 	// 保存命令引用，以便停止时取消
 	t.cmdMu.Lock()
@@ -168,7 +249,7 @@ func (t *PingTask) executePingWithRealtimeCallback(resultCallback func(result ma
 	
 	// 检查任务是否已停止（在启动命令后）
 	t.mu.RLock()
-	isRunning = t.IsRunning
+	isRunning := t.IsRunning
 	t.mu.RUnlock()
 	if !isRunning {
 		// 任务已停止，取消命令
@@ -335,7 +416,11 @@ func parseSinglePacketLatency(line string) float64 {
 
 func (t *PingTask) executePing() map[string]interface{} {
 	// 发送单个ping包（-c 1），每个包完成后立即返回结果
-	cmd := exec.Command("ping", "-c", "1", t.Target)
+	args := []string{"-c", "1"}
+	if flag := t.Options.Family.Flag(); flag != "" {
+		args = append(args, flag)
+	}
+	cmd := exec.Command("ping", append(args, t.Host)...)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return map[string]interface{}{
@@ -0,0 +1,20 @@
+package continuous
+
+import "linkmaster-node/internal/stats"
+
+// statsWindowSize 持续测试滚动统计保留的最近样本数
+const statsWindowSize = 100
+
+// withStats 包装结果回调，每个结果附带最近样本的滚动统计（stats字段）
+func withStats(window *stats.Window, resultCallback func(result map[string]interface{})) func(result map[string]interface{}) {
+	return func(result map[string]interface{}) {
+		if resultCallback == nil {
+			return
+		}
+		latency, _ := result["latency"].(float64)
+		success, _ := result["success"].(bool)
+		window.Add(latency, success)
+		result["stats"] = window.Fields()
+		resultCallback(result)
+	}
+}
@@ -2,13 +2,14 @@ package continuous
 
 import (
 	"context"
-	"fmt"
 	"net"
 	"strconv"
-	"strings"
 	"sync"
 	"time"
 
+	"linkmaster-node/internal/netutil"
+	"linkmaster-node/internal/stats"
+
 	"go.uber.org/zap"
 )
 
@@ -17,6 +18,7 @@ type TCPingTask struct {
 	Target      string
 	Host        string
 	Port        int
+	Family      netutil.Family
 	Interval    time.Duration
 	MaxDuration time.Duration
 	StartTime   time.Time
@@ -25,20 +27,17 @@ type TCPingTask struct {
 	IsRunning   bool
 	mu          sync.RWMutex
 	logger      *zap.Logger
+	window      *stats.Window // 最近样本的滚动统计
 }
 
-func NewTCPingTask(taskID, target string, interval, maxDuration time.Duration) (*TCPingTask, error) {
-	// 解析host:port
-	parts := strings.Split(target, ":")
-	if len(parts) != 2 {
-		return nil, fmt.Errorf("无效的target格式，需要 host:port")
-	}
-
-	host := parts[0]
-	port, err := strconv.Atoi(parts[1])
+func NewTCPingTask(taskID, target string, interval, maxDuration time.Duration, family netutil.Family) (*TCPingTask, error) {
+	// 解析host:port（支持 [IPv6]:port、国际化域名和URL）
+	parsed, err := netutil.ParseHostPort(target)
 	if err != nil {
-		return nil, fmt.Errorf("无效的端口: %v", err)
+		return nil, err
 	}
+	host := parsed.Host
+	port := parsed.Port
 
 	logger, _ := zap.NewProduction()
 	return &TCPingTask{
@@ -46,6 +45,7 @@ func NewTCPingTask(taskID, target string, interval, maxDuration time.Duration) (
 		Target:      target,
 		Host:        host,
 		Port:        port,
+		Family:      family,
 		Interval:    interval,
 		MaxDuration: maxDuration,
 		StartTime:   time.Now(),
@@ -53,10 +53,12 @@ func NewTCPingTask(taskID, target string, interval, maxDuration time.Duration) (
 		StopCh:      make(chan struct{}),
 		IsRunning:   true,
 		logger:      logger,
+		window:      stats.NewWindow(statsWindowSize),
 	}, nil
 }
 
 func (t *TCPingTask) Start(ctx context.Context, resultCallback func(result map[string]interface{})) {
+	resultCallback = withStats(t.window, resultCallback)
 	for {
 		select {
 		case <-ctx.Done():
@@ -136,34 +138,19 @@ func (t *TCPingTask) UpdateLastRequest() {
 }
 
 func (t *TCPingTask) executeTCPing() map[string]interface{} {
-	start := time.Now()
-	conn, err := net.DialTimeout("tcp", net.JoinHostPort(t.Host, strconv.Itoa(t.Port)), 5*time.Second)
-	latency := time.Since(start).Milliseconds()
-
-	// 提取目标IP
+	// 按地址族解析目标IP，解析失败时交由拨号返回错误
+	dialHost := t.Host
 	var targetIP string
-	if conn != nil {
-		if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
-			targetIP = addr.IP.String()
-		}
-		defer conn.Close()
+	if ip, err := t.Family.LookupPrimaryIP(context.Background(), t.Host); err == nil {
+		targetIP = ip.String()
+		dialHost = targetIP
 	}
 
-	// 如果连接失败，从host解析
-	if targetIP == "" {
-		ips, err := net.LookupIP(t.Host)
-		if err == nil && len(ips) > 0 {
-			// 优先使用IPv4
-			for _, ip := range ips {
-				if ip.To4() != nil {
-					targetIP = ip.String()
-					break
-				}
-			}
-			if targetIP == "" && len(ips) > 0 {
-				targetIP = ips[0].String()
-			}
-		}
+	start := time.Now()
+	conn, err := net.DialTimeout(t.Family.Network("tcp"), net.JoinHostPort(dialHost, strconv.Itoa(t.Port)), 5*time.Second)
+	latency := stats.Round(stats.Milliseconds(time.Since(start)))
+	if conn != nil {
+		defer conn.Close()
 	}
 
 	if err != nil {
@@ -179,7 +166,7 @@ func (t *TCPingTask) executeTCPing() map[string]interface{} {
 
 	return map[string]interface{}{
 		"timestamp":   time.Now().Unix(),
-		"latency":     float64(latency),
+		"latency":     latency,
 		"success":     true,
 		"packet_loss": false,
 		"ip":          targetIP,
@@ -0,0 +1,335 @@
+package dnsprobe
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"linkmaster-node/internal/netutil"
+)
+
+// Response 一次查询的应答
+type Response struct {
+	Msg          *Message
+	Server       string // 应答的服务器 ip:port
+	ServerName   string // ds 中的主机名，加密传输时也是TLS的SNI
+	URL          string // DoH 请求的URL
+	Transport    string // udp、tcp、tls、https 或 quic
+	Timing       Timing
+	Size         int    // 应答报文字节数
+	UDPTruncated bool   // UDP应答被截断后改用了TCP
+	HTTPVersion  string // DoH 使用的HTTP版本
+	TLS          *tls.ConnectionState
+}
+
+// Timing 查询各阶段的耗时，不涉及的阶段为0
+type Timing struct {
+	Resolve time.Duration // 解析DNS服务器的域名
+	Connect time.Duration // 建立TCP连接
+	TLS     time.Duration // TLS握手；QUIC的握手包含TLS，全部计入此项
+	Query   time.Duration // 发出查询到收到应答
+	Total   time.Duration
+}
+
+// Query 按 opts 发送查询；使用系统DNS时依次尝试 resolv.conf 中的服务器
+func Query(ctx context.Context, opts Options) (*Response, error) {
+	start := time.Now()
+	server, err := ParseServer(opts.Server)
+	if err != nil {
+		return nil, err
+	}
+	query := opts.NewMessage(opts.Recursion)
+
+	var addrs []string
+	var resolve time.Duration
+	if server.Host == "" {
+		addrs = SystemServers(opts.Family)
+		if len(addrs) == 0 {
+			return nil, fmt.Errorf("没有可用的 IPv%s 系统DNS服务器", opts.Family)
+		}
+	} else {
+		ip, err := opts.Family.LookupPrimaryIP(ctx, server.Host)
+		if err != nil {
+			return nil, err
+		}
+		resolve = time.Since(start)
+		addrs = []string{net.JoinHostPort(ip.String(), strconv.Itoa(server.Port))}
+	}
+
+	var resp *Response
+	for _, addr := range addrs {
+		switch server.Transport {
+		case TransportTLS:
+			resp, err = exchangeTLS(ctx, addr, server, query, opts)
+		case TransportHTTPS:
+			// RFC 8484 建议DoH使用ID 0，便于HTTP缓存
+			query.ID = 0
+			resp, err = exchangeHTTPS(ctx, addr, server, query, opts)
+		case TransportQUIC:
+			// RFC 9250 要求DoQ的ID为0
+			query.ID = 0
+			resp, err = exchangeQUIC(ctx, addr, server, query, opts)
+		default:
+			resp, err = Exchange(ctx, addr, query, opts.Timeout, opts.TCP || server.Transport == TransportTCP)
+		}
+		if err == nil || ctx.Err() != nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	resp.ServerName = server.Host
+	resp.Timing.Resolve = resolve
+	resp.Timing.Total = time.Since(start)
+	return resp, nil
+}
+
+// Exchange 向 server（ip:port）发送查询，UDP应答被截断时改用TCP重新查询
+func Exchange(ctx context.Context, server string, query *Message, timeout time.Duration, useTCP bool) (*Response, error) {
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	if !useTCP {
+		resp, err := exchangeUDP(ctx, server, packed, query, timeout)
+		if err != nil || !resp.Msg.Truncated {
+			return resp, err
+		}
+	}
+	resp, err := exchangeTCP(ctx, server, packed, query, timeout)
+	if err == nil && !useTCP {
+		resp.UDPTruncated = true
+	}
+	return resp, err
+}
+
+func exchangeUDP(ctx context.Context, server string, packed []byte, query *Message, timeout time.Duration) (*Response, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	stop := watchContext(ctx, conn)
+	defer stop()
+
+	start := time.Now()
+	if _, err := conn.Write(packed); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 65535)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, contextError(ctx, err)
+		}
+		// 忽略ID不匹配的报文（迟到的旧应答或伪造报文）
+		if n < 12 || binary.BigEndian.Uint16(buf) != query.ID {
+			continue
+		}
+		rtt := time.Since(start)
+		msg, err := checkResponse(buf[:n], query)
+		if err != nil {
+			return nil, err
+		}
+		return &Response{Msg: msg, Server: server, Transport: TransportUDP, Timing: Timing{Query: rtt}, Size: n}, nil
+	}
+}
+
+func exchangeTCP(ctx context.Context, server string, packed []byte, query *Message, timeout time.Duration) (*Response, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", server)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+	defer conn.Close()
+	stop := watchContext(ctx, conn)
+	defer stop()
+	resp := &Response{Server: server, Transport: TransportTCP}
+	resp.Timing.Connect = time.Since(start)
+
+	start = time.Now()
+	resp.Msg, resp.Size, err = exchangeStream(conn, packed, query)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+	resp.Timing.Query = time.Since(start)
+	return resp, nil
+}
+
+// exchangeStream 在TCP等流式连接上发送带两字节长度前缀的查询并读取应答
+func exchangeStream(conn io.ReadWriter, packed []byte, query *Message) (*Message, int, error) {
+	if err := writeFramed(conn, packed); err != nil {
+		return nil, 0, err
+	}
+	return readFramed(conn, query)
+}
+
+// writeFramed 写出带两字节长度前缀的报文
+func writeFramed(w io.Writer, packed []byte) error {
+	out := make([]byte, 2, 2+len(packed))
+	binary.BigEndian.PutUint16(out, uint16(len(packed)))
+	_, err := w.Write(append(out, packed...))
+	return err
+}
+
+// readFramed 读取带两字节长度前缀的应答
+func readFramed(r io.Reader, query *Message) (*Message, int, error) {
+	var length [2]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return nil, 0, err
+	}
+	buf := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, 0, err
+	}
+	msg, err := checkResponse(buf, query)
+	return msg, len(buf), err
+}
+
+// checkResponse 解析应答并确认与查询对应
+func checkResponse(buf []byte, query *Message) (*Message, error) {
+	msg, err := Unpack(buf)
+	if err != nil {
+		return nil, fmt.Errorf("解析应答失败: %v", err)
+	}
+	if !msg.Response || msg.ID != query.ID {
+		return nil, errors.New("应答与查询不匹配")
+	}
+	// 部分服务器在出错时不回显问题部分
+	if len(msg.Questions) > 0 && len(query.Questions) > 0 {
+		q, r := query.Questions[0], msg.Questions[0]
+		if !strings.EqualFold(q.Name, r.Name) || q.Type != r.Type || q.Class != r.Class {
+			return nil, errors.New("应答的问题与查询不一致")
+		}
+	}
+	return msg, nil
+}
+
+// watchContext 在 ctx 结束时中断连接上阻塞的读写，返回的函数用于停止监听
+func watchContext(ctx context.Context, conn net.Conn) func() bool {
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	return context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Unix(1, 0))
+	})
+}
+
+// contextError 读写因 ctx 结束而失败时返回更明确的错误
+func contextError(ctx context.Context, err error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) || errors.Is(err, os.ErrDeadlineExceeded) {
+		return errors.New("查询超时")
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// 查询使用的传输方式
+const (
+	TransportUDP   = "udp"
+	TransportTCP   = "tcp"
+	TransportTLS   = "tls"   // DNS over TLS，RFC 7858
+	TransportHTTPS = "https" // DNS over HTTPS，RFC 8484
+	TransportQUIC  = "quic"  // DNS over QUIC，RFC 9250
+)
+
+var transportPorts = map[string]int{
+	TransportUDP:   53,
+	TransportTCP:   53,
+	TransportTLS:   853,
+	TransportHTTPS: 443,
+	TransportQUIC:  853,
+}
+
+// Server 解析后的DNS服务器
+type Server struct {
+	Transport string
+	Host      string // 主机名或IP，为空表示使用系统DNS
+	Port      int
+	Path      string // DoH 的请求路径
+}
+
+// ParseServer 解析 ds 参数：ip、ip:port、[IPv6]:port、域名，
+// 或 udp://、tcp://、tls://host:853、https://host/dns-query、quic://host:853 形式的URL
+func ParseServer(ds string) (Server, error) {
+	ds = strings.TrimSpace(ds)
+	if ds == "" {
+		return Server{Transport: TransportUDP}, nil
+	}
+	// 兼容 RFC 8484 的URI模板写法，如 https://dns.example/dns-query{?dns}
+	if i := strings.Index(ds, "{"); i >= 0 {
+		ds = ds[:i]
+	}
+	target, err := netutil.ParseTarget(ds)
+	if err != nil {
+		return Server{}, err
+	}
+	server := Server{Transport: target.Scheme, Host: target.Host, Port: target.Port}
+	if server.Transport == "" || server.Transport == "dns" {
+		server.Transport = TransportUDP
+	}
+	defaultPort, ok := transportPorts[server.Transport]
+	if !ok {
+		return Server{}, fmt.Errorf("不支持的DNS服务器协议: %s", target.Scheme)
+	}
+	if server.Port == 0 {
+		server.Port = defaultPort
+	}
+	if server.Transport == TransportHTTPS {
+		server.Path = target.Path
+		if server.Path == "" || server.Path == "/" {
+			server.Path = "/dns-query"
+		}
+	}
+	return server, nil
+}
+
+// SystemServers 返回 /etc/resolv.conf 中配置的DNS服务器（ip:port），没有配置时与Go标准库一样使用本机
+func SystemServers(family netutil.Family) []string {
+	var servers []string
+	if f, err := os.Open("/etc/resolv.conf"); err == nil {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) >= 2 && fields[0] == "nameserver" {
+				servers = append(servers, fields[1])
+			}
+		}
+		f.Close()
+	}
+	if len(servers) == 0 {
+		servers = []string{"127.0.0.1", "::1"}
+	}
+
+	result := make([]string, 0, len(servers))
+	for _, s := range servers {
+		host, _, _ := strings.Cut(s, "%")
+		ip := net.ParseIP(host)
+		if ip == nil || !family.Match(ip) {
+			continue
+		}
+		result = append(result, net.JoinHostPort(s, "53"))
+	}
+	return result
+}
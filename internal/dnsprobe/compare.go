@@ -0,0 +1,281 @@
+package dnsprobe
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"linkmaster-node/internal/netutil"
+	"linkmaster-node/internal/stats"
+
+	"github.com/miekg/dns"
+)
+
+// 对比查询中解析器的来源
+const (
+	ResolverSystem        = "system"
+	ResolverPublic        = "public"
+	ResolverCustom        = "custom"
+	ResolverAuthoritative = "authoritative"
+)
+
+// compareConcurrency 同时进行的查询数量
+const compareConcurrency = 16
+
+// Resolver 对比查询的一个解析器
+type Resolver struct {
+	Kind   string
+	Label  string // 显示名称：ds 原始值或权威服务器的NS名称
+	Server string // ds 格式的地址，系统DNS为空
+}
+
+// CompareResult 一个解析器的查询结果
+type CompareResult struct {
+	Resolver Resolver
+	Response *Response
+	Err      error
+}
+
+// Compare 并发地向所有解析器发送同一个查询；权威服务器不设置RD标志
+func Compare(ctx context.Context, opts Options, resolvers []Resolver) []CompareResult {
+	results := make([]CompareResult, len(resolvers))
+	sem := make(chan struct{}, compareConcurrency)
+	var wg sync.WaitGroup
+	for i, r := range resolvers {
+		wg.Add(1)
+		go func(i int, r Resolver) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			o := opts
+			o.Server = r.Server
+			if r.Kind == ResolverAuthoritative {
+				if r.Server == "" {
+					results[i] = CompareResult{Resolver: r, Err: errors.New("无法解析权威服务器的地址")}
+					return
+				}
+				o.Recursion = false
+			}
+			resp, err := Query(ctx, o)
+			results[i] = CompareResult{Resolver: r, Response: resp, Err: err}
+		}(i, r)
+	}
+	wg.Wait()
+	return results
+}
+
+// PublicResolvers 将配置的公共DNS转换为对比的解析器，按地址族跳过不可用的IP地址
+// （auto 时只使用IPv4地址），域名形式的地址始终保留
+func PublicResolvers(list []string, kind string, family netutil.Family) []Resolver {
+	resolvers := make([]Resolver, 0, len(list))
+	for _, ds := range list {
+		server, err := ParseServer(ds)
+		if err != nil {
+			continue
+		}
+		if ip := net.ParseIP(server.Host); ip != nil {
+			if (family == netutil.FamilyAuto && ip.To4() == nil) || !family.Match(ip) {
+				continue
+			}
+		}
+		resolvers = append(resolvers, Resolver{Kind: kind, Label: ds, Server: ds})
+	}
+	return resolvers
+}
+
+// AnswerKey 返回用于判断应答是否一致的键：查询类型的记录集合（不含TTL），
+// 没有该类型记录时依次使用CNAME目标和响应码（NOERROR 记为 NODATA）
+func AnswerKey(msg *Message, qtype uint16) string {
+	var data, cnames []string
+	for _, rr := range msg.Answer {
+		_, text, _ := rr.Fields()
+		switch {
+		case rr.Type == qtype || qtype == TypeANY:
+			data = append(data, TypeString(rr.Type)+" "+strings.ToLower(text))
+		case rr.Type == TypeCNAME:
+			cnames = append(cnames, "CNAME "+strings.ToLower(text))
+		}
+	}
+	if len(data) == 0 {
+		data = cnames
+	}
+	if len(data) == 0 {
+		if rcode := msg.Rcode(); rcode != 0 {
+			return RcodeString(rcode)
+		}
+		return "NODATA"
+	}
+	sort.Strings(data)
+	return strings.Join(data, "\n")
+}
+
+// MinTTL 返回应答部分记录的最小TTL，没有记录时为 -1
+func MinTTL(msg *Message) int64 {
+	ttl := int64(-1)
+	for _, rr := range msg.Answer {
+		if ttl < 0 || int64(rr.TTL) < ttl {
+			ttl = int64(rr.TTL)
+		}
+	}
+	return ttl
+}
+
+// CompareMaps 返回每个解析器的结果行、按应答内容分组的集合，以及成功的应答是否不一致；
+// 每行的 consistent 表示其应答是否与多数一致
+func CompareMaps(results []CompareResult, qtype uint16) ([]map[string]interface{}, []map[string]interface{}, bool) {
+	type answerSet struct {
+		key       string
+		resolvers []string
+	}
+	var sets []*answerSet
+	keys := make([]string, len(results))
+	for i, r := range results {
+		if r.Err != nil {
+			continue
+		}
+		keys[i] = AnswerKey(r.Response.Msg, qtype)
+		var set *answerSet
+		for _, s := range sets {
+			if s.key == keys[i] {
+				set = s
+			}
+		}
+		if set == nil {
+			set = &answerSet{key: keys[i]}
+			sets = append(sets, set)
+		}
+		set.resolvers = append(set.resolvers, r.Resolver.Label)
+	}
+	// 多数一致的集合排在最前
+	sort.SliceStable(sets, func(i, j int) bool { return len(sets[i].resolvers) > len(sets[j].resolvers) })
+
+	rows := make([]map[string]interface{}, 0, len(results))
+	for i, r := range results {
+		row := map[string]interface{}{
+			"resolver": r.Resolver.Label,
+			"kind":     r.Resolver.Kind,
+		}
+		if r.Err != nil {
+			row["error"] = r.Err.Error()
+			rows = append(rows, row)
+			continue
+		}
+		resp := r.Response
+		answers := make([]string, 0, len(resp.Msg.Answer))
+		for _, rr := range resp.Msg.Answer {
+			_, text, _ := rr.Fields()
+			answers = append(answers, TypeString(rr.Type)+" "+text)
+		}
+		row["server"] = resp.Server
+		row["transport"] = resp.Transport
+		row["rcode"] = RcodeString(resp.Msg.Rcode())
+		row["flags"] = resp.Msg.Flags()
+		row["answers"] = answers
+		row["answer"] = RecordMaps(resp.Msg.Answer)
+		row["min_ttl"] = MinTTL(resp.Msg)
+		row["query_time"] = stats.Round(stats.Milliseconds(resp.Timing.Query))
+		row["consistent"] = keys[i] == sets[0].key
+		rows = append(rows, row)
+	}
+
+	setMaps := make([]map[string]interface{}, 0, len(sets))
+	for _, s := range sets {
+		setMaps = append(setMaps, map[string]interface{}{
+			"answers":   strings.Split(s.key, "\n"),
+			"resolvers": s.resolvers,
+			"count":     len(s.resolvers),
+		})
+	}
+	return rows, setMaps, len(sets) > 1
+}
+
+// NameServer 区域的一个权威服务器
+type NameServer struct {
+	Name string
+	IP   net.IP // 按地址族选取的地址，解析失败时为 nil
+}
+
+// maxNameServers 对比时最多查询的权威服务器数量
+const maxNameServers = 8
+
+// FindAuthoritative 通过 opts 指定的递归服务器找到 name 所在的区域及其权威服务器
+func FindAuthoritative(ctx context.Context, name string, opts Options) (string, []NameServer, error) {
+	o := opts
+	o.Type = TypeNS
+	o.Class = ClassIN
+	o.Recursion = true
+
+	zone := Fqdn(name)
+	for i := 0; i < 16; i++ {
+		o.Name = zone
+		resp, err := Query(ctx, o)
+		if err != nil {
+			return "", nil, err
+		}
+		var names []string
+		for _, rr := range resp.Msg.Answer {
+			if rr.Type == TypeNS && strings.EqualFold(rr.Name, zone) {
+				ns, _, _ := dns.UnpackDomainName(rr.Data, 0)
+				names = append(names, ns)
+			}
+		}
+		if len(names) > 0 {
+			return zone, resolveNameServers(ctx, names, opts.Family), nil
+		}
+
+		// 没有NS记录时，应答中的SOA指明了所在区域
+		next := ""
+		for _, rr := range resp.Msg.Authority {
+			if rr.Type == TypeSOA && !strings.EqualFold(rr.Name, zone) {
+				next = rr.Name
+			}
+		}
+		if next == "" {
+			if zone == "." {
+				break
+			}
+			_, parent, _ := strings.Cut(zone, ".")
+			if parent == "" {
+				parent = "."
+			}
+			next = parent
+		}
+		zone = next
+	}
+	return "", nil, errors.New("未找到权威服务器")
+}
+
+// resolveNameServers 按地址族解析NS名称，结果按名称排序
+func resolveNameServers(ctx context.Context, names []string, family netutil.Family) []NameServer {
+	sort.Strings(names)
+	if len(names) > maxNameServers {
+		names = names[:maxNameServers]
+	}
+	servers := make([]NameServer, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			servers[i].Name = name
+			if ip, err := family.LookupPrimaryIP(ctx, strings.TrimSuffix(name, ".")); err == nil {
+				servers[i].IP = ip
+			}
+		}(i, name)
+	}
+	wg.Wait()
+	return servers
+}
+
+// Address 返回权威服务器的 ds 格式地址
+func (ns NameServer) Address() string {
+	if ns.IP == nil {
+		return ""
+	}
+	return net.JoinHostPort(ns.IP.String(), strconv.Itoa(53))
+}
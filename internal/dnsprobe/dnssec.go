@@ -0,0 +1,434 @@
+package dnsprobe
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// DNSSEC 签名校验（RFC 4034、RFC 4035），支持的算法见 RFC 8624 中必须实现的部分
+
+// DNSSEC 算法编号
+const (
+	algRSASHA1          = 5
+	algRSASHA1NSEC3SHA1 = 7
+	algRSASHA256        = 8
+	algRSASHA512        = 10
+	algECDSAP256SHA256  = 13
+	algECDSAP384SHA384  = 14
+	algED25519          = 15
+)
+
+// DS 摘要类型
+const (
+	digestSHA1   = 1
+	digestSHA256 = 2
+	digestSHA384 = 4
+)
+
+// errUnsupported 算法或摘要类型不受支持：DS如此时按 RFC 4035 第5.2节视为未签名，已签名区域中的记录如此时为bogus
+var errUnsupported = errors.New("不支持的算法")
+
+// DS 一条DS记录（或信任锚）
+type DS struct {
+	Zone       string
+	KeyTag     uint16
+	Algorithm  uint8
+	DigestType uint8
+	Digest     []byte
+}
+
+// rootAnchors 内置的根区信任锚（IANA root-anchors.xml 中的 KSK-2017 和 KSK-2024）
+var rootAnchors = []string{
+	". 20326 8 2 E06D44B80B8F1D39A95C0B0D7C65D08458E880409BBC683457104237C7F8EC8D",
+	". 38696 8 2 683D2D0ACB8C9B712A1948B27F741219298D0A450D612C483AF444A4C0FB2B16",
+}
+
+var (
+	anchorsMu    sync.RWMutex
+	trustAnchors = mustParseAnchors(rootAnchors)
+)
+
+// SetTrustAnchors 用配置的DS记录（主文件格式，如 ". 20326 8 2 E06D..."，可带 IN DS）替换内置的根区信任锚，
+// list 为空时保留内置的信任锚
+func SetTrustAnchors(list []string) error {
+	if len(list) == 0 {
+		return nil
+	}
+	anchors, err := parseAnchors(list)
+	if err != nil {
+		return err
+	}
+	anchorsMu.Lock()
+	trustAnchors = anchors
+	anchorsMu.Unlock()
+	return nil
+}
+
+func rootTrustAnchors() []DS {
+	anchorsMu.RLock()
+	defer anchorsMu.RUnlock()
+	return trustAnchors
+}
+
+func mustParseAnchors(list []string) []DS {
+	anchors, err := parseAnchors(list)
+	if err != nil {
+		panic(err)
+	}
+	return anchors
+}
+
+func parseAnchors(list []string) ([]DS, error) {
+	anchors := make([]DS, 0, len(list))
+	for _, line := range list {
+		var fields []string
+		for _, f := range strings.Fields(line) {
+			if !strings.EqualFold(f, "IN") && !strings.EqualFold(f, "DS") {
+				fields = append(fields, f)
+			}
+		}
+		if len(fields) < 5 {
+			return nil, fmt.Errorf("信任锚格式错误: %s", line)
+		}
+		tag, err1 := strconv.ParseUint(fields[1], 10, 16)
+		alg, err2 := strconv.ParseUint(fields[2], 10, 8)
+		digestType, err3 := strconv.ParseUint(fields[3], 10, 8)
+		digest, err4 := hex.DecodeString(strings.Join(fields[4:], ""))
+		if err := errors.Join(err1, err2, err3, err4); err != nil {
+			return nil, fmt.Errorf("信任锚格式错误: %s", line)
+		}
+		if strings.ToLower(Fqdn(fields[0])) != "." {
+			return nil, fmt.Errorf("信任锚必须为根区: %s", line)
+		}
+		anchors = append(anchors, DS{Zone: ".", KeyTag: uint16(tag), Algorithm: uint8(alg), DigestType: uint8(digestType), Digest: digest})
+	}
+	return anchors, nil
+}
+
+// parseDS 解析DS记录
+func parseDS(rr RR) (DS, error) {
+	if len(rr.Data) < 5 {
+		return DS{}, errors.New("DS记录长度错误")
+	}
+	return DS{
+		Zone:       strings.ToLower(rr.Name),
+		KeyTag:     binary.BigEndian.Uint16(rr.Data),
+		Algorithm:  rr.Data[2],
+		DigestType: rr.Data[3],
+		Digest:     rr.Data[4:],
+	}, nil
+}
+
+// supported 判断DS的算法和摘要类型是否都受支持
+func (ds DS) supported() bool {
+	switch ds.DigestType {
+	case digestSHA1, digestSHA256, digestSHA384:
+		return algorithmSupported(ds.Algorithm)
+	}
+	return false
+}
+
+// matches 判断DNSKEY是否与DS对应（RFC 4034 第5.1.4节）
+func (ds DS) matches(key RR) bool {
+	if len(key.Data) < 4 || key.Data[3] != ds.Algorithm || KeyTag(key.Data) != ds.KeyTag {
+		return false
+	}
+	owner, err := canonicalName(key.Name)
+	if err != nil {
+		return false
+	}
+	data := append(owner, key.Data...)
+	var digest []byte
+	switch ds.DigestType {
+	case digestSHA1:
+		sum := sha1.Sum(data)
+		digest = sum[:]
+	case digestSHA256:
+		sum := sha256.Sum256(data)
+		digest = sum[:]
+	case digestSHA384:
+		sum := sha512.Sum384(data)
+		digest = sum[:]
+	default:
+		return false
+	}
+	return bytes.Equal(digest, ds.Digest)
+}
+
+// RRSIG 解析后的签名记录
+type RRSIG struct {
+	TypeCovered uint16
+	Algorithm   uint8
+	Labels      uint8
+	OriginalTTL uint32
+	Expiration  uint32
+	Inception   uint32
+	KeyTag      uint16
+	Signer      string
+	Signature   []byte
+	header      []byte // 签名之前的记录数据，签名者名称为规范形式
+}
+
+// parseRRSIG 解析RRSIG记录
+func parseRRSIG(rr RR) (RRSIG, error) {
+	d := rr.Data
+	if len(d) < 19 {
+		return RRSIG{}, errors.New("RRSIG记录长度错误")
+	}
+	signer, off, err := dns.UnpackDomainName(d, 18)
+	if err != nil {
+		return RRSIG{}, err
+	}
+	header := append([]byte(nil), d[:off]...)
+	lowerName(header, 18)
+	return RRSIG{
+		TypeCovered: binary.BigEndian.Uint16(d),
+		Algorithm:   d[2],
+		Labels:      d[3],
+		OriginalTTL: binary.BigEndian.Uint32(d[4:]),
+		Expiration:  binary.BigEndian.Uint32(d[8:]),
+		Inception:   binary.BigEndian.Uint32(d[12:]),
+		KeyTag:      binary.BigEndian.Uint16(d[16:]),
+		Signer:      strings.ToLower(signer),
+		Signature:   d[off:],
+		header:      header,
+	}, nil
+}
+
+// verify 用 key 校验签名是否覆盖 rrset（RFC 4035 第5.3节）
+func (sig RRSIG) verify(rrset []RR, key RR, now time.Time) error {
+	if len(key.Data) < 4 {
+		return errors.New("DNSKEY记录长度错误")
+	}
+	flags := binary.BigEndian.Uint16(key.Data)
+	if flags&0x0100 == 0 || key.Data[2] != 3 {
+		return errors.New("DNSKEY不是区域密钥")
+	}
+	if key.Data[3] != sig.Algorithm || KeyTag(key.Data) != sig.KeyTag || !strings.EqualFold(key.Name, sig.Signer) {
+		return errors.New("DNSKEY与RRSIG不对应")
+	}
+	// 按序列号算术（RFC 1982）比较时间，兼容2106年之后的回绕
+	t := uint32(now.Unix())
+	if int32(t-sig.Inception) < 0 {
+		return fmt.Errorf("签名尚未生效（%s）", dns.TimeToString(sig.Inception))
+	}
+	if int32(sig.Expiration-t) < 0 {
+		return fmt.Errorf("签名已过期（%s）", dns.TimeToString(sig.Expiration))
+	}
+	data, err := sig.signedData(rrset)
+	if err != nil {
+		return err
+	}
+	return verifySignature(sig.Algorithm, key.Data[4:], data, sig.Signature)
+}
+
+// signedData 返回被签名的数据：RRSIG记录数据（不含签名）和规范形式、规范顺序的记录集合（RFC 4034 第3.1.8.1节）
+func (sig RRSIG) signedData(rrset []RR) ([]byte, error) {
+	owner := rrset[0].Name
+	var labels []string
+	if trimmed := strings.TrimSuffix(strings.ToLower(owner), "."); trimmed != "" {
+		labels = strings.Split(trimmed, ".")
+	}
+	if len(labels) > 0 && labels[0] == "*" {
+		labels = labels[1:]
+	}
+	if int(sig.Labels) > len(labels) {
+		return nil, errors.New("RRSIG的标签数大于域名的标签数")
+	}
+	if int(sig.Labels) < len(labels) {
+		// 通配符展开的记录，按通配符域名计算签名
+		owner = "*." + strings.Join(labels[len(labels)-int(sig.Labels):], ".")
+	}
+	name, err := canonicalName(owner)
+	if err != nil {
+		return nil, err
+	}
+
+	rdatas := make([][]byte, 0, len(rrset))
+	for _, rr := range rrset {
+		rdatas = append(rdatas, canonicalRdata(rr))
+	}
+	sort.Slice(rdatas, func(i, j int) bool { return bytes.Compare(rdatas[i], rdatas[j]) < 0 })
+
+	data := append([]byte(nil), sig.header...)
+	for i, rdata := range rdatas {
+		if i > 0 && bytes.Equal(rdata, rdatas[i-1]) {
+			continue
+		}
+		data = append(data, name...)
+		data = binary.BigEndian.AppendUint16(data, rrset[0].Type)
+		data = binary.BigEndian.AppendUint16(data, rrset[0].Class)
+		data = binary.BigEndian.AppendUint32(data, sig.OriginalTTL)
+		data = binary.BigEndian.AppendUint16(data, uint16(len(rdata)))
+		data = append(data, rdata...)
+	}
+	return data, nil
+}
+
+// labelCount 返回域名的标签数，不含根和开头的通配符标签（RFC 4034 第3.1.3节）
+func labelCount(name string) int {
+	name = strings.TrimSuffix(name, ".")
+	if name == "" {
+		return 0
+	}
+	n := strings.Count(name, ".") + 1
+	if name == "*" || strings.HasPrefix(name, "*.") {
+		n--
+	}
+	return n
+}
+
+// canonicalName 返回小写的线上格式域名
+func canonicalName(name string) ([]byte, error) {
+	b, err := packName(nil, name)
+	if err != nil {
+		return nil, err
+	}
+	lowerName(b, 0)
+	return b, nil
+}
+
+// canonicalRdata 返回记录数据的规范形式，其中的域名转为小写（RFC 4034 第6.2节，RFC 6840 第5.1节）
+func canonicalRdata(rr RR) []byte {
+	data := append([]byte(nil), rr.Data...)
+	prefix, names := rdataNames(rr.Type)
+	off := prefix
+	for i := 0; i < names && off < len(data); i++ {
+		off = lowerName(data, off)
+	}
+	return data
+}
+
+// lowerName 将 b 中 off 处未压缩的线上格式域名转为小写，返回域名之后的位置
+func lowerName(b []byte, off int) int {
+	for off < len(b) {
+		n := int(b[off])
+		off++
+		if n == 0 || off+n > len(b) {
+			break
+		}
+		for i := off; i < off+n; i++ {
+			if b[i] >= 'A' && b[i] <= 'Z' {
+				b[i] += 'a' - 'A'
+			}
+		}
+		off += n
+	}
+	return off
+}
+
+// algorithmSupported 判断是否支持该签名算法
+func algorithmSupported(alg uint8) bool {
+	switch alg {
+	case algRSASHA1, algRSASHA1NSEC3SHA1, algRSASHA256, algRSASHA512, algECDSAP256SHA256, algECDSAP384SHA384, algED25519:
+		return true
+	}
+	return false
+}
+
+// verifySignature 用DNSKEY中的公钥校验签名
+func verifySignature(alg uint8, key, data, sig []byte) error {
+	invalid := errors.New("签名校验失败")
+	switch alg {
+	case algRSASHA1, algRSASHA1NSEC3SHA1, algRSASHA256, algRSASHA512:
+		pub, err := rsaPublicKey(key)
+		if err != nil {
+			return err
+		}
+		hash := crypto.SHA256
+		switch alg {
+		case algRSASHA1, algRSASHA1NSEC3SHA1:
+			hash = crypto.SHA1
+		case algRSASHA512:
+			hash = crypto.SHA512
+		}
+		h := hash.New()
+		h.Write(data)
+		if rsa.VerifyPKCS1v15(pub, hash, h.Sum(nil), sig) != nil {
+			return invalid
+		}
+		return nil
+
+	case algECDSAP256SHA256, algECDSAP384SHA384:
+		curve, hash, size := elliptic.P256(), crypto.SHA256, 32
+		if alg == algECDSAP384SHA384 {
+			curve, hash, size = elliptic.P384(), crypto.SHA384, 48
+		}
+		if len(key) != 2*size || len(sig) != 2*size {
+			return errors.New("ECDSA密钥或签名长度错误")
+		}
+		pub := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(key[:size]), Y: new(big.Int).SetBytes(key[size:])}
+		h := hash.New()
+		h.Write(data)
+		if !ecdsa.Verify(pub, h.Sum(nil), new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])) {
+			return invalid
+		}
+		return nil
+
+	case algED25519:
+		if len(key) != ed25519.PublicKeySize || len(sig) != ed25519.SignatureSize {
+			return errors.New("Ed25519密钥或签名长度错误")
+		}
+		if !ed25519.Verify(ed25519.PublicKey(key), data, sig) {
+			return invalid
+		}
+		return nil
+	}
+	return errUnsupported
+}
+
+// rsaPublicKey 解析DNSKEY中的RSA公钥（RFC 3110 第2节）
+func rsaPublicKey(key []byte) (*rsa.PublicKey, error) {
+	malformed := errors.New("RSA公钥格式错误")
+	if len(key) < 1 {
+		return nil, malformed
+	}
+	expLen, off := int(key[0]), 1
+	if expLen == 0 {
+		if len(key) < 3 {
+			return nil, malformed
+		}
+		expLen, off = int(binary.BigEndian.Uint16(key[1:])), 3
+	}
+	if expLen == 0 || expLen > 4 || len(key) <= off+expLen {
+		return nil, malformed
+	}
+	exp := 0
+	for _, b := range key[off : off+expLen] {
+		exp = exp<<8 | int(b)
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(key[off+expLen:]), E: exp}, nil
+}
+
+// nsec3Hash 计算域名的NSEC3散列（RFC 5155 第5节），结果为 base32hex 编码
+func nsec3Hash(name string, iterations uint16, salt []byte) (string, error) {
+	wire, err := canonicalName(name)
+	if err != nil {
+		return "", err
+	}
+	h := sha1.Sum(append(wire, salt...))
+	for i := 0; i < int(iterations); i++ {
+		h = sha1.Sum(append(h[:], salt...))
+	}
+	return base32.HexEncoding.WithPadding(base32.NoPadding).EncodeToString(h[:]), nil
+}
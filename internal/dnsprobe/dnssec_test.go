@@ -0,0 +1,479 @@
+package dnsprobe
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 固定的测试私钥（PKCS#1 / SEC 1 DER），仅用于测试
+const (
+	testRSAKey  = "MIICXgIBAAKBgQDGie12P/lQD3wJQzBfu5k90NT4+s5HiHeZN+2aeJ7nMTG8Dfr/wXZls1t7Jdi1wQrl+h9nJpfgRPAxqbpXrwjh0dBgoFw/w3Y0W6QF9p/ywSgctYVINBdhKubg3hcGtfUfXEyEE2RHCsfjqkAi3J9ECCf/iOrMfE+AtkEtKLNsDQIDAQABAoGAGud7/2dB8MB4bYTmXEK+zGqtQaQzKexrUJ8Mmr83wVqgQ7ydQ+VClxuMDQ8e49pqEKopcUAAmsqcxg4bC2DT0hESzZcsgakXC+W7sRDXY2waNRTdRSwqLKe3T8mSds8eSB2acQugYcZDj3wMSLpYLT+4qmLu5m0SuIyn68xK4cECQQDjuTF4tRgpqTPm8YD0DEzMZQtVGOUN83PZTehEQ+e5MS2pWGcWgRqUrwGsmabFQCmCnCoQwSLtxYDSt4O7LkeRAkEA3zEFiAr7gl/A0LhvQp019F59eHQrTSGgTMjdXTJ+cN9K+aWLeW4wGoI3/SNdFe7W9RqL1OLp+5T+NMEyFXA2vQJBAN10erTNP+s73fqH/4TV9VWMKiIebJpNl+rKhcblVpLVTXdzPgU3lhbjvjgQ0IrgY73dilbyy7n5KNdCmfvM/NECQQDLSg5cvIwbUvX/5o3IogMb4tjr1vv91Cv65FXkZnKOgoP64ZMpdniwHH28vvIxYQs/0v8cV9aBRVNJX9MrfbsNAkEAr4j/IDanlN2w+IwAAnYMxy6EAlg7n5QkI7CcSlkDEldqsSzl1hWWwkJZMVWX57k92yVUzDOnKyvHsuVC99xutQ=="
+	testP256Key = "MHcCAQEEID0S658AxATYmYQIoIIpMEi9qGY6I7TPte4JYxurIlksoAoGCCqGSM49AwEHoUQDQgAEK8P7djhSXXmR8hNWI6NXJrwWY2MO00YleLKYv61ZJkw6vQ2kiw8+ve9QSsXU5WCExgfIUIqBFZBo0LeovNcvrQ=="
+	testP384Key = "MIGkAgEBBDDPNWtSDNu8eEIB04vb6w9VVBxiFOPEin9Mwz+MTXKRO44gEOLMG8R1VusAD66BySCgBwYFK4EEACKhZANiAATkjiR4hufTXiBMBU/5NxISmAhPpx/6dSb/AGt/TfFV04d2eRYMvquV/5a8KEVHIZ0SkJ8uJuxYNKVjK3ESKq4TUvaWZn5NPtYeVxFhBQy9x50SrLEDKVS9h6vW+HkPmxE="
+)
+
+// testNow 测试中的校验时间
+var testNow = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// testKey 测试用的区域密钥
+type testKey struct {
+	rr   RR // DNSKEY记录
+	alg  uint8
+	priv crypto.Signer
+}
+
+// newTestKey 用固定的私钥生成 zone 的DNSKEY；Ed25519 的种子由区域名称导出，各区域的密钥不同
+func newTestKey(t testing.TB, zone string, flags uint16, alg uint8) *testKey {
+	t.Helper()
+	k := &testKey{alg: alg}
+	var pub []byte
+	switch alg {
+	case algRSASHA1, algRSASHA256, algRSASHA512:
+		der, _ := base64.StdEncoding.DecodeString(testRSAKey)
+		priv, err := x509.ParsePKCS1PrivateKey(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		k.priv = priv
+		pub = append([]byte{3, 1, 0, 1}, priv.N.Bytes()...)
+	case algECDSAP256SHA256, algECDSAP384SHA384:
+		key, size := testP256Key, 32
+		if alg == algECDSAP384SHA384 {
+			key, size = testP384Key, 48
+		}
+		der, _ := base64.StdEncoding.DecodeString(key)
+		priv, err := x509.ParseECPrivateKey(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		k.priv = priv
+		pub = append(priv.X.FillBytes(make([]byte, size)), priv.Y.FillBytes(make([]byte, size))...)
+	case algED25519:
+		seed := sha256.Sum256([]byte(zone))
+		priv := ed25519.NewKeyFromSeed(seed[:])
+		k.priv = priv
+		pub = priv.Public().(ed25519.PublicKey)
+	default:
+		t.Fatalf("unsupported test algorithm %d", alg)
+	}
+	data := binary.BigEndian.AppendUint16(nil, flags)
+	data = append(append(data, 3, alg), pub...)
+	k.rr = RR{Name: zone, Type: TypeDNSKEY, Class: ClassIN, TTL: 3600, Data: data}
+	return k
+}
+
+// signData 按算法对数据签名，返回RRSIG中的签名字段
+func (k *testKey) signData(t testing.TB, data []byte) []byte {
+	t.Helper()
+	switch priv := k.priv.(type) {
+	case *rsa.PrivateKey:
+		hash := crypto.SHA256
+		switch k.alg {
+		case algRSASHA1:
+			hash = crypto.SHA1
+		case algRSASHA512:
+			hash = crypto.SHA512
+		}
+		h := hash.New()
+		h.Write(data)
+		sig, err := rsa.SignPKCS1v15(nil, priv, hash, h.Sum(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sig
+	case *ecdsa.PrivateKey:
+		hash, size := crypto.SHA256, 32
+		if k.alg == algECDSAP384SHA384 {
+			hash, size = crypto.SHA384, 48
+		}
+		h := hash.New()
+		h.Write(data)
+		r, s, err := ecdsa.Sign(rand.Reader, priv, h.Sum(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return append(r.FillBytes(make([]byte, size)), s.FillBytes(make([]byte, size))...)
+	case ed25519.PrivateKey:
+		return ed25519.Sign(priv, data)
+	}
+	t.Fatal("unknown key type")
+	return nil
+}
+
+// sign 返回 rrset 在 [inception, expiration] 内有效的RRSIG
+func (k *testKey) sign(t testing.TB, rrset []RR, inception, expiration time.Time) RR {
+	t.Helper()
+	d := binary.BigEndian.AppendUint16(nil, rrset[0].Type)
+	d = append(d, k.alg, uint8(labelCount(rrset[0].Name)))
+	d = binary.BigEndian.AppendUint32(d, rrset[0].TTL)
+	d = binary.BigEndian.AppendUint32(d, uint32(expiration.Unix()))
+	d = binary.BigEndian.AppendUint32(d, uint32(inception.Unix()))
+	d = binary.BigEndian.AppendUint16(d, KeyTag(k.rr.Data))
+	d, err := packName(d, k.rr.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := RR{Name: rrset[0].Name, Type: TypeRRSIG, Class: ClassIN, TTL: rrset[0].TTL, Data: d}
+	sig, err := parseRRSIG(rr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := sig.signedData(rrset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr.Data = append(rr.Data, k.signData(t, data)...)
+	return rr
+}
+
+// signNow 返回在 testNow 前后一天内有效的RRSIG
+func (k *testKey) signNow(t testing.TB, rrset []RR) RR {
+	return k.sign(t, rrset, testNow.Add(-24*time.Hour), testNow.Add(24*time.Hour))
+}
+
+// ds 返回该密钥的SHA-256 DS记录
+func (k *testKey) ds(t testing.TB) RR {
+	t.Helper()
+	owner, err := canonicalName(k.rr.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(append(owner, k.rr.Data...))
+	data := binary.BigEndian.AppendUint16(nil, KeyTag(k.rr.Data))
+	data = append(append(data, k.alg, digestSHA256), digest[:]...)
+	return RR{Name: k.rr.Name, Type: TypeDS, Class: ClassIN, TTL: 3600, Data: data}
+}
+
+func aRecords(name string, ips ...[4]byte) []RR {
+	rrs := make([]RR, 0, len(ips))
+	for _, ip := range ips {
+		rrs = append(rrs, RR{Name: name, Type: TypeA, Class: ClassIN, TTL: 300, Data: ip[:]})
+	}
+	return rrs
+}
+
+func TestRRSIGVerify(t *testing.T) {
+	algs := []uint8{algRSASHA1, algRSASHA256, algRSASHA512, algECDSAP256SHA256, algECDSAP384SHA384, algED25519}
+	tests := []struct {
+		name                  string
+		inception, expiration time.Duration // 相对 testNow
+		mutate                func(rrs []RR) []RR
+		wantErr               string
+	}{
+		{name: "valid", inception: -time.Hour, expiration: time.Hour},
+		{name: "expired", inception: -2 * time.Hour, expiration: -time.Hour, wantErr: "已过期"},
+		{name: "not yet valid", inception: time.Hour, expiration: 2 * time.Hour, wantErr: "尚未生效"},
+		{name: "reordered", inception: -time.Hour, expiration: time.Hour, mutate: func(rrs []RR) []RR {
+			return []RR{rrs[1], rrs[0]}
+		}},
+		{name: "owner case", inception: -time.Hour, expiration: time.Hour, mutate: func(rrs []RR) []RR {
+			return aRecords("WWW.Example.", [4]byte{192, 0, 2, 1}, [4]byte{192, 0, 2, 2})
+		}},
+		{name: "tampered", inception: -time.Hour, expiration: time.Hour, wantErr: "签名校验失败", mutate: func(rrs []RR) []RR {
+			return aRecords("www.example.", [4]byte{192, 0, 2, 1}, [4]byte{192, 0, 2, 3})
+		}},
+		{name: "missing record", inception: -time.Hour, expiration: time.Hour, wantErr: "签名校验失败", mutate: func(rrs []RR) []RR {
+			return rrs[:1]
+		}},
+	}
+	for _, alg := range algs {
+		key := newTestKey(t, "example.", 256, alg)
+		for _, tt := range tests {
+			t.Run(strconv.Itoa(int(alg))+"/"+tt.name, func(t *testing.T) {
+				rrs := aRecords("www.example.", [4]byte{192, 0, 2, 1}, [4]byte{192, 0, 2, 2})
+				rr := key.sign(t, rrs, testNow.Add(tt.inception), testNow.Add(tt.expiration))
+				if tt.mutate != nil {
+					rrs = tt.mutate(rrs)
+				}
+				sig, err := parseRRSIG(rr)
+				if err != nil {
+					t.Fatal(err)
+				}
+				err = sig.verify(rrs, key.rr, testNow)
+				if tt.wantErr == "" && err != nil {
+					t.Errorf("verify: %v", err)
+				}
+				if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+					t.Errorf("verify error = %v, want %q", err, tt.wantErr)
+				}
+			})
+		}
+	}
+}
+
+func TestRRSIGVerifyKeyMismatch(t *testing.T) {
+	key := newTestKey(t, "example.", 256, algED25519)
+	rrs := aRecords("www.example.", [4]byte{192, 0, 2, 1})
+	sig, err := parseRRSIG(key.signNow(t, rrs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		key  RR
+	}{
+		{"other zone", newTestKey(t, "example.net.", 256, algED25519).rr},
+		{"other algorithm", newTestKey(t, "example.", 256, algECDSAP256SHA256).rr},
+		{"not a zone key", newTestKey(t, "example.", 0, algED25519).rr},
+		{"truncated", RR{Name: "example.", Type: TypeDNSKEY, Data: []byte{1, 0, 3}}},
+	}
+	for _, tt := range tests {
+		if err := sig.verify(rrs, tt.key, testNow); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
+
+func TestRRSIGVerifyWildcard(t *testing.T) {
+	key := newTestKey(t, "example.", 256, algED25519)
+	rr := key.signNow(t, aRecords("*.example.", [4]byte{192, 0, 2, 1}))
+
+	// 通配符展开后的记录：RRSIG的标签数为1，按 *.example. 校验
+	expanded := aRecords("a.b.example.", [4]byte{192, 0, 2, 1})
+	rr.Name = "a.b.example."
+	sig, err := parseRRSIG(rr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sig.Labels != 1 {
+		t.Fatalf("labels = %d, want 1", sig.Labels)
+	}
+	if err := sig.verify(expanded, key.rr, testNow); err != nil {
+		t.Errorf("verify expanded wildcard: %v", err)
+	}
+
+	// 标签数大于域名的标签数
+	sig.Labels = 4
+	if err := sig.verify(expanded, key.rr, testNow); err == nil {
+		t.Error("expected error for labels larger than owner")
+	}
+}
+
+func TestVerifySignatureMalformed(t *testing.T) {
+	tests := []struct {
+		name     string
+		alg      uint8
+		key, sig []byte
+	}{
+		{"rsa empty key", algRSASHA256, nil, make([]byte, 128)},
+		{"rsa long exponent", algRSASHA256, []byte{5, 1, 2, 3, 4, 5, 6}, make([]byte, 128)},
+		{"rsa no modulus", algRSASHA256, []byte{3, 1, 0, 1}, make([]byte, 128)},
+		{"ecdsa short key", algECDSAP256SHA256, make([]byte, 63), make([]byte, 64)},
+		{"ecdsa short sig", algECDSAP384SHA384, make([]byte, 96), make([]byte, 95)},
+		{"ed25519 short sig", algED25519, make([]byte, ed25519.PublicKeySize), make([]byte, 10)},
+		{"unsupported", 253, nil, nil},
+	}
+	for _, tt := range tests {
+		if err := verifySignature(tt.alg, tt.key, []byte("data"), tt.sig); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+	if err := verifySignature(253, nil, nil, nil); err != errUnsupported {
+		t.Errorf("unsupported algorithm error = %v", err)
+	}
+}
+
+// dskeyExample RFC 4034 第5.4节示例中的 dskey.example.com. DNSKEY（密钥标签 60485）
+const dskeyExample = "AQOeiiR0GOMYkDshWoSKz9XzfwJr1AYtsmx3TGkJaNXVbfi/2pHm822aJ5iI9BMzNXxeYCmZDRD99WYwYqUSdjMmmAphXdvxegXd/M5+X7OrzKBaMbCVdFLUUh6DhweJBjEVv5f2wwjM9XzcnOf+EPbtG9DMBmADjFDc2w/rljwvFw=="
+
+func TestDSMatches(t *testing.T) {
+	key := RR{Name: "dskey.example.com.", Type: TypeDNSKEY, Class: ClassIN, Data: dnskey(256, 3, algRSASHA1, dskeyExample)}
+	digest := func(s string) []byte {
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	sha1Digest := digest("2BB183AF5F22588179A53B0A98631FAD1A292118")
+	sha256Digest := digest("D4B7D520E7BB5F0F67674A0CCEB1E3E0614B93C4F9E99B8383F6A1E4469DA50A")
+
+	tests := []struct {
+		name string
+		key  RR
+		ds   DS
+		want bool
+	}{
+		// RFC 4034 第5.4节
+		{"sha1", key, DS{KeyTag: 60485, Algorithm: algRSASHA1, DigestType: digestSHA1, Digest: sha1Digest}, true},
+		// RFC 4509 第2.3节
+		{"sha256", key, DS{KeyTag: 60485, Algorithm: algRSASHA1, DigestType: digestSHA256, Digest: sha256Digest}, true},
+		{"owner case", RR{Name: "DSKEY.Example.COM.", Data: key.Data}, DS{KeyTag: 60485, Algorithm: algRSASHA1, DigestType: digestSHA256, Digest: sha256Digest}, true},
+		{"wrong owner", RR{Name: "other.example.com.", Data: key.Data}, DS{KeyTag: 60485, Algorithm: algRSASHA1, DigestType: digestSHA256, Digest: sha256Digest}, false},
+		{"wrong key tag", key, DS{KeyTag: 60486, Algorithm: algRSASHA1, DigestType: digestSHA256, Digest: sha256Digest}, false},
+		{"wrong algorithm", key, DS{KeyTag: 60485, Algorithm: algRSASHA256, DigestType: digestSHA256, Digest: sha256Digest}, false},
+		{"digest type mismatch", key, DS{KeyTag: 60485, Algorithm: algRSASHA1, DigestType: digestSHA384, Digest: sha256Digest}, false},
+		{"unknown digest type", key, DS{KeyTag: 60485, Algorithm: algRSASHA1, DigestType: 3, Digest: sha256Digest}, false},
+		{"truncated key", RR{Name: key.Name, Data: key.Data[:3]}, DS{KeyTag: 60485, Algorithm: algRSASHA1, DigestType: digestSHA1, Digest: sha1Digest}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.ds.matches(tt.key); got != tt.want {
+			t.Errorf("%s: matches = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDSSupported(t *testing.T) {
+	tests := []struct {
+		alg, digestType uint8
+		want            bool
+	}{
+		{algRSASHA256, digestSHA256, true},
+		{algECDSAP384SHA384, digestSHA384, true},
+		{algED25519, digestSHA1, true},
+		{12, digestSHA256, false}, // GOST
+		{algRSASHA256, 3, false},  // GOST 摘要
+		{253, digestSHA256, false},
+	}
+	for _, tt := range tests {
+		if got := (DS{Algorithm: tt.alg, DigestType: tt.digestType}).supported(); got != tt.want {
+			t.Errorf("DS{%d %d}.supported() = %v, want %v", tt.alg, tt.digestType, got, tt.want)
+		}
+	}
+}
+
+func TestNSEC3Hash(t *testing.T) {
+	// RFC 5155 附录A
+	salt := []byte{0xaa, 0xbb, 0xcc, 0xdd}
+	tests := []struct {
+		name, want string
+	}{
+		{"example", "0P9MHAVEQVM6T7VBL5LOP2U3T2RP3TOM"},
+		{"a.example", "35MTHGPGCU1QG68FAB165KLNSNK3DPVL"},
+		{"A.EXAMPLE.", "35MTHGPGCU1QG68FAB165KLNSNK3DPVL"},
+	}
+	for _, tt := range tests {
+		got, err := nsec3Hash(tt.name, 12, salt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("nsec3Hash(%q) = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+// bitmap 编码类型位图（只用窗口0）
+func bitmap(types ...uint16) []byte {
+	var bits [32]byte
+	n := 0
+	for _, t := range types {
+		bits[t/8] |= 0x80 >> (t % 8)
+		n = max(n, int(t/8)+1)
+	}
+	if n == 0 {
+		// 没有类型时不能有空的窗口（RFC 4034 第4.1.2节）
+		return nil
+	}
+	return append([]byte{0, byte(n)}, bits[:n]...)
+}
+
+func nsecRR(owner, next string, types ...uint16) RR {
+	data, _ := packName(nil, next)
+	return RR{Name: owner, Type: TypeNSEC, Class: ClassIN, TTL: 300, Data: append(data, bitmap(types...)...)}
+}
+
+var nsec3Encoding = base32.HexEncoding.WithPadding(base32.NoPadding)
+
+// nsec3RR 构造 NSEC3 记录：owner 和 next 为原始散列值
+func nsec3RR(zone string, owner, next []byte, optOut bool, types ...uint16) RR {
+	flags := byte(0)
+	if optOut {
+		flags = 1
+	}
+	data := []byte{1, flags, 0, 0, 0, byte(len(next))} // SHA-1，0次迭代，无盐
+	data = append(append(data, next...), bitmap(types...)...)
+	return RR{Name: nsec3Encoding.EncodeToString(owner) + "." + zone, Type: TypeNSEC3, Class: ClassIN, TTL: 300, Data: data}
+}
+
+// addHash 返回散列值加上 delta 的结果（按大端整数，溢出时回绕）
+func addHash(h []byte, delta int) []byte {
+	out := append([]byte(nil), h...)
+	carry := delta
+	for i := len(out) - 1; i >= 0 && carry != 0; i-- {
+		v := int(out[i]) + carry
+		out[i] = byte(v)
+		carry = v >> 8
+	}
+	return out
+}
+
+func TestDenialCovers(t *testing.T) {
+	hashStr, err := nsec3Hash("sub.example.", 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := nsec3Encoding.DecodeString(hashStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	low := make([]byte, len(hash))
+	high := bytes.Repeat([]byte{0xff}, len(hash))
+
+	tests := []struct {
+		name string
+		rr   RR
+		want bool
+	}{
+		// NSEC：该名称处有NS没有DS
+		{"nsec insecure delegation", nsecRR("sub.example.", "z.example.", TypeNS, TypeRRSIG, TypeNSEC), true},
+		{"nsec owner case", nsecRR("SUB.example.", "z.example.", TypeNS, TypeRRSIG, TypeNSEC), true},
+		{"nsec has DS", nsecRR("sub.example.", "z.example.", TypeNS, TypeDS, TypeRRSIG, TypeNSEC), false},
+		{"nsec not a delegation", nsecRR("sub.example.", "z.example.", TypeA, TypeRRSIG, TypeNSEC), false},
+		{"nsec other name", nsecRR("a.example.", "z.example.", TypeNS, TypeRRSIG, TypeNSEC), false},
+
+		// NSEC3 精确匹配
+		{"nsec3 match", nsec3RR("example.", hash, addHash(hash, 1), false, TypeNS), true},
+		{"nsec3 match has DS", nsec3RR("example.", hash, addHash(hash, 1), false, TypeNS, TypeDS), false},
+		{"nsec3 match without NS", nsec3RR("example.", hash, addHash(hash, 1), false, TypeA), false},
+		{"nsec3 other zone", nsec3RR("example.net.", hash, addHash(hash, 1), false, TypeNS), false},
+
+		// NSEC3 覆盖：只有设置 opt-out 时才能证明
+		{"nsec3 covers opt-out", nsec3RR("example.", addHash(hash, -1), addHash(hash, 1), true), true},
+		{"nsec3 covers without opt-out", nsec3RR("example.", addHash(hash, -1), addHash(hash, 1), false), false},
+		{"nsec3 does not cover", nsec3RR("example.", addHash(hash, 1), addHash(hash, 2), true), false},
+		{"nsec3 ends at hash", nsec3RR("example.", addHash(hash, -2), hash, true), false},
+
+		// 区域中最后一条NSEC3：next 回绕到最小的散列
+		{"nsec3 wraparound above owner", nsec3RR("example.", addHash(hash, -1), low, true), true},
+		{"nsec3 wraparound below next", nsec3RR("example.", high, addHash(hash, 1), true), true},
+		{"nsec3 wraparound outside", nsec3RR("example.", addHash(hash, 1), addHash(hash, -1), true), false},
+		{"nsec3 single record", nsec3RR("example.", addHash(hash, 1), addHash(hash, 1), true), true},
+
+		// 格式错误
+		{"nsec3 unknown hash algorithm", RR{Name: hashStr + ".example.", Type: TypeNSEC3, Data: []byte{2, 1, 0, 0, 0, 0}}, false},
+		{"nsec3 truncated", RR{Name: hashStr + ".example.", Type: TypeNSEC3, Data: []byte{1, 1, 0, 0, 4, 1}}, false},
+	}
+	for _, tt := range tests {
+		if got := denialCovers(tt.rr, "sub.example.", "example."); got != tt.want {
+			t.Errorf("%s: denialCovers = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCompareNames(t *testing.T) {
+	// RFC 4034 第6.1节中的示例，按规范顺序排列
+	names := []string{"example.", "a.example.", "yljkjljk.a.example.", "Z.a.example.", "zABC.a.EXAMPLE.", "z.example.", "\\001.z.example.", "*.z.example.", "\\200.z.example."}
+	for i := range names {
+		for j := range names {
+			got := compareNames(names[i], names[j])
+			if got < 0 != (i < j) || got == 0 != (i == j) {
+				t.Errorf("compareNames(%q, %q) = %d", names[i], names[j], got)
+			}
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package dnsprobe
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// EDNS 选项代码（IANA DNS EDNS0 Option Codes）
+const (
+	OptionNSID         uint16 = 3  // RFC 5001
+	OptionClientSubnet uint16 = 8  // RFC 7871
+	OptionExpire       uint16 = 9  // RFC 7314
+	OptionCookie       uint16 = 10 // RFC 7873
+	OptionKeepalive    uint16 = 11 // RFC 7828
+	OptionPadding      uint16 = 12 // RFC 7830
+	OptionEDE          uint16 = 15 // RFC 8914
+)
+
+var optionNames = map[uint16]string{
+	OptionNSID:         "NSID",
+	OptionClientSubnet: "CLIENT-SUBNET",
+	OptionExpire:       "EXPIRE",
+	OptionCookie:       "COOKIE",
+	OptionKeepalive:    "TCP-KEEPALIVE",
+	OptionPadding:      "PADDING",
+	OptionEDE:          "EDE",
+}
+
+// OptionString 返回EDNS选项的名称，未知的返回 OPTnnn
+func OptionString(code uint16) string {
+	if name, ok := optionNames[code]; ok {
+		return name
+	}
+	return "OPT" + strconv.Itoa(int(code))
+}
+
+// ClientSubnet 解析后的 EDNS Client Subnet 选项
+type ClientSubnet struct {
+	Address      net.IP
+	SourcePrefix uint8
+	ScopePrefix  uint8
+}
+
+// ParseClientSubnet 解析 ecs 参数：前缀（如 1.2.3.0/24、2001:db8::/48）或单个IP（IPv4 取 /24，IPv6 取 /56），
+// IPv4映射的IPv6地址按IPv4处理
+func ParseClientSubnet(s string) (*ClientSubnet, error) {
+	s = strings.TrimSpace(s)
+	addr, prefix, hasPrefix := strings.Cut(s, "/")
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return nil, fmt.Errorf("无效的ECS地址: %s", s)
+	}
+	bits, ones := net.IPv6len*8, 56
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits, ones = ip4, net.IPv4len*8, 24
+	}
+	if hasPrefix {
+		n, err := strconv.Atoi(prefix)
+		if err != nil || n < 0 || n > bits {
+			return nil, fmt.Errorf("无效的ECS前缀长度: %s", s)
+		}
+		ones = n
+	}
+	return &ClientSubnet{Address: ip.Mask(net.CIDRMask(ones, bits)), SourcePrefix: uint8(ones)}, nil
+}
+
+// String 返回 地址/源前缀 形式
+func (c *ClientSubnet) String() string {
+	return fmt.Sprintf("%s/%d", c.Address, c.SourcePrefix)
+}
+
+// Option 编码为EDNS选项：地址只保留前缀覆盖的字节（RFC 7871 第6节），前缀超过地址长度时截断
+func (c *ClientSubnet) Option() EDNSOption {
+	family := uint16(2)
+	addr := c.Address.To16()
+	if ip4 := c.Address.To4(); ip4 != nil {
+		family, addr = 1, ip4
+	}
+	prefix := min(int(c.SourcePrefix), len(addr)*8)
+	data := binary.BigEndian.AppendUint16(nil, family)
+	data = append(data, uint8(prefix), c.ScopePrefix)
+	data = append(data, addr[:(prefix+7)/8]...)
+	return EDNSOption{Code: OptionClientSubnet, Data: data}
+}
+
+// parseClientSubnetOption 解析应答中的ECS选项
+func parseClientSubnetOption(data []byte) (*ClientSubnet, error) {
+	if len(data) < 4 {
+		return nil, errors.New("ECS选项长度错误")
+	}
+	size := net.IPv4len
+	switch binary.BigEndian.Uint16(data) {
+	case 1:
+	case 2:
+		size = net.IPv6len
+	default:
+		return nil, errors.New("ECS选项的地址族未知")
+	}
+	addr := data[4:]
+	if len(addr) > size {
+		return nil, errors.New("ECS选项长度错误")
+	}
+	ip := make(net.IP, size)
+	copy(ip, addr)
+	return &ClientSubnet{Address: ip, SourcePrefix: data[2], ScopePrefix: data[3]}, nil
+}
+
+// NewCookie 返回 cookie 参数对应的选项：true 时生成随机的8字节客户端cookie，
+// 字符串为十六进制的客户端cookie（8字节）或客户端加服务器cookie（16-40字节）
+func NewCookie(v interface{}) (*EDNSOption, error) {
+	switch c := v.(type) {
+	case bool:
+		if !c {
+			return nil, nil
+		}
+		data := make([]byte, 8)
+		rand.Read(data)
+		return &EDNSOption{Code: OptionCookie, Data: data}, nil
+	case string:
+		data, err := hex.DecodeString(strings.TrimSpace(c))
+		if err != nil || len(data) != 8 && (len(data) < 16 || len(data) > 40) {
+			return nil, fmt.Errorf("无效的cookie: %s", c)
+		}
+		return &EDNSOption{Code: OptionCookie, Data: data}, nil
+	}
+	return nil, nil
+}
+
+// ParseOption 解析 ednsopt 参数中的一项：code 或 code:十六进制数据，与 dig +ednsopt 相同
+func ParseOption(s string) (EDNSOption, error) {
+	codeStr, value, _ := strings.Cut(strings.TrimSpace(s), ":")
+	code, err := strconv.ParseUint(codeStr, 10, 16)
+	if err != nil {
+		return EDNSOption{}, fmt.Errorf("无效的EDNS选项: %s", s)
+	}
+	data, err := hex.DecodeString(value)
+	if err != nil {
+		return EDNSOption{}, fmt.Errorf("无效的EDNS选项: %s", s)
+	}
+	return EDNSOption{Code: uint16(code), Data: data}, nil
+}
+
+// optionMap 返回一个选项的结构化结果，已知选项另有解析后的字段
+func optionMap(opt EDNSOption) map[string]interface{} {
+	m := map[string]interface{}{
+		"code": opt.Code,
+		"name": OptionString(opt.Code),
+		"data": hex.EncodeToString(opt.Data),
+	}
+	switch opt.Code {
+	case OptionNSID:
+		m["text"] = printable(opt.Data)
+	case OptionClientSubnet:
+		if ecs, err := parseClientSubnetOption(opt.Data); err == nil {
+			m["address"] = ecs.Address.String()
+			m["source_prefix"] = ecs.SourcePrefix
+			m["scope_prefix"] = ecs.ScopePrefix
+		}
+	case OptionCookie:
+		if len(opt.Data) >= 8 {
+			m["client"] = hex.EncodeToString(opt.Data[:8])
+			m["server"] = hex.EncodeToString(opt.Data[8:])
+		}
+	case OptionEDE:
+		if len(opt.Data) >= 2 {
+			m["info_code"] = binary.BigEndian.Uint16(opt.Data)
+			m["extra_text"] = string(opt.Data[2:])
+		}
+	}
+	return m
+}
+
+// optionText 返回选项在 dig 风格输出中的文本
+func optionText(opt EDNSOption) string {
+	switch opt.Code {
+	case OptionNSID:
+		return fmt.Sprintf("%s (\"%s\")", strings.ToUpper(hex.EncodeToString(opt.Data)), printable(opt.Data))
+	case OptionClientSubnet:
+		if ecs, err := parseClientSubnetOption(opt.Data); err == nil {
+			return fmt.Sprintf("%s/%d/%d", ecs.Address, ecs.SourcePrefix, ecs.ScopePrefix)
+		}
+	case OptionEDE:
+		if len(opt.Data) >= 2 {
+			return fmt.Sprintf("%d (%s)", binary.BigEndian.Uint16(opt.Data), string(opt.Data[2:]))
+		}
+	}
+	return hex.EncodeToString(opt.Data)
+}
+
+// printable 将NSID等数据转换为可读文本，不可打印的字符替换为点
+func printable(data []byte) string {
+	var sb strings.Builder
+	for _, b := range data {
+		if b < 0x80 && unicode.IsPrint(rune(b)) {
+			sb.WriteByte(b)
+		} else {
+			sb.WriteByte('.')
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,127 @@
+package dnsprobe
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestClientSubnetRoundTrip(t *testing.T) {
+	tests := []struct {
+		in       string
+		want     string
+		wantData []byte
+	}{
+		{"1.2.3.4", "1.2.3.0/24", []byte{0, 1, 24, 0, 1, 2, 3}},
+		{"10.0.0.0/8", "10.0.0.0/8", []byte{0, 1, 8, 0, 10}},
+		{"0.0.0.0/0", "0.0.0.0/0", []byte{0, 1, 0, 0}},
+		{"2001:db8::1", "2001:db8::/56", []byte{0, 2, 56, 0, 0x20, 0x01, 0x0d, 0xb8, 0, 0, 0}},
+		{"2001:db8:1234::/36", "2001:db8:1000::/36", []byte{0, 2, 36, 0, 0x20, 0x01, 0x0d, 0xb8, 0x10}},
+		// IPv4映射地址按IPv4处理
+		{"::ffff:1.2.3.4", "1.2.3.0/24", []byte{0, 1, 24, 0, 1, 2, 3}},
+		{"::ffff:1.2.3.0/24", "1.2.3.0/24", []byte{0, 1, 24, 0, 1, 2, 3}},
+	}
+	for _, tt := range tests {
+		ecs, err := ParseClientSubnet(tt.in)
+		if err != nil {
+			t.Errorf("ParseClientSubnet(%q): %v", tt.in, err)
+			continue
+		}
+		if ecs.String() != tt.want {
+			t.Errorf("ParseClientSubnet(%q) = %s, want %s", tt.in, ecs, tt.want)
+		}
+		opt := ecs.Option()
+		if opt.Code != OptionClientSubnet || !bytes.Equal(opt.Data, tt.wantData) {
+			t.Errorf("Option(%q) = %v, want %v", tt.in, opt.Data, tt.wantData)
+		}
+
+		// 经过OPT记录编码和解析后内容不变
+		msg := NewQuery("example.com", TypeA, ClassIN, true)
+		msg.EDNS.Options = []EDNSOption{opt}
+		packed, err := msg.Pack()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := Unpack(packed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed, err := parseClientSubnetOption(got.EDNS.Options[0].Data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed.String() != tt.want {
+			t.Errorf("round trip %q = %s, want %s", tt.in, parsed, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"", "example.com", "1.2.3.4/33", "::1/129", "::ffff:1.2.3.0/120", "1.2.3.4/-1", "1.2.3.4/x"} {
+		if _, err := ParseClientSubnet(bad); err == nil {
+			t.Errorf("ParseClientSubnet(%q) expected error", bad)
+		}
+	}
+}
+
+func TestClientSubnetOptionClampsPrefix(t *testing.T) {
+	ecs := &ClientSubnet{Address: net.IPv4(1, 2, 3, 4), SourcePrefix: 120}
+	if got, want := ecs.Option().Data, []byte{0, 1, 32, 0, 1, 2, 3, 4}; !bytes.Equal(got, want) {
+		t.Errorf("Option = %v, want %v", got, want)
+	}
+}
+
+func TestParseClientSubnetOptionInvalid(t *testing.T) {
+	for _, data := range [][]byte{
+		{0, 1, 24},                   // 过短
+		{0, 3, 24, 0, 1, 2, 3},       // 未知地址族
+		{0, 1, 32, 0, 1, 2, 3, 4, 5}, // IPv4 地址超过4字节
+	} {
+		if _, err := parseClientSubnetOption(data); err == nil {
+			t.Errorf("parseClientSubnetOption(%v) expected error", data)
+		}
+	}
+}
+
+func TestParseOption(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    EDNSOption
+		wantErr bool
+	}{
+		{"3", EDNSOption{Code: 3, Data: []byte{}}, false},
+		{"65001:abcd", EDNSOption{Code: 65001, Data: []byte{0xab, 0xcd}}, false},
+		{" 12:00 ", EDNSOption{Code: 12, Data: []byte{0}}, false},
+		{"65536", EDNSOption{}, true},
+		{"x:00", EDNSOption{}, true},
+		{"10:zz", EDNSOption{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseOption(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseOption(%q) expected error", tt.in)
+			}
+			continue
+		}
+		if err != nil || got.Code != tt.want.Code || !bytes.Equal(got.Data, tt.want.Data) {
+			t.Errorf("ParseOption(%q) = %+v, %v; want %+v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestNewCookie(t *testing.T) {
+	opt, err := NewCookie(true)
+	if err != nil || opt == nil || len(opt.Data) != 8 {
+		t.Fatalf("NewCookie(true) = %+v, %v", opt, err)
+	}
+	if opt, err := NewCookie(false); opt != nil || err != nil {
+		t.Errorf("NewCookie(false) = %+v, %v", opt, err)
+	}
+	if opt, err := NewCookie("0102030405060708a1a2a3a4a5a6a7a8"); err != nil || len(opt.Data) != 16 {
+		t.Errorf("NewCookie(client+server) = %+v, %v", opt, err)
+	}
+	for _, bad := range []string{"0102", "0102030405060708a1a2", "zz"} {
+		if _, err := NewCookie(bad); err == nil {
+			t.Errorf("NewCookie(%q) expected error", bad)
+		}
+	}
+}
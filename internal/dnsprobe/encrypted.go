@@ -0,0 +1,185 @@
+package dnsprobe
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"time"
+
+	"github.com/quic-go/quic-go"
+)
+
+// maxMessageSize DNS报文的最大长度
+const maxMessageSize = 65535
+
+func tlsConfig(serverName string, insecure bool, alpn ...string) *tls.Config {
+	return &tls.Config{
+		ServerName:         serverName,
+		NextProtos:         alpn,
+		InsecureSkipVerify: insecure,
+	}
+}
+
+// exchangeTLS 通过 DNS over TLS（RFC 7858）查询
+func exchangeTLS(ctx context.Context, addr string, server Server, query *Message, opts Options) (*Response, error) {
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+	defer conn.Close()
+	stop := watchContext(ctx, conn)
+	defer stop()
+	resp := &Response{Server: addr, Transport: TransportTLS}
+	resp.Timing.Connect = time.Since(start)
+
+	start = time.Now()
+	// RFC 7858 没有定义ALPN，不发送以免严格的服务器拒绝握手
+	tlsConn := tls.Client(conn, tlsConfig(server.Host, opts.Insecure))
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return nil, fmt.Errorf("TLS握手失败: %v", contextError(ctx, err))
+	}
+	resp.Timing.TLS = time.Since(start)
+	state := tlsConn.ConnectionState()
+	resp.TLS = &state
+
+	start = time.Now()
+	resp.Msg, resp.Size, err = exchangeStream(tlsConn, packed, query)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+	resp.Timing.Query = time.Since(start)
+	return resp, nil
+}
+
+// exchangeHTTPS 通过 DNS over HTTPS（RFC 8484，POST application/dns-message）查询，连接固定到已解析的 addr
+func exchangeHTTPS(ctx context.Context, addr string, server Server, query *Message, opts Options) (*Response, error) {
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	hostport := server.Host
+	if server.Port != transportPorts[TransportHTTPS] {
+		hostport = net.JoinHostPort(server.Host, strconv.Itoa(server.Port))
+	} else if ip := net.ParseIP(server.Host); ip != nil && ip.To4() == nil {
+		hostport = "[" + server.Host + "]"
+	}
+	resp := &Response{Server: addr, Transport: TransportHTTPS, URL: "https://" + hostport + server.Path}
+
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "tcp", addr)
+		},
+		TLSClientConfig:   tlsConfig(server.Host, opts.Insecure),
+		ForceAttemptHTTP2: true,
+		DisableKeepAlives: true,
+	}
+	defer transport.CloseIdleConnections()
+
+	var connectStart, tlsStart, wroteRequest time.Time
+	trace := &httptrace.ClientTrace{
+		ConnectStart: func(_, _ string) { connectStart = time.Now() },
+		ConnectDone: func(_, _ string, err error) {
+			resp.Timing.Connect = time.Since(connectStart)
+		},
+		TLSHandshakeStart: func() { tlsStart = time.Now() },
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			resp.Timing.TLS = time.Since(tlsStart)
+			if err == nil {
+				resp.TLS = &state
+			}
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) { wroteRequest = time.Now() },
+	}
+
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), http.MethodPost, resp.URL, bytes.NewReader(packed))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+
+	httpResp, err := (&http.Client{Transport: transport}).Do(req)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+	defer httpResp.Body.Close()
+	resp.HTTPVersion = httpResp.Proto
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DoH服务器返回 HTTP %d", httpResp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(httpResp.Body, maxMessageSize))
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+	resp.Timing.Query = time.Since(wroteRequest)
+
+	resp.Msg, err = checkResponse(body, query)
+	if err != nil {
+		return nil, err
+	}
+	resp.Size = len(body)
+	return resp, nil
+}
+
+// exchangeQUIC 通过 DNS over QUIC（RFC 9250）查询，每个查询使用一个双向流
+func exchangeQUIC(ctx context.Context, addr string, server Server, query *Message, opts Options) (*Response, error) {
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	conn, err := quic.DialAddr(ctx, addr, tlsConfig(server.Host, opts.Insecure, "doq"), &quic.Config{
+		HandshakeIdleTimeout: opts.Timeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("QUIC握手失败: %v", contextError(ctx, err))
+	}
+	// DOQ_NO_ERROR
+	defer conn.CloseWithError(0, "")
+	resp := &Response{Server: addr, Transport: TransportQUIC}
+	resp.Timing.TLS = time.Since(start)
+	state := conn.ConnectionState().TLS
+	resp.TLS = &state
+
+	start = time.Now()
+	stream, err := conn.OpenStreamSync(ctx)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		stream.SetDeadline(deadline)
+	}
+	if err := writeFramed(stream, packed); err != nil {
+		return nil, contextError(ctx, err)
+	}
+	// 发送查询后关闭流的发送方向，表示查询结束
+	stream.Close()
+	resp.Msg, resp.Size, err = readFramed(stream, query)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+	resp.Timing.Query = time.Since(start)
+	return resp, nil
+}
@@ -0,0 +1,251 @@
+package dnsprobe
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/miekg/dns"
+)
+
+// 报文的编解码使用 miekg/dns，这里的类型只保留探测和校验需要的内容：记录数据为线上格式（其中的域名不压缩），
+// 便于DNSSEC校验直接使用；EDNS见 RFC 6891
+
+// Header 报文头中的标志和响应码
+type Header struct {
+	ID                 uint16
+	Response           bool
+	Opcode             uint8
+	Authoritative      bool
+	Truncated          bool
+	RecursionDesired   bool
+	RecursionAvailable bool
+	AuthenticData      bool
+	CheckingDisabled   bool
+	Rcode              int // 报文头中的低4位，完整响应码见 Message.Rcode
+}
+
+// Question 查询的问题
+type Question struct {
+	Name  string // 带结尾点的完整域名
+	Type  uint16
+	Class uint16
+}
+
+// RR 一条资源记录，Data 为记录数据，其中的压缩域名已展开
+type RR struct {
+	Name  string
+	Type  uint16
+	Class uint16
+	TTL   uint32
+	Data  []byte
+}
+
+// EDNSOption OPT记录中的一个选项
+type EDNSOption struct {
+	Code uint16
+	Data []byte
+}
+
+// EDNS OPT伪记录中的内容
+type EDNS struct {
+	UDPSize       uint16
+	ExtendedRcode uint8 // 响应码的高8位
+	Version       uint8
+	DNSSECOK      bool // DO 标志
+	Options       []EDNSOption
+}
+
+// Message 一个DNS报文，OPT记录单独保存在 EDNS 中，不出现在 Additional 里
+type Message struct {
+	Header
+	Questions  []Question
+	Answer     []RR
+	Authority  []RR
+	Additional []RR
+	EDNS       *EDNS
+}
+
+// NewQuery 构造查询报文，默认携带EDNS（UDP缓冲区 DefaultUDPSize）
+func NewQuery(name string, qtype, qclass uint16, recursion bool) *Message {
+	var id [2]byte
+	rand.Read(id[:])
+	return &Message{
+		Header: Header{
+			ID:               binary.BigEndian.Uint16(id[:]),
+			RecursionDesired: recursion,
+		},
+		Questions: []Question{{Name: Fqdn(name), Type: qtype, Class: qclass}},
+		EDNS:      &EDNS{UDPSize: DefaultUDPSize},
+	}
+}
+
+// Rcode 返回包括EDNS扩展位在内的完整响应码
+func (m *Message) Rcode() int {
+	if m.EDNS != nil {
+		return int(m.EDNS.ExtendedRcode)<<4 | m.Header.Rcode
+	}
+	return m.Header.Rcode
+}
+
+// Pack 将报文编码为线上格式（不压缩域名）
+func (m *Message) Pack() ([]byte, error) {
+	msg := &dns.Msg{MsgHdr: dns.MsgHdr{
+		Id:                 m.ID,
+		Response:           m.Response,
+		Opcode:             int(m.Opcode),
+		Authoritative:      m.Authoritative,
+		Truncated:          m.Truncated,
+		RecursionDesired:   m.RecursionDesired,
+		RecursionAvailable: m.RecursionAvailable,
+		AuthenticatedData:  m.AuthenticData,
+		CheckingDisabled:   m.CheckingDisabled,
+		Rcode:              m.Rcode(),
+	}}
+	for _, q := range m.Questions {
+		msg.Question = append(msg.Question, dns.Question{Name: Fqdn(q.Name), Qtype: q.Type, Qclass: q.Class})
+	}
+
+	additional := m.Additional
+	if m.EDNS != nil {
+		additional = append(append([]RR(nil), additional...), m.EDNS.rr())
+	}
+	sections := []struct {
+		dst *[]dns.RR
+		rrs []RR
+	}{{&msg.Answer, m.Answer}, {&msg.Ns, m.Authority}, {&msg.Extra, additional}}
+	for _, section := range sections {
+		for _, rr := range section.rrs {
+			r, err := rr.toDNS()
+			if err != nil {
+				return nil, fmt.Errorf("编码 %s 记录失败: %v", TypeString(rr.Type), err)
+			}
+			*section.dst = append(*section.dst, r)
+		}
+	}
+	return msg.Pack()
+}
+
+// rr 编码为OPT伪记录
+func (e *EDNS) rr() RR {
+	ttl := uint32(e.ExtendedRcode)<<24 | uint32(e.Version)<<16
+	if e.DNSSECOK {
+		ttl |= 1 << 15
+	}
+	var data []byte
+	for _, opt := range e.Options {
+		data = binary.BigEndian.AppendUint16(data, opt.Code)
+		data = binary.BigEndian.AppendUint16(data, uint16(len(opt.Data)))
+		data = append(data, opt.Data...)
+	}
+	return RR{Name: ".", Type: TypeOPT, Class: e.UDPSize, TTL: ttl, Data: data}
+}
+
+// Unpack 解析线上格式的报文
+func Unpack(b []byte) (*Message, error) {
+	msg := new(dns.Msg)
+	if err := msg.Unpack(b); err != nil {
+		return nil, fmt.Errorf("解析报文失败: %v", err)
+	}
+	// 报文提前结束时 miekg/dns 按实际读到的记录处理，这里与报文头中的数量比较，拒绝不完整的报文
+	for i, n := range []int{len(msg.Question), len(msg.Answer), len(msg.Ns), len(msg.Extra)} {
+		if count := int(binary.BigEndian.Uint16(b[4+2*i:])); n != count {
+			return nil, fmt.Errorf("报文不完整: 报文头中有 %d 条记录，实际只有 %d 条", count, n)
+		}
+	}
+	m := &Message{Header: Header{
+		ID:                 msg.Id,
+		Response:           msg.Response,
+		Opcode:             uint8(msg.Opcode),
+		Authoritative:      msg.Authoritative,
+		Truncated:          msg.Truncated,
+		RecursionDesired:   msg.RecursionDesired,
+		RecursionAvailable: msg.RecursionAvailable,
+		AuthenticData:      msg.AuthenticatedData,
+		CheckingDisabled:   msg.CheckingDisabled,
+		Rcode:              msg.Rcode & 0xF, // 扩展位保存在 EDNS 中
+	}}
+	for _, q := range msg.Question {
+		m.Questions = append(m.Questions, Question{Name: q.Name, Type: q.Qtype, Class: q.Qclass})
+	}
+
+	sections := []struct {
+		dst *[]RR
+		rrs []dns.RR
+	}{{&m.Answer, msg.Answer}, {&m.Authority, msg.Ns}, {&m.Additional, msg.Extra}}
+	for _, section := range sections {
+		for _, r := range section.rrs {
+			rr, err := fromDNS(r)
+			if err != nil {
+				return nil, fmt.Errorf("解析 %s 记录失败: %v", TypeString(r.Header().Rrtype), err)
+			}
+			if _, ok := r.(*dns.OPT); ok && section.dst == &m.Additional {
+				m.EDNS = unpackEDNS(rr)
+				continue
+			}
+			*section.dst = append(*section.dst, rr)
+		}
+	}
+	return m, nil
+}
+
+// toDNS 转换为 miekg/dns 的记录
+func (rr RR) toDNS() (dns.RR, error) {
+	if len(rr.Data) > 0xFFFF {
+		return nil, errors.New("记录数据过长")
+	}
+	hdr := dns.RR_Header{Name: Fqdn(rr.Name), Rrtype: rr.Type, Class: rr.Class, Ttl: rr.TTL, Rdlength: uint16(len(rr.Data))}
+	r, _, err := dns.UnpackRRWithHeader(hdr, rr.Data, 0)
+	return r, err
+}
+
+// fromDNS 从 miekg/dns 的记录转换，记录数据中的域名展开为完整形式
+func fromDNS(r dns.RR) (RR, error) {
+	buf := make([]byte, dns.Len(r))
+	off, err := dns.PackRR(r, buf, 0, nil, false)
+	if err != nil {
+		return RR{}, err
+	}
+	h := r.Header()
+	return RR{Name: h.Name, Type: h.Rrtype, Class: h.Class, TTL: h.Ttl, Data: buf[off-int(h.Rdlength) : off]}, nil
+}
+
+func unpackEDNS(rr RR) *EDNS {
+	e := &EDNS{
+		UDPSize:       rr.Class,
+		ExtendedRcode: uint8(rr.TTL >> 24),
+		Version:       uint8(rr.TTL >> 16),
+		DNSSECOK:      rr.TTL&(1<<15) != 0,
+	}
+	data := rr.Data
+	for len(data) >= 4 {
+		code := binary.BigEndian.Uint16(data)
+		length := int(binary.BigEndian.Uint16(data[2:]))
+		if 4+length > len(data) {
+			break
+		}
+		e.Options = append(e.Options, EDNSOption{Code: code, Data: append([]byte(nil), data[4:4+length]...)})
+		data = data[4+length:]
+	}
+	return e
+}
+
+// Fqdn 为域名补上结尾的点
+func Fqdn(name string) string {
+	return dns.Fqdn(name)
+}
+
+// packName 将文本形式的域名（支持 \. 和 \DDD 转义）编码为不压缩的线上格式，追加到 b 之后
+func packName(b []byte, name string) ([]byte, error) {
+	name = Fqdn(name)
+	buf := make([]byte, len(name)+1)
+	n, err := dns.PackDomainName(name, buf, 0, nil, false)
+	if err == nil && n > 255 {
+		err = errors.New("域名过长")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("域名 %q 格式错误: %v", name, err)
+	}
+	return append(b, buf[:n]...), nil
+}
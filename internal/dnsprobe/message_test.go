@@ -0,0 +1,211 @@
+package dnsprobe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// header 构造报文头，counts 依次为问题、应答、权威、附加部分的记录数
+func header(id, flags uint16, counts ...uint16) []byte {
+	b := binary.BigEndian.AppendUint16(nil, id)
+	b = binary.BigEndian.AppendUint16(b, flags)
+	for i := 0; i < 4; i++ {
+		var n uint16
+		if i < len(counts) {
+			n = counts[i]
+		}
+		b = binary.BigEndian.AppendUint16(b, n)
+	}
+	return b
+}
+
+func TestPackUnpackRoundTrip(t *testing.T) {
+	msg := &Message{
+		Header: Header{
+			ID:                 0x1234,
+			Response:           true,
+			Opcode:             0,
+			Authoritative:      true,
+			RecursionDesired:   true,
+			RecursionAvailable: true,
+			AuthenticData:      true,
+			Rcode:              3,
+		},
+		Questions: []Question{{Name: "example.com.", Type: TypeA, Class: ClassIN}},
+		Answer: []RR{
+			{Name: "example.com.", Type: TypeA, Class: ClassIN, TTL: 300, Data: []byte{192, 0, 2, 1}},
+		},
+		Authority: []RR{
+			{Name: "example.com.", Type: TypeNS, Class: ClassIN, TTL: 3600, Data: []byte("\x01a\x0bexample-dns\x03net\x00")},
+		},
+		EDNS: &EDNS{
+			UDPSize:       1232,
+			ExtendedRcode: 1,
+			Version:       0,
+			DNSSECOK:      true,
+			Options: []EDNSOption{
+				{Code: OptionNSID, Data: []byte("ns1")},
+				{Code: OptionCookie, Data: []byte{1, 2, 3, 4, 5, 6, 7, 8}},
+				{Code: OptionPadding, Data: []byte{}},
+			},
+		},
+	}
+	packed, err := msg.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Unpack(packed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Header != msg.Header {
+		t.Errorf("header = %+v, want %+v", got.Header, msg.Header)
+	}
+	if !reflect.DeepEqual(got.Questions, msg.Questions) {
+		t.Errorf("questions = %+v", got.Questions)
+	}
+	if !reflect.DeepEqual(got.Answer, msg.Answer) || !reflect.DeepEqual(got.Authority, msg.Authority) {
+		t.Errorf("answer = %+v, authority = %+v", got.Answer, got.Authority)
+	}
+	if len(got.Additional) != 0 {
+		t.Errorf("OPT should not appear in additional: %+v", got.Additional)
+	}
+	if got.EDNS == nil {
+		t.Fatal("EDNS missing")
+	}
+	if got.EDNS.UDPSize != 1232 || got.EDNS.ExtendedRcode != 1 || !got.EDNS.DNSSECOK || len(got.EDNS.Options) != 3 {
+		t.Errorf("EDNS = %+v", got.EDNS)
+	}
+	for i, opt := range got.EDNS.Options {
+		want := msg.EDNS.Options[i]
+		if opt.Code != want.Code || !bytes.Equal(opt.Data, want.Data) {
+			t.Errorf("option %d = %+v, want %+v", i, opt, want)
+		}
+	}
+	// 扩展响应码的高8位与报文头中的低4位组合
+	if got.Rcode() != 1<<4|3 {
+		t.Errorf("Rcode() = %d", got.Rcode())
+	}
+}
+
+func TestNewQueryEDNS(t *testing.T) {
+	packed, err := NewQuery("example.org", TypeAAAA, ClassIN, true).Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := Unpack(packed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !msg.RecursionDesired || msg.EDNS == nil || msg.EDNS.UDPSize != DefaultUDPSize {
+		t.Errorf("query = %+v, EDNS = %+v", msg.Header, msg.EDNS)
+	}
+	if q := msg.Questions[0]; q.Name != "example.org." || q.Type != TypeAAAA {
+		t.Errorf("question = %+v", q)
+	}
+}
+
+func TestPackName(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    []byte
+		wantErr bool
+	}{
+		{".", []byte{0}, false},
+		{"example.com", []byte("\x07example\x03com\x00"), false},
+		{"a\\.b.c.", []byte("\x03a.b\x01c\x00"), false},
+		{"\\065bc.", []byte("\x03Abc\x00"), false},
+		{"a..b.", nil, true},
+		{strings.Repeat("x", 64) + ".", nil, true},
+		{strings.Repeat(strings.Repeat("x", 63)+".", 4), nil, true},
+	}
+	for _, tt := range tests {
+		got, err := packName(nil, tt.name)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("packName(%q) expected error", tt.name)
+			}
+			continue
+		}
+		if err != nil || !bytes.Equal(got, tt.want) {
+			t.Errorf("packName(%q) = %q, %v; want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestUnpackTruncated(t *testing.T) {
+	question := []byte("\x07example\x03com\x00\x00\x01\x00\x01")
+	rr := func(typ uint16, rdlength uint16, rdata []byte) []byte {
+		b := []byte{0xc0, 12}
+		b = binary.BigEndian.AppendUint16(b, typ)
+		b = binary.BigEndian.AppendUint16(b, ClassIN)
+		b = binary.BigEndian.AppendUint32(b, 60)
+		b = binary.BigEndian.AppendUint16(b, rdlength)
+		return append(b, rdata...)
+	}
+	join := func(parts ...[]byte) []byte { return bytes.Join(parts, nil) }
+
+	tests := []struct {
+		name    string
+		msg     []byte
+		wantErr string
+	}{
+		{"short header", []byte{0, 1, 2}, "解析报文失败"},
+		{"question count without question", header(1, 0x8000, 1), "报文不完整"},
+		{"truncated question name", join(header(1, 0x8000, 1), question[:5]), "解析报文失败"},
+		{"question name self loop", join(header(1, 0x8000, 1), []byte{0xc0, 12, 0, 1, 0, 1}), "解析报文失败"},
+		{"reserved label type", join(header(1, 0x8000, 1), []byte{0x40, 0, 0, 1, 0, 1}), "解析报文失败"},
+		{"answer count without answer", join(header(1, 0x8000, 1, 1), question), "报文不完整"},
+		{"additional count beyond records", join(header(1, 0x8000, 1, 1, 0, 2), question, rr(TypeA, 4, []byte{1, 2, 3, 4})), "报文不完整"},
+		{"truncated RR header", join(header(1, 0x8000, 1, 1), question, rr(TypeA, 4, nil)[:8]), "解析报文失败"},
+		{"truncated rdata", join(header(1, 0x8000, 1, 1), question, rr(TypeA, 4, []byte{1, 2})), "解析报文失败"},
+		{"rdlength beyond message", join(header(1, 0x8000, 1, 1), question, rr(TypeTXT, 0xffff, []byte("\x01a"))), "解析报文失败"},
+		{"name in rdata beyond rdlength", join(header(1, 0x8000, 1, 1), question, rr(TypeNS, 2, []byte("\x03ns1\x00"))), "解析报文失败"},
+		{"MX shorter than preference", join(header(1, 0x8000, 1, 1), question, rr(TypeMX, 1, []byte{0})), "解析报文失败"},
+		{"compression loop in rdata", join(header(1, 0x8000, 1, 1), question, rr(TypeCNAME, 2, []byte{0xc0, 41})), "解析报文失败"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Unpack(tt.msg)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUnpackCompressedRdata(t *testing.T) {
+	// 应答的 CNAME 指向问题中的域名，解析后记录数据中的域名应展开为完整形式
+	msg := header(7, 0x8180, 1, 2)
+	msg = append(msg, "\x03www\x07example\x03com\x00\x00\x05\x00\x01"...)
+	msg = append(msg, 0xc0, 12, 0, 5, 0, 1, 0, 0, 0, 60, 0, 2, 0xc0, 16)
+	msg = append(msg, 0xc0, 16, 0, 15, 0, 1, 0, 0, 0, 60, 0, 7, 0, 10, 2, 'm', 'x', 0xc0, 16)
+	m, err := Unpack(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Answer) != 2 {
+		t.Fatalf("answers = %+v", m.Answer)
+	}
+	if _, text, _ := m.Answer[0].Fields(); text != "example.com." {
+		t.Errorf("CNAME = %q", text)
+	}
+	if _, text, _ := m.Answer[1].Fields(); text != "10 mx.example.com." {
+		t.Errorf("MX = %q", text)
+	}
+	if !bytes.Equal(m.Answer[0].Data, []byte("\x07example\x03com\x00")) {
+		t.Errorf("CNAME data not expanded: %q", m.Answer[0].Data)
+	}
+}
+
+func TestUnpackEDNSMalformedOptions(t *testing.T) {
+	// 选项长度超出OPT记录数据时忽略剩余部分
+	e := unpackEDNS(RR{Type: TypeOPT, Class: 4096, TTL: 1 << 15, Data: []byte{0, 3, 0, 2, 'n', 's', 0, 10, 0, 8, 1}})
+	if e.UDPSize != 4096 || !e.DNSSECOK || len(e.Options) != 1 || string(e.Options[0].Data) != "ns" {
+		t.Errorf("EDNS = %+v", e)
+	}
+}
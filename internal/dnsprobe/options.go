@@ -0,0 +1,281 @@
+package dnsprobe
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"linkmaster-node/internal/netutil"
+)
+
+const (
+	DefaultTimeout = 5 * time.Second
+	MinTimeout     = 100 * time.Millisecond
+	MaxTimeout     = 30 * time.Second
+	DefaultUDPSize = 1232 // DNS Flag Day 2020 建议的EDNS缓冲区大小
+	MinUDPSize     = 512
+)
+
+// Options 一次DNS查询的参数
+type Options struct {
+	Name      string // 查询的域名（带结尾点）
+	Type      uint16
+	Class     uint16
+	Server    string         // ds 参数，空为系统DNS
+	Family    netutil.Family // 与DNS服务器通信使用的地址族
+	Timeout   time.Duration
+	TCP       bool // 直接使用TCP查询
+	Recursion bool // RD 标志
+	Insecure  bool // 加密传输时跳过证书校验
+	DNSSEC    bool // 设置DO标志请求DNSSEC记录，并在本地校验信任链
+	CD        bool // CD 标志，要求上游服务器不做DNSSEC校验
+
+	NoEDNS       bool          // 不携带OPT记录
+	UDPSize      uint16        // EDNS 通告的UDP缓冲区大小
+	ClientSubnet *ClientSubnet // EDNS Client Subnet
+	NSID         bool          // 请求服务器返回NSID
+	Cookie       *EDNSOption   // DNS Cookie
+	EDNSOptions  []EDNSOption  // ednsopt 指定的其他选项
+}
+
+// ParseOptions 从测试请求的 params 中解析查询参数，host 为查询的域名
+//
+// 支持的参数：dt（记录类型，默认A）、ds（DNS服务器，支持 tls://、https://、quic:// 加密传输）、class（默认IN）、
+// timeout_ms、tcp、rd（默认 true）、insecure（加密传输时跳过证书校验）、dnssec（请求并校验DNSSEC记录）、cd、ip_version，
+// 以及EDNS相关的 edns（默认 true）、udp_size、ecs（客户端子网）、nsid、cookie、ednsopt（code[:十六进制数据]，可为数组）。
+// dt 为 PTR 且 host 是IP时自动转换为反向解析域名。
+func ParseOptions(host string, params map[string]interface{}) (Options, error) {
+	opts := Options{
+		Type:      TypeA,
+		Class:     ClassIN,
+		Timeout:   DefaultTimeout,
+		Recursion: true,
+		UDPSize:   DefaultUDPSize,
+	}
+
+	if dt, ok := params["dt"].(string); ok && strings.TrimSpace(dt) != "" {
+		t, err := ParseType(dt)
+		if err != nil {
+			return opts, err
+		}
+		opts.Type = t
+	}
+	if class, ok := params["class"].(string); ok && strings.TrimSpace(class) != "" {
+		c, err := ParseClass(class)
+		if err != nil {
+			return opts, err
+		}
+		opts.Class = c
+	}
+	if ds, ok := params["ds"].(string); ok {
+		opts.Server = strings.TrimSpace(ds)
+	}
+	if timeout, ok := params["timeout_ms"].(float64); ok {
+		opts.Timeout = clampDuration(time.Duration(timeout)*time.Millisecond, MinTimeout, MaxTimeout)
+	}
+	if v, ok := params["tcp"].(bool); ok {
+		opts.TCP = v
+	}
+	if v, ok := params["rd"].(bool); ok {
+		opts.Recursion = v
+	}
+	if v, ok := params["insecure"].(bool); ok {
+		opts.Insecure = v
+	}
+	if v, ok := params["dnssec"].(bool); ok {
+		opts.DNSSEC = v
+	}
+	if v, ok := params["cd"].(bool); ok {
+		opts.CD = v
+	}
+	if err := parseEDNSOptions(&opts, params); err != nil {
+		return opts, err
+	}
+	if _, err := ParseServer(opts.Server); err != nil {
+		return opts, err
+	}
+
+	family, err := netutil.ParseFamily(params)
+	if err != nil {
+		return opts, err
+	}
+	if family == netutil.FamilyDual {
+		family = netutil.FamilyAuto
+	}
+	opts.Family = family
+
+	if ip := net.ParseIP(host); ip != nil && opts.Type == TypePTR {
+		opts.Name = ReverseName(ip)
+	} else {
+		opts.Name = Fqdn(host)
+	}
+	if _, err := packName(nil, opts.Name); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// Settings 返回实际生效的参数，回显在结果的 settings 字段中
+func (o Options) Settings() map[string]interface{} {
+	server := o.Server
+	if server == "" {
+		server = "system"
+	}
+	settings := map[string]interface{}{
+		"name":       o.Name,
+		"type":       TypeString(o.Type),
+		"class":      ClassString(o.Class),
+		"server":     server,
+		"timeout_ms": o.Timeout.Milliseconds(),
+		"tcp":        o.TCP,
+		"rd":         o.Recursion,
+		"insecure":   o.Insecure,
+		"dnssec":     o.DNSSEC,
+		"cd":         o.CD,
+		"ip_version": string(o.Family),
+	}
+	if o.NoEDNS {
+		settings["edns"] = false
+		return settings
+	}
+	settings["edns"] = true
+	settings["udp_size"] = o.UDPSize
+	settings["nsid"] = o.NSID
+	if o.ClientSubnet != nil {
+		settings["ecs"] = o.ClientSubnet.String()
+	}
+	if o.Cookie != nil {
+		settings["cookie"] = hex.EncodeToString(o.Cookie.Data)
+	}
+	if len(o.EDNSOptions) > 0 {
+		opts := make([]string, 0, len(o.EDNSOptions))
+		for _, opt := range o.EDNSOptions {
+			opts = append(opts, fmt.Sprintf("%d:%s", opt.Code, hex.EncodeToString(opt.Data)))
+		}
+		settings["ednsopt"] = opts
+	}
+	return settings
+}
+
+// parseEDNSOptions 解析EDNS相关的参数
+func parseEDNSOptions(opts *Options, params map[string]interface{}) error {
+	if v, ok := params["edns"].(bool); ok {
+		opts.NoEDNS = !v
+	}
+	if v, ok := params["udp_size"].(float64); ok {
+		opts.UDPSize = uint16(max(MinUDPSize, min(v, 65535)))
+	}
+	if v, ok := params["nsid"].(bool); ok {
+		opts.NSID = v
+	}
+	if v, ok := params["ecs"].(string); ok && strings.TrimSpace(v) != "" {
+		ecs, err := ParseClientSubnet(v)
+		if err != nil {
+			return err
+		}
+		opts.ClientSubnet = ecs
+	}
+	cookie, err := NewCookie(params["cookie"])
+	if err != nil {
+		return err
+	}
+	opts.Cookie = cookie
+
+	var list []string
+	switch v := params["ednsopt"].(type) {
+	case string:
+		list = strings.Split(v, ",")
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+	}
+	for _, s := range list {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		opt, err := ParseOption(s)
+		if err != nil {
+			return err
+		}
+		opts.EDNSOptions = append(opts.EDNSOptions, opt)
+	}
+
+	if opts.NoEDNS && (opts.DNSSEC || opts.NSID || opts.ClientSubnet != nil || opts.Cookie != nil || len(opts.EDNSOptions) > 0) {
+		return errors.New("关闭EDNS时不能使用 dnssec、nsid、ecs、cookie 或 ednsopt")
+	}
+	return nil
+}
+
+// NewMessage 按参数构造查询报文，recursion 为 RD 标志
+func (o Options) NewMessage(recursion bool) *Message {
+	query := NewQuery(o.Name, o.Type, o.Class, recursion)
+	query.CheckingDisabled = o.CD
+	if o.NoEDNS {
+		query.EDNS = nil
+		return query
+	}
+	query.EDNS.UDPSize = o.UDPSize
+	query.EDNS.DNSSECOK = o.DNSSEC
+	if o.NSID {
+		query.EDNS.Options = append(query.EDNS.Options, EDNSOption{Code: OptionNSID})
+	}
+	if o.ClientSubnet != nil {
+		query.EDNS.Options = append(query.EDNS.Options, o.ClientSubnet.Option())
+	}
+	if o.Cookie != nil {
+		query.EDNS.Options = append(query.EDNS.Options, *o.Cookie)
+	}
+	query.EDNS.Options = append(query.EDNS.Options, o.EDNSOptions...)
+	return query
+}
+
+// ParseName 解析查询的域名：先按普通目标解析（支持URL和国际化域名），
+// 失败时允许 _sip._tcp.example.com 这类含下划线等字符的DNS名称
+func ParseName(raw string) (string, error) {
+	target, err := netutil.ParseTarget(raw)
+	if err == nil {
+		return target.Host, nil
+	}
+	name := strings.TrimSuffix(strings.TrimSpace(raw), ".")
+	if name == "" || strings.ContainsAny(name, "/: \t") {
+		return "", err
+	}
+	if _, packErr := packName(nil, name); packErr != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// ReverseName 返回IP对应的反向解析域名（in-addr.arpa / ip6.arpa）
+func ReverseName(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d.in-addr.arpa.", ip4[3], ip4[2], ip4[1], ip4[0])
+	}
+	const hexDigits = "0123456789abcdef"
+	var sb strings.Builder
+	ip16 := ip.To16()
+	for i := len(ip16) - 1; i >= 0; i-- {
+		sb.WriteByte(hexDigits[ip16[i]&0xF])
+		sb.WriteByte('.')
+		sb.WriteByte(hexDigits[ip16[i]>>4])
+		sb.WriteByte('.')
+	}
+	sb.WriteString("ip6.arpa.")
+	return sb.String()
+}
+
+func clampDuration(v, min, max time.Duration) time.Duration {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
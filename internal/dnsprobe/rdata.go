@@ -0,0 +1,286 @@
+package dnsprobe
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// 记录类型
+const (
+	TypeA          = dns.TypeA
+	TypeNS         = dns.TypeNS
+	TypeCNAME      = dns.TypeCNAME
+	TypeSOA        = dns.TypeSOA
+	TypePTR        = dns.TypePTR
+	TypeMX         = dns.TypeMX
+	TypeTXT        = dns.TypeTXT
+	TypeAAAA       = dns.TypeAAAA
+	TypeSRV        = dns.TypeSRV
+	TypeDNAME      = dns.TypeDNAME
+	TypeOPT        = dns.TypeOPT
+	TypeDS         = dns.TypeDS
+	TypeRRSIG      = dns.TypeRRSIG
+	TypeNSEC       = dns.TypeNSEC
+	TypeDNSKEY     = dns.TypeDNSKEY
+	TypeNSEC3      = dns.TypeNSEC3
+	TypeNSEC3PARAM = dns.TypeNSEC3PARAM
+	TypeSVCB       = dns.TypeSVCB
+	TypeHTTPS      = dns.TypeHTTPS
+	TypeANY        = dns.TypeANY
+	TypeCAA        = dns.TypeCAA
+)
+
+// 记录类别
+const (
+	ClassIN  = dns.ClassINET
+	ClassCH  = dns.ClassCHAOS
+	ClassHS  = dns.ClassHESIOD
+	ClassANY = dns.ClassANY
+)
+
+// TypeString 返回记录类型的名称，未知类型为 TYPEnnn（RFC 3597）
+func TypeString(t uint16) string {
+	return dns.Type(t).String()
+}
+
+// ClassString 返回类别的名称，未知类别为 CLASSnnn
+func ClassString(c uint16) string {
+	return dns.Class(c).String()
+}
+
+// RcodeString 返回响应码的名称；16 在EDNS中为 BADVERS，miekg/dns 按TSIG记为 BADSIG
+func RcodeString(rcode int) string {
+	if rcode == dns.RcodeBadVers {
+		return "BADVERS"
+	}
+	if name, ok := dns.RcodeToString[rcode]; ok {
+		return name
+	}
+	return "RCODE" + strconv.Itoa(rcode)
+}
+
+// ParseType 解析记录类型名称，支持 TYPEnnn 和数字
+func ParseType(s string) (uint16, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if t, ok := dns.StringToType[s]; ok && t != TypeOPT {
+		return t, nil
+	}
+	if n, err := strconv.ParseUint(strings.TrimPrefix(s, "TYPE"), 10, 16); err == nil && n > 0 {
+		return uint16(n), nil
+	}
+	return 0, fmt.Errorf("不支持的记录类型: %s", s)
+}
+
+// ParseClass 解析类别名称，支持 CLASSnnn 和数字
+func ParseClass(s string) (uint16, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if c, ok := dns.StringToClass[s]; ok {
+		return c, nil
+	}
+	if n, err := strconv.ParseUint(strings.TrimPrefix(s, "CLASS"), 10, 16); err == nil && n > 0 {
+		return uint16(n), nil
+	}
+	return 0, fmt.Errorf("不支持的记录类别: %s", s)
+}
+
+// rdataNames 返回记录数据中域名的位置：域名之前定长字段的字节数和连续的域名个数，
+// 仅包括可能被压缩的类型（RFC 3597 第4节），DNSSEC规范形式中这些域名需转为小写
+func rdataNames(typ uint16) (prefix, names int) {
+	switch typ {
+	case TypeNS, TypeCNAME, TypePTR, TypeDNAME:
+		return 0, 1
+	case TypeSOA:
+		return 0, 2
+	case TypeMX:
+		return 2, 1
+	case TypeSRV:
+		return 6, 1
+	}
+	return 0, 0
+}
+
+// Fields 按记录类型解析记录数据，返回各字段和主文件格式的文本形式
+func (rr RR) Fields() (map[string]interface{}, string, error) {
+	if len(rr.Data) == 0 {
+		// 没有数据的记录（如动态更新中的删除）使用 RFC 3597 的通用格式
+		return map[string]interface{}{"rdata": ""}, genericText(nil), nil
+	}
+	r, err := rr.toDNS()
+	if err != nil {
+		return nil, "", err
+	}
+	// 数据在字段之间结束时 miekg/dns 把缺少的字段当作零值，重新编码后与原数据比较以发现不完整的数据
+	if packed, err := fromDNS(r); err != nil || !bytes.Equal(packed.Data, rr.Data) {
+		return nil, "", errors.New("数据长度错误")
+	}
+	text := strings.TrimPrefix(r.String(), r.Header().String())
+
+	var fields map[string]interface{}
+	switch r := r.(type) {
+	case *dns.A:
+		fields = map[string]interface{}{"ip": r.A.String()}
+	case *dns.AAAA:
+		fields = map[string]interface{}{"ip": r.AAAA.String()}
+	case *dns.NS:
+		fields = map[string]interface{}{"ns": r.Ns}
+	case *dns.CNAME:
+		fields = map[string]interface{}{"target": r.Target}
+	case *dns.DNAME:
+		fields = map[string]interface{}{"target": r.Target}
+	case *dns.PTR:
+		fields = map[string]interface{}{"ptr": r.Ptr}
+	case *dns.MX:
+		fields = map[string]interface{}{"preference": r.Preference, "exchange": r.Mx}
+	case *dns.TXT:
+		// miekg/dns 中的字符串已按主文件格式转义，字段中保留原始内容
+		txt, err := characterStrings(rr.Data)
+		if err != nil {
+			return nil, "", err
+		}
+		fields = map[string]interface{}{"txt": txt}
+	case *dns.SOA:
+		fields = map[string]interface{}{
+			"mname": r.Ns, "rname": r.Mbox, "serial": r.Serial,
+			"refresh": r.Refresh, "retry": r.Retry, "expire": r.Expire, "minimum": r.Minttl,
+		}
+	case *dns.SRV:
+		fields = map[string]interface{}{"priority": r.Priority, "weight": r.Weight, "port": r.Port, "target": r.Target}
+	case *dns.CAA:
+		fields = map[string]interface{}{"flags": r.Flag, "tag": r.Tag, "value": r.Value}
+	case *dns.DS:
+		fields = map[string]interface{}{"key_tag": r.KeyTag, "algorithm": r.Algorithm, "digest_type": r.DigestType, "digest": strings.ToUpper(r.Digest)}
+	case *dns.DNSKEY:
+		fields = map[string]interface{}{
+			"flags": r.Flags, "protocol": r.Protocol, "algorithm": r.Algorithm,
+			"public_key": r.PublicKey, "key_tag": KeyTag(rr.Data),
+		}
+	case *dns.RRSIG:
+		fields = map[string]interface{}{
+			"type_covered": TypeString(r.TypeCovered), "algorithm": r.Algorithm, "labels": r.Labels,
+			"original_ttl": r.OrigTtl, "expiration": dns.TimeToString(r.Expiration), "inception": dns.TimeToString(r.Inception),
+			"key_tag": r.KeyTag, "signer": r.SignerName, "signature": r.Signature,
+		}
+	case *dns.NSEC:
+		fields = map[string]interface{}{"next": r.NextDomain, "types": typeList(r.TypeBitMap)}
+	case *dns.NSEC3:
+		fields = map[string]interface{}{
+			"hash_algorithm": r.Hash, "flags": r.Flags, "iterations": r.Iterations, "salt": saltString(r.Salt),
+			"next_hashed": r.NextDomain, "types": typeList(r.TypeBitMap),
+		}
+	case *dns.NSEC3PARAM:
+		fields = map[string]interface{}{"hash_algorithm": r.Hash, "flags": r.Flags, "iterations": r.Iterations, "salt": saltString(r.Salt)}
+	case *dns.SVCB:
+		fields = svcbFields(r)
+	case *dns.HTTPS:
+		fields = svcbFields(&r.SVCB)
+	default:
+		fields = map[string]interface{}{"rdata": hex.EncodeToString(rr.Data)}
+	}
+	return fields, text, nil
+}
+
+// genericText 返回 RFC 3597 通用格式的记录数据文本
+func genericText(d []byte) string {
+	text := fmt.Sprintf("\\# %d", len(d))
+	if len(d) > 0 {
+		text += " " + hex.EncodeToString(d)
+	}
+	return text
+}
+
+// characterStrings 解析连续的 <character-string>
+func characterStrings(d []byte) ([]string, error) {
+	var list []string
+	for len(d) > 0 {
+		n := int(d[0])
+		if 1+n > len(d) {
+			return nil, errors.New("数据长度错误")
+		}
+		list = append(list, string(d[1:1+n]))
+		d = d[1+n:]
+	}
+	return list, nil
+}
+
+// typeList 返回 NSEC/NSEC3 类型位图中的类型名称
+func typeList(types []uint16) []string {
+	names := make([]string, 0, len(types))
+	for _, t := range types {
+		names = append(names, TypeString(t))
+	}
+	return names
+}
+
+// saltString 返回 NSEC3 盐值的文本形式，没有盐时为 "-"
+func saltString(salt string) string {
+	if salt == "" {
+		return "-"
+	}
+	return strings.ToUpper(salt)
+}
+
+// KeyTag 计算DNSKEY记录数据的密钥标签（RFC 4034 附录B）
+func KeyTag(rdata []byte) uint16 {
+	var ac uint32
+	for i, b := range rdata {
+		if i&1 == 0 {
+			ac += uint32(b) << 8
+		} else {
+			ac += uint32(b)
+		}
+	}
+	ac += ac >> 16 & 0xFFFF
+	return uint16(ac & 0xFFFF)
+}
+
+// svcbFields 返回 SVCB/HTTPS 记录的字段，参数按 RFC 9460 的名称给出
+func svcbFields(r *dns.SVCB) map[string]interface{} {
+	params := make(map[string]interface{})
+	for _, kv := range r.Value {
+		var v interface{}
+		switch kv := kv.(type) {
+		case *dns.SVCBMandatory:
+			keys := make([]string, 0, len(kv.Code))
+			for _, k := range kv.Code {
+				keys = append(keys, k.String())
+			}
+			v = keys
+		case *dns.SVCBAlpn:
+			v = kv.Alpn
+		case *dns.SVCBNoDefaultAlpn, *dns.SVCBOhttp:
+			v = true
+		case *dns.SVCBPort:
+			v = kv.Port
+		case *dns.SVCBIPv4Hint:
+			v = ipStrings(kv.Hint)
+		case *dns.SVCBIPv6Hint:
+			v = ipStrings(kv.Hint)
+		case *dns.SVCBECHConfig:
+			v = base64.StdEncoding.EncodeToString(kv.ECH)
+		case *dns.SVCBDoHPath:
+			v = kv.Template
+		case *dns.SVCBLocal:
+			v = hex.EncodeToString(kv.Data)
+		default:
+			v = kv.String()
+		}
+		params[kv.Key().String()] = v
+	}
+	return map[string]interface{}{"priority": r.Priority, "target": r.Target, "params": params}
+}
+
+func ipStrings(ips []net.IP) []string {
+	list := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		list = append(list, ip.String())
+	}
+	return list
+}
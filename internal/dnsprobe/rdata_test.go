@@ -0,0 +1,119 @@
+package dnsprobe
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// rootKSK2017 根区 KSK-2017（密钥标签 20326）的公钥
+const rootKSK2017 = "AwEAAaz/tAm8yTn4Mfeh5eyI96WSVexTBAvkMgJzkKTOiW1vkIbzxeF3+/4RgWOq7HrxRixHlFlExOLAJr5emLvN7SWXgnLh4+B5xQlNVz8Og8kvArMtNROxVQuCaSnIDdD5LKyWbRd2n9WGe2R8PzgCmr3EgVLrjyBxWezF0jLHwVN8efS3rCj/EWgvIWgb9tarpVUDK/b58Da+sqqls3eNbuv7pr+eoZG+SrDK6nWeL3c6H5Apxz7LjVc1uTIdsIXxuOLYA4/ilBmSVIzuDWfdRUfhHdY6+cn8HFRm+2hM8AnXGXws9555KrUB5qihylGa8subX2Nn6UwNR1AkUTV74bU="
+
+func dnskey(flags uint16, protocol, alg uint8, key string) []byte {
+	pub, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		panic(err)
+	}
+	d := binary.BigEndian.AppendUint16(nil, flags)
+	return append(append(d, protocol, alg), pub...)
+}
+
+func TestKeyTag(t *testing.T) {
+	tests := []struct {
+		name  string
+		rdata []byte
+		want  uint16
+	}{
+		{"empty", nil, 0},
+		{"odd length", []byte{0x01, 0x02, 0x03}, 0x0402},
+		// 进位：0xFFFF + 0x0001 折叠后为 0x0001
+		{"carry", []byte{0xff, 0xff, 0x00, 0x01}, 0x0001},
+		{"root KSK-2017", dnskey(257, 3, 8, rootKSK2017), 20326},
+	}
+	for _, tt := range tests {
+		if got := KeyTag(tt.rdata); got != tt.want {
+			t.Errorf("%s: KeyTag = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestKeyTagMatchesTrustAnchor(t *testing.T) {
+	key := RR{Name: ".", Type: TypeDNSKEY, Class: ClassIN, Data: dnskey(257, 3, 8, rootKSK2017)}
+	matched := false
+	for _, ds := range rootTrustAnchors() {
+		if ds.matches(key) {
+			matched = true
+		}
+	}
+	if !matched {
+		t.Fatal("root KSK-2017 does not match any built-in trust anchor")
+	}
+	// 修改公钥后摘要不再匹配
+	key.Data = append([]byte(nil), key.Data...)
+	key.Data[len(key.Data)-1] ^= 1
+	for _, ds := range rootTrustAnchors() {
+		if ds.matches(key) {
+			t.Fatal("modified key should not match")
+		}
+	}
+}
+
+func TestFields(t *testing.T) {
+	tests := []struct {
+		name       string
+		rr         RR
+		wantFields map[string]interface{}
+		wantText   string
+		wantErr    bool
+	}{
+		{"A", RR{Type: TypeA, Data: []byte{192, 0, 2, 1}}, map[string]interface{}{"ip": "192.0.2.1"}, "192.0.2.1", false},
+		{"A wrong length", RR{Type: TypeA, Data: []byte{192, 0, 2}}, nil, "", true},
+		{"AAAA", RR{Type: TypeAAAA, Data: []byte{0x20, 0x01, 0x0d, 0xb8, 15: 1}}, map[string]interface{}{"ip": "2001:db8::1"}, "2001:db8::1", false},
+		{"CNAME", RR{Type: TypeCNAME, Data: []byte("\x03www\x07example\x00")}, map[string]interface{}{"target": "www.example."}, "www.example.", false},
+		{"CNAME truncated", RR{Type: TypeCNAME, Data: []byte("\x03ww")}, nil, "", true},
+		{"MX", RR{Type: TypeMX, Data: []byte("\x00\x0a\x02mx\x00")}, map[string]interface{}{"preference": uint16(10), "exchange": "mx."}, "10 mx.", false},
+		{"MX truncated", RR{Type: TypeMX, Data: []byte{0}}, nil, "", true},
+		{"TXT", RR{Type: TypeTXT, Data: []byte("\x05hello\x07a \"b\" c")}, map[string]interface{}{"txt": []string{"hello", "a \"b\" c"}}, `"hello" "a \"b\" c"`, false},
+		{"TXT truncated", RR{Type: TypeTXT, Data: []byte("\x05hel")}, nil, "", true},
+		{"DS", RR{Type: TypeDS, Data: []byte{0x4f, 0x66, 8, 2, 0xab, 0xcd}},
+			map[string]interface{}{"key_tag": uint16(20326), "algorithm": uint8(8), "digest_type": uint8(2), "digest": "ABCD"}, "20326 8 2 ABCD", false},
+		{"DS truncated", RR{Type: TypeDS, Data: []byte{0x4f, 0x66, 8}}, nil, "", true},
+		{"NSEC", RR{Type: TypeNSEC, Data: append([]byte("\x01b\x07example\x00"), bitmap(TypeA, TypeRRSIG, TypeNSEC)...)},
+			map[string]interface{}{"next": "b.example.", "types": []string{"A", "RRSIG", "NSEC"}}, "b.example. A RRSIG NSEC", false},
+		{"NSEC3", RR{Type: TypeNSEC3, Data: append([]byte{1, 1, 0, 10, 0, 4, 0xde, 0xad, 0xbe, 0xef}, bitmap(TypeA)...)},
+			map[string]interface{}{"hash_algorithm": uint8(1), "flags": uint8(1), "iterations": uint16(10), "salt": "-", "next_hashed": "RQMRTRO", "types": []string{"A"}},
+			"1 1 10 - RQMRTRO A", false},
+		{"SVCB", RR{Type: TypeSVCB, Data: []byte("\x00\x01\x00\x00\x01\x00\x06\x02h2\x02h3\x00\x03\x00\x02\x01\xbb")},
+			map[string]interface{}{"priority": uint16(1), "target": ".", "params": map[string]interface{}{"alpn": []string{"h2", "h3"}, "port": uint16(443)}},
+			`1 . alpn="h2,h3" port="443"`, false},
+		{"unknown type", RR{Type: 65280, Data: []byte{1, 2}}, map[string]interface{}{"rdata": "0102"}, `\# 2 0102`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields, text, err := tt.rr.Fields()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %v %q", fields, text)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(fields, tt.wantFields) || text != tt.wantText {
+				t.Errorf("got %#v %q, want %#v %q", fields, text, tt.wantFields, tt.wantText)
+			}
+		})
+	}
+}
+
+func TestDNSKEYFields(t *testing.T) {
+	fields, _, err := RR{Type: TypeDNSKEY, Data: dnskey(257, 3, 8, rootKSK2017)}.Fields()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fields["key_tag"] != uint16(20326) || fields["public_key"] != rootKSK2017 {
+		t.Errorf("fields = %v", fields)
+	}
+}
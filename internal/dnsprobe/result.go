@@ -0,0 +1,222 @@
+package dnsprobe
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+
+	"linkmaster-node/internal/stats"
+)
+
+// RecordMap 返回记录的结构化结果：name、ttl、class、type、data（主文件格式的文本）以及按类型解析的字段
+func RecordMap(rr RR) map[string]interface{} {
+	fields, text, err := rr.Fields()
+	record := map[string]interface{}{}
+	for k, v := range fields {
+		record[k] = v
+	}
+	if err != nil {
+		record["error"] = err.Error()
+		text = genericText(rr.Data)
+	}
+	record["name"] = rr.Name
+	record["ttl"] = rr.TTL
+	record["class"] = ClassString(rr.Class)
+	record["type"] = TypeString(rr.Type)
+	record["data"] = text
+	return record
+}
+
+// RecordMaps 返回一组记录的结构化结果
+func RecordMaps(rrs []RR) []map[string]interface{} {
+	records := make([]map[string]interface{}, 0, len(rrs))
+	for _, rr := range rrs {
+		records = append(records, RecordMap(rr))
+	}
+	return records
+}
+
+// Flags 返回报文头中的标志
+func (m *Message) Flags() map[string]interface{} {
+	return map[string]interface{}{
+		"qr": m.Response,
+		"aa": m.Authoritative,
+		"tc": m.Truncated,
+		"rd": m.RecursionDesired,
+		"ra": m.RecursionAvailable,
+		"ad": m.AuthenticData,
+		"cd": m.CheckingDisabled,
+	}
+}
+
+// Map 返回应答的结构化结果，ceDns 及基于它的测试类型共用这一结构
+func (r *Response) Map() map[string]interface{} {
+	m := r.Msg
+	questions := make([]map[string]interface{}, 0, len(m.Questions))
+	for _, q := range m.Questions {
+		questions = append(questions, map[string]interface{}{
+			"name":  q.Name,
+			"type":  TypeString(q.Type),
+			"class": ClassString(q.Class),
+		})
+	}
+
+	result := map[string]interface{}{
+		"rcode":      RcodeString(m.Rcode()),
+		"flags":      m.Flags(),
+		"question":   questions,
+		"answer":     RecordMaps(m.Answer),
+		"authority":  RecordMaps(m.Authority),
+		"additional": RecordMaps(m.Additional),
+		"query_time": stats.Round(stats.Milliseconds(r.Timing.Query)),
+		"server":     r.Server,
+		"transport":  r.Transport,
+		"msg_size":   r.Size,
+		"timing": map[string]interface{}{
+			"resolve": stats.Round(stats.Milliseconds(r.Timing.Resolve)),
+			"connect": stats.Round(stats.Milliseconds(r.Timing.Connect)),
+			"tls":     stats.Round(stats.Milliseconds(r.Timing.TLS)),
+			"query":   stats.Round(stats.Milliseconds(r.Timing.Query)),
+			"total":   stats.Round(stats.Milliseconds(r.Timing.Total)),
+		},
+	}
+	if r.ServerName != "" {
+		result["server_name"] = r.ServerName
+	}
+	if r.URL != "" {
+		result["url"] = r.URL
+		result["http_version"] = r.HTTPVersion
+	}
+	if r.TLS != nil {
+		result["tls"] = map[string]interface{}{
+			"version":      tls.VersionName(r.TLS.Version),
+			"cipher_suite": tls.CipherSuiteName(r.TLS.CipherSuite),
+			"alpn":         r.TLS.NegotiatedProtocol,
+			"server_name":  r.TLS.ServerName,
+			"resumed":      r.TLS.DidResume,
+		}
+	}
+	if r.UDPTruncated {
+		result["udp_truncated"] = true
+	}
+	if m.EDNS != nil {
+		edns := map[string]interface{}{
+			"version":  m.EDNS.Version,
+			"udp_size": m.EDNS.UDPSize,
+			"do":       m.EDNS.DNSSECOK,
+		}
+		options := make([]map[string]interface{}, 0, len(m.EDNS.Options))
+		for _, opt := range m.EDNS.Options {
+			om := optionMap(opt)
+			options = append(options, om)
+			// 常用选项另外直接放在 edns 下，便于判断应答的任播节点和ECS作用范围
+			switch opt.Code {
+			case OptionNSID:
+				edns["nsid"] = om["text"]
+			case OptionClientSubnet:
+				if _, ok := om["scope_prefix"]; ok {
+					edns["ecs"] = map[string]interface{}{
+						"address":       om["address"],
+						"source_prefix": om["source_prefix"],
+						"scope_prefix":  om["scope_prefix"],
+					}
+				}
+			case OptionCookie:
+				if server, ok := om["server"]; ok {
+					edns["cookie"] = map[string]interface{}{"client": om["client"], "server": server}
+				}
+			}
+		}
+		edns["options"] = options
+		result["edns"] = edns
+	}
+	return result
+}
+
+// Text 返回与 dig 输出格式相近的文本
+func (r *Response) Text() string {
+	m := r.Msg
+	var sb strings.Builder
+	fmt.Fprintf(&sb, ";; ->>HEADER<<- opcode: %s, status: %s, id: %d\n", opcodeString(m.Opcode), RcodeString(m.Rcode()), m.ID)
+
+	var flags []string
+	for _, f := range []struct {
+		name string
+		set  bool
+	}{
+		{"qr", m.Response}, {"aa", m.Authoritative}, {"tc", m.Truncated}, {"rd", m.RecursionDesired},
+		{"ra", m.RecursionAvailable}, {"ad", m.AuthenticData}, {"cd", m.CheckingDisabled},
+	} {
+		if f.set {
+			flags = append(flags, f.name)
+		}
+	}
+	additional := len(m.Additional)
+	if m.EDNS != nil {
+		additional++
+	}
+	fmt.Fprintf(&sb, ";; flags: %s; QUERY: %d, ANSWER: %d, AUTHORITY: %d, ADDITIONAL: %d\n",
+		strings.Join(flags, " "), len(m.Questions), len(m.Answer), len(m.Authority), additional)
+
+	if m.EDNS != nil {
+		sb.WriteString("\n;; OPT PSEUDOSECTION:\n")
+		ednsFlags := ""
+		if m.EDNS.DNSSECOK {
+			ednsFlags = " do"
+		}
+		fmt.Fprintf(&sb, "; EDNS: version: %d, flags:%s; udp: %d\n", m.EDNS.Version, ednsFlags, m.EDNS.UDPSize)
+		for _, opt := range m.EDNS.Options {
+			fmt.Fprintf(&sb, "; %s: %s\n", OptionString(opt.Code), optionText(opt))
+		}
+	}
+
+	sb.WriteString("\n;; QUESTION SECTION:\n")
+	for _, q := range m.Questions {
+		fmt.Fprintf(&sb, ";%s\t\t%s\t%s\n", q.Name, ClassString(q.Class), TypeString(q.Type))
+	}
+	for _, section := range []struct {
+		name string
+		rrs  []RR
+	}{{"ANSWER", m.Answer}, {"AUTHORITY", m.Authority}, {"ADDITIONAL", m.Additional}} {
+		if len(section.rrs) == 0 {
+			continue
+		}
+		fmt.Fprintf(&sb, "\n;; %s SECTION:\n", section.name)
+		for _, rr := range section.rrs {
+			sb.WriteString(rr.String())
+			sb.WriteByte('\n')
+		}
+	}
+
+	host, port, _ := net.SplitHostPort(r.Server)
+	fmt.Fprintf(&sb, "\n;; Query time: %d msec\n", r.Timing.Query.Milliseconds())
+	fmt.Fprintf(&sb, ";; SERVER: %s#%s(%s) (%s)\n", host, port, host, strings.ToUpper(r.Transport))
+	fmt.Fprintf(&sb, ";; MSG SIZE  rcvd: %d\n", r.Size)
+	return sb.String()
+}
+
+// String 返回记录的主文件格式文本
+func (rr RR) String() string {
+	_, text, err := rr.Fields()
+	if err != nil {
+		text = genericText(rr.Data)
+	}
+	return fmt.Sprintf("%s\t%d\t%s\t%s\t%s", rr.Name, rr.TTL, ClassString(rr.Class), TypeString(rr.Type), text)
+}
+
+func opcodeString(op uint8) string {
+	switch op {
+	case 0:
+		return "QUERY"
+	case 1:
+		return "IQUERY"
+	case 2:
+		return "STATUS"
+	case 4:
+		return "NOTIFY"
+	case 5:
+		return "UPDATE"
+	}
+	return fmt.Sprintf("OPCODE%d", op)
+}
@@ -0,0 +1,325 @@
+package dnsprobe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"linkmaster-node/internal/netutil"
+
+	"github.com/miekg/dns"
+)
+
+// maxTraceSteps 单次追踪最多发送的查询数量（含失败的尝试）
+const maxTraceSteps = 48
+
+// MaxTraceTime 单次追踪的总时间上限，超时后返回已完成的步骤
+const MaxTraceTime = 30 * time.Second
+
+// 追踪中每次查询的结果
+const (
+	TraceReferral = "referral" // 引用到下一级区域
+	TraceAnswer   = "answer"   // 得到应答记录（含CNAME）
+	TraceNXDomain = "nxdomain" // 域名不存在
+	TraceNoData   = "nodata"   // 域名存在但没有该类型的记录
+	TraceLame     = "lame"     // 服务器对该区域没有权威（lame delegation）
+	TraceError    = "error"    // 超时、连接失败等
+)
+
+// rootHints 内置的根提示（IANA named.root）
+var rootHints = []struct {
+	name, ipv4, ipv6 string
+}{
+	{"a.root-servers.net.", "198.41.0.4", "2001:503:ba3e::2:30"},
+	{"b.root-servers.net.", "170.247.170.2", "2801:1b8:10::b"},
+	{"c.root-servers.net.", "192.33.4.12", "2001:500:2::c"},
+	{"d.root-servers.net.", "199.7.91.13", "2001:500:2d::d"},
+	{"e.root-servers.net.", "192.203.230.10", "2001:500:a8::e"},
+	{"f.root-servers.net.", "192.5.5.241", "2001:500:2f::f"},
+	{"g.root-servers.net.", "192.112.36.4", "2001:500:12::d0d"},
+	{"h.root-servers.net.", "198.97.190.53", "2001:500:1::53"},
+	{"i.root-servers.net.", "192.36.148.17", "2001:7fe::53"},
+	{"j.root-servers.net.", "192.58.128.30", "2001:503:c27::2:30"},
+	{"k.root-servers.net.", "193.0.14.129", "2001:7fd::1"},
+	{"l.root-servers.net.", "199.7.83.42", "2001:500:9f::42"},
+	{"m.root-servers.net.", "202.12.27.33", "2001:dc3::35"},
+}
+
+// RootServers 返回按地址族选取的根服务器（auto 时使用IPv4）；hints 为配置的根服务器IP，非空时代替内置的根提示
+func RootServers(hints []string, family netutil.Family) []NameServer {
+	var servers []NameServer
+	if len(hints) > 0 {
+		for _, h := range hints {
+			ip := net.ParseIP(strings.TrimSpace(h))
+			if ip == nil || !family.Match(ip) {
+				continue
+			}
+			servers = append(servers, NameServer{Name: ip.String(), IP: ip})
+		}
+		return servers
+	}
+	for _, h := range rootHints {
+		addr := h.ipv4
+		if family == netutil.FamilyIPv6 {
+			addr = h.ipv6
+		}
+		servers = append(servers, NameServer{Name: h.name, IP: net.ParseIP(addr)})
+	}
+	return servers
+}
+
+// Referral 一次引用：下一级区域、其NS集合以及附加部分的胶水记录
+type Referral struct {
+	Zone string
+	NS   []string
+	Glue []RR // A/AAAA 记录
+}
+
+// TraceStep 追踪中的一次查询
+type TraceStep struct {
+	Zone       string // 被查询服务器所服务的区域
+	NameServer NameServer
+	Glue       bool // 服务器地址来自上一级的胶水记录或根提示，否则通过系统DNS解析
+	Status     string
+	Reason     string // lame 的原因
+	Response   *Response
+	Err        error
+	Referral   *Referral
+}
+
+// Trace 从根服务器开始的迭代解析过程
+type Trace struct {
+	Steps []TraceStep
+	Final *Response // 得到最终应答（answer/nxdomain/nodata）的查询
+}
+
+// TraceQuery 从 roots 开始迭代解析 opts 中的域名，逐级跟随引用直到得到权威应答；
+// 每个区域的服务器按随机顺序尝试，超时或 lame 时换下一个。出错或超过 MaxTraceTime 时也返回已完成的步骤
+func TraceQuery(ctx context.Context, opts Options, roots []NameServer) (*Trace, error) {
+	ctx, cancel := context.WithTimeout(ctx, MaxTraceTime)
+	defer cancel()
+
+	t := &Trace{}
+	zone := "."
+	servers := roots
+	for {
+		servers = shuffleNameServers(servers)
+		var referral *Referral
+		for _, ns := range servers {
+			if len(t.Steps) >= maxTraceSteps {
+				return t, errors.New("追踪步骤过多")
+			}
+			if ctx.Err() != nil {
+				return t, traceContextError(ctx)
+			}
+			step := traceExchange(ctx, opts, zone, ns)
+			t.Steps = append(t.Steps, step)
+			if step.Status == TraceError && ctx.Err() != nil {
+				return t, traceContextError(ctx)
+			}
+			switch step.Status {
+			case TraceAnswer, TraceNXDomain, TraceNoData:
+				t.Final = step.Response
+				return t, nil
+			case TraceReferral:
+				referral = step.Referral
+			}
+			if referral != nil {
+				break
+			}
+		}
+		if referral == nil {
+			return t, fmt.Errorf("区域 %s 的服务器均未给出有效应答", zone)
+		}
+		zone = referral.Zone
+		servers = referralServers(referral, opts.Family)
+		if len(servers) == 0 {
+			return t, fmt.Errorf("区域 %s 没有可用的权威服务器", zone)
+		}
+	}
+}
+
+// traceContextError 追踪被取消或超过总时间上限时的错误
+func traceContextError(ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("追踪超过 %d 秒未完成", int(MaxTraceTime.Seconds()))
+	}
+	return ctx.Err()
+}
+
+// traceExchange 向一个服务器发送不递归的查询并判断应答的类型
+func traceExchange(ctx context.Context, opts Options, zone string, ns NameServer) TraceStep {
+	step := TraceStep{Zone: zone, NameServer: ns, Glue: ns.IP != nil}
+	if ns.IP == nil {
+		ip, err := opts.Family.LookupPrimaryIP(ctx, strings.TrimSuffix(ns.Name, "."))
+		if err != nil {
+			step.Status = TraceError
+			step.Err = fmt.Errorf("解析 %s 的地址失败: %v", ns.Name, err)
+			return step
+		}
+		step.NameServer.IP = ip
+	}
+
+	start := time.Now()
+	query := opts.NewMessage(false)
+	resp, err := Exchange(ctx, step.NameServer.Address(), query, opts.Timeout, opts.TCP)
+	if err != nil {
+		step.Status = TraceError
+		step.Err = err
+		return step
+	}
+	resp.ServerName = ns.Name
+	resp.Timing.Total = time.Since(start)
+	step.Response = resp
+
+	msg := resp.Msg
+	switch rcode := msg.Rcode(); {
+	case rcode == 3:
+		step.Status = TraceNXDomain
+		return step
+	case rcode != 0:
+		step.Status = TraceLame
+		step.Reason = "响应码 " + RcodeString(rcode)
+		return step
+	}
+	for _, rr := range msg.Answer {
+		if strings.EqualFold(rr.Name, opts.Name) {
+			step.Status = TraceAnswer
+			return step
+		}
+	}
+
+	// 权威部分中比当前区域更接近查询域名的NS记录即为引用
+	var child string
+	var names []string
+	upward := false
+	for _, rr := range msg.Authority {
+		if rr.Type != TypeNS {
+			continue
+		}
+		owner := strings.ToLower(rr.Name)
+		if !inZone(owner, zone) || owner == strings.ToLower(zone) || !inZone(opts.Name, owner) {
+			upward = true
+			continue
+		}
+		if child != "" && owner != child {
+			continue
+		}
+		child = owner
+		name, _, _ := dns.UnpackDomainName(rr.Data, 0)
+		names = append(names, name)
+	}
+	if child != "" {
+		step.Status = TraceReferral
+		step.Referral = newReferral(child, names, msg.Additional)
+		return step
+	}
+
+	switch {
+	case msg.Authoritative:
+		step.Status = TraceNoData
+	case upward:
+		step.Status = TraceLame
+		step.Reason = "向上引用"
+	default:
+		step.Status = TraceLame
+		step.Reason = "非权威应答且没有引用"
+	}
+	return step
+}
+
+// newReferral 从附加部分中找出NS名称对应的胶水记录
+func newReferral(zone string, names []string, additional []RR) *Referral {
+	sort.Strings(names)
+	ref := &Referral{Zone: zone, NS: names}
+	for _, rr := range additional {
+		if rr.Type != TypeA && rr.Type != TypeAAAA {
+			continue
+		}
+		for _, name := range names {
+			if strings.EqualFold(rr.Name, name) {
+				ref.Glue = append(ref.Glue, rr)
+				break
+			}
+		}
+	}
+	return ref
+}
+
+// referralServers 返回引用中下一级区域的服务器，地址按地址族从胶水记录中选取；
+// 没有可用胶水的NS在查询时再通过系统DNS解析
+func referralServers(ref *Referral, family netutil.Family) []NameServer {
+	servers := make([]NameServer, 0, len(ref.NS))
+	for _, name := range ref.NS {
+		var ips []net.IP
+		for _, rr := range ref.Glue {
+			if strings.EqualFold(rr.Name, name) && (len(rr.Data) == net.IPv4len || len(rr.Data) == net.IPv6len) {
+				ips = append(ips, net.IP(rr.Data))
+			}
+		}
+		servers = append(servers, NameServer{Name: name, IP: family.PickIP(ips)})
+	}
+	return servers
+}
+
+// shuffleNameServers 打乱服务器顺序，有地址的服务器保持在前
+func shuffleNameServers(servers []NameServer) []NameServer {
+	out := append([]NameServer(nil), servers...)
+	rand.Shuffle(len(out), func(i, j int) { out[i], out[j] = out[j], out[i] })
+	sort.SliceStable(out, func(i, j int) bool { return out[i].IP != nil && out[j].IP == nil })
+	return out
+}
+
+// inZone 判断 name 是否等于 zone 或位于 zone 之下
+func inZone(name, zone string) bool {
+	name, zone = strings.ToLower(Fqdn(name)), strings.ToLower(Fqdn(zone))
+	return zone == "." || name == zone || strings.HasSuffix(name, "."+zone)
+}
+
+// Map 返回一次查询的结构化结果，应答相关字段与 ceDns 的结构相同
+func (s TraceStep) Map() map[string]interface{} {
+	step := map[string]interface{}{}
+	if s.Response != nil {
+		for k, v := range s.Response.Map() {
+			step[k] = v
+		}
+	}
+	step["zone"] = s.Zone
+	step["server_name"] = s.NameServer.Name
+	step["ip"] = ""
+	if s.NameServer.IP != nil {
+		step["ip"] = s.NameServer.IP.String()
+		if _, ok := step["server"]; !ok {
+			step["server"] = s.NameServer.Address()
+		}
+	}
+	step["glue"] = s.Glue
+	step["status"] = s.Status
+	if s.Reason != "" {
+		step["reason"] = s.Reason
+	}
+	if s.Err != nil {
+		step["error"] = s.Err.Error()
+	}
+	if s.Referral != nil {
+		glue := make([]map[string]interface{}, 0, len(s.Referral.Glue))
+		for _, rr := range s.Referral.Glue {
+			glue = append(glue, map[string]interface{}{
+				"name": rr.Name,
+				"type": TypeString(rr.Type),
+				"ip":   net.IP(rr.Data).String(),
+			})
+		}
+		step["referral"] = map[string]interface{}{
+			"zone": s.Referral.Zone,
+			"ns":   s.Referral.NS,
+			"glue": glue,
+		}
+	}
+	return step
+}
@@ -0,0 +1,911 @@
+package dnsprobe
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// DNSSEC 校验状态（RFC 4035 第4.3节）
+const (
+	StatusSecure        = "secure"        // 从信任锚到记录的签名链完整有效
+	StatusInsecure      = "insecure"      // 已证明记录所在区域未签名（非安全委派或DS的算法不受支持）
+	StatusBogus         = "bogus"         // 签名链应当存在但校验失败
+	StatusIndeterminate = "indeterminate" // 无法获取校验所需的记录
+)
+
+// statusRank 用于合并多个状态，数值大的优先
+var statusRank = map[string]int{
+	StatusSecure:        0,
+	StatusInsecure:      1,
+	StatusIndeterminate: 2,
+	StatusBogus:         3,
+}
+
+// RRsetResult 一个记录集合的校验结果；Link 为失败的环节，如 "example.com. DS"
+type RRsetResult struct {
+	Section   string
+	Name      string
+	Type      uint16
+	Status    string
+	Signer    string
+	KeyTag    uint16
+	Algorithm uint8
+	Wildcard  bool
+	Link      string
+	Reason    string
+
+	labels int // 通过校验的RRSIG的标签数，通配符证明中用于确定最近的祖先
+}
+
+// ZoneResult 信任链中一个区域的校验结果
+type ZoneResult struct {
+	Zone   string
+	Status string
+	Link   string
+	Reason string
+	DS     []DS
+	Keys   []RR // 区域的DNSKEY，仅 secure 时已通过校验
+}
+
+// Validation 一次应答的DNSSEC校验结果
+type Validation struct {
+	Status string
+	AD     bool // 上游服务器应答中的AD标志
+	RRsets []RRsetResult
+	Zones  []*ZoneResult // 按从根到下的顺序
+
+	answer, authority []string // 每条记录的状态，与应答中的顺序对应
+}
+
+// Validate 在本地校验应答的信任链：通过 opts 指定的服务器（设置DO和CD标志）获取各级区域的DS和DNSKEY，
+// 从根区信任锚开始逐级校验。有应答记录时校验应答部分，否则校验权威部分（SOA、NSEC/NSEC3）的签名，
+// 并检查NSEC/NSEC3是否证明了名称或类型不存在；通配符展开的应答还需证明没有更近的匹配
+func Validate(ctx context.Context, opts Options, resp *Response) *Validation {
+	v := &validator{
+		ctx:     ctx,
+		opts:    opts,
+		now:     time.Now(),
+		fetches: map[string]fetchResult{},
+		zones:   map[string]*ZoneResult{},
+	}
+	return v.validate(resp.Msg)
+}
+
+// validate 校验应答报文并合并各记录集合的状态
+func (v *validator) validate(msg *Message) *Validation {
+	result := &Validation{AD: msg.AuthenticData}
+
+	if v.opts.Class != ClassIN {
+		result.Status = StatusIndeterminate
+		result.RRsets = append(result.RRsets, RRsetResult{Status: StatusIndeterminate, Reason: "仅支持 IN 类别的校验"})
+		return result
+	}
+
+	result.answer = v.section(result, "answer", msg.Answer)
+	wildcard := false
+	for _, r := range result.RRsets {
+		wildcard = wildcard || r.Wildcard
+	}
+	// 否定应答和通配符展开的应答需要权威部分中的NSEC/NSEC3证明
+	if len(msg.Answer) == 0 || wildcard {
+		result.authority = v.section(result, "authority", msg.Authority)
+	}
+	v.checkDenial(result, msg)
+
+	result.Status = StatusSecure
+	if len(result.RRsets) == 0 {
+		result.Status = StatusIndeterminate
+	}
+	for _, r := range result.RRsets {
+		if statusRank[r.Status] > statusRank[result.Status] {
+			result.Status = r.Status
+		}
+	}
+	for _, z := range v.zones {
+		result.Zones = append(result.Zones, z)
+	}
+	sort.Slice(result.Zones, func(i, j int) bool {
+		return strings.Count(result.Zones[i].Zone, ".") < strings.Count(result.Zones[j].Zone, ".") ||
+			strings.Count(result.Zones[i].Zone, ".") == strings.Count(result.Zones[j].Zone, ".") && result.Zones[i].Zone < result.Zones[j].Zone
+	})
+	return result
+}
+
+// checkDenial 检查否定应答（NXDOMAIN、NODATA）和通配符展开的应答中对名称或类型不存在的证明，
+// 所在区域已签名而缺少证明时加入一条 bogus 的结果
+func (v *validator) checkDenial(result *Validation, msg *Message) {
+	denials := secureDenials(result.RRsets, msg.Authority)
+	for _, r := range result.RRsets {
+		if r.Section != "answer" || !r.Wildcard {
+			continue
+		}
+		if err := proveWildcard(denials, r.Name, r.labels); err != nil {
+			result.RRsets = append(result.RRsets, RRsetResult{Section: "authority", Name: r.Name, Type: r.Type, Status: StatusBogus, Link: r.Name + " " + TypeString(r.Type), Reason: err.Error()})
+		}
+	}
+
+	name, qtype := v.opts.Name, v.opts.Type
+	if len(msg.Questions) > 0 {
+		name, qtype = msg.Questions[0].Name, msg.Questions[0].Type
+	}
+	rcode := msg.Rcode()
+	if len(msg.Answer) > 0 || name == "" || rcode != 0 && rcode != 3 {
+		return
+	}
+	name = Fqdn(name)
+	denied := RRsetResult{Section: "authority", Name: name, Type: qtype, Link: name + " " + TypeString(qtype)}
+
+	// 只有权威部分的签名均有效时才要求证明；权威部分为空时按查询名称所在的区域判断
+	status, found := StatusSecure, false
+	for _, r := range result.RRsets {
+		if r.Section == "authority" {
+			found = true
+			if statusRank[r.Status] > statusRank[status] {
+				status = r.Status
+			}
+		}
+	}
+	if !found {
+		zone, err := v.findZone(name)
+		if err != nil {
+			denied.Status, denied.Reason = StatusIndeterminate, err.Error()
+			result.RRsets = append(result.RRsets, denied)
+			return
+		}
+		z := v.zone(zone)
+		denied.Status, denied.Reason = z.Status, z.Reason
+		if z.Status == StatusSecure {
+			denied.Status, denied.Reason = StatusBogus, "区域 "+zone+" 已签名，但否定应答缺少NSEC/NSEC3记录"
+		} else {
+			denied.Link = z.Link
+		}
+		result.RRsets = append(result.RRsets, denied)
+		return
+	}
+	if status != StatusSecure {
+		return
+	}
+
+	var err error
+	if rcode == 3 {
+		err = proveNXDomain(denials, name)
+	} else {
+		err = proveNoData(denials, name, qtype)
+	}
+	if err != nil {
+		denied.Status, denied.Reason = StatusBogus, err.Error()
+		result.RRsets = append(result.RRsets, denied)
+	}
+}
+
+type fetchResult struct {
+	msg *Message
+	err error
+}
+
+type validator struct {
+	ctx     context.Context
+	opts    Options
+	now     time.Time
+	fetches map[string]fetchResult
+	zones   map[string]*ZoneResult
+}
+
+// section 校验一个部分中的所有记录集合，返回每条记录的状态
+func (v *validator) section(result *Validation, name string, rrs []RR) []string {
+	statuses := make([]string, len(rrs))
+	sets, sigs := groupRRsets(rrs)
+	for _, set := range sets {
+		r := v.rrset(set, sigs[rrsetKey(set[0].Name, set[0].Type)])
+		r.Section = name
+		result.RRsets = append(result.RRsets, r)
+		for i, rr := range rrs {
+			if rr.Type != TypeRRSIG && strings.EqualFold(rr.Name, r.Name) && rr.Type == r.Type {
+				statuses[i] = r.Status
+			}
+		}
+	}
+	// RRSIG 的状态与其覆盖的记录集合相同
+	for i, rr := range rrs {
+		if rr.Type != TypeRRSIG || len(rr.Data) < 2 {
+			continue
+		}
+		covered := binary.BigEndian.Uint16(rr.Data)
+		for j, other := range rrs {
+			if other.Type == covered && strings.EqualFold(other.Name, rr.Name) {
+				statuses[i] = statuses[j]
+				break
+			}
+		}
+	}
+	return statuses
+}
+
+// rrset 校验一个记录集合
+func (v *validator) rrset(rrs []RR, sigs []RR) RRsetResult {
+	r := RRsetResult{Name: rrs[0].Name, Type: rrs[0].Type}
+	link := rrs[0].Name + " " + TypeString(rrs[0].Type)
+
+	signer := ""
+	for _, s := range sigs {
+		if sig, err := parseRRSIG(s); err == nil && inZone(r.Name, sig.Signer) {
+			signer = sig.Signer
+			break
+		}
+	}
+	if signer == "" {
+		// 没有签名：所在区域未签名时为 insecure，否则签名被剥离
+		zone, err := v.findZone(r.Name)
+		if err != nil {
+			r.Status, r.Link, r.Reason = StatusIndeterminate, link, err.Error()
+			return r
+		}
+		z := v.zone(zone)
+		if z.Status == StatusSecure {
+			r.Status, r.Link, r.Reason = StatusBogus, link, "区域 "+zone+" 已签名，但记录缺少RRSIG"
+			return r
+		}
+		r.Status, r.Link, r.Reason = z.Status, z.Link, z.Reason
+		return r
+	}
+
+	r.Signer = signer
+	z := v.zone(signer)
+	if z.Status != StatusSecure {
+		r.Status, r.Link, r.Reason = z.Status, z.Link, z.Reason
+		return r
+	}
+	sig, err := v.verify(rrs, sigs, z.Keys)
+	switch {
+	case errors.Is(err, errUnsupported):
+		// 区域已通过DS证明使用受支持的算法，只有不支持的签名说明可用签名被剥离（RFC 6840 第5.2节）
+		r.Status, r.Link, r.Reason = StatusBogus, link, "区域 "+signer+" 已签名，但记录的签名算法均不受支持"
+	case err != nil:
+		r.Status, r.Link, r.Reason = StatusBogus, link, err.Error()
+	default:
+		r.Status = StatusSecure
+		r.KeyTag, r.Algorithm = sig.KeyTag, sig.Algorithm
+		r.Wildcard = int(sig.Labels) < labelCount(r.Name)
+		r.labels = int(sig.Labels)
+	}
+	return r
+}
+
+// verify 用 keys 中的任一密钥校验记录集合上的任一签名，返回通过校验的签名；
+// 签名均使用不支持的算法时返回 errUnsupported
+func (v *validator) verify(rrs, sigs, keys []RR) (RRSIG, error) {
+	if len(sigs) == 0 {
+		return RRSIG{}, errors.New("缺少RRSIG")
+	}
+	var lastErr error
+	supported := false
+	for _, s := range sigs {
+		sig, err := parseRRSIG(s)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if sig.TypeCovered != rrs[0].Type || !algorithmSupported(sig.Algorithm) {
+			continue
+		}
+		supported = true
+		matched := false
+		for _, key := range keys {
+			if len(key.Data) < 4 || KeyTag(key.Data) != sig.KeyTag || key.Data[3] != sig.Algorithm {
+				continue
+			}
+			matched = true
+			if err := sig.verify(rrs, key, v.now); err != nil {
+				lastErr = err
+				continue
+			}
+			return sig, nil
+		}
+		if !matched && lastErr == nil {
+			lastErr = fmt.Errorf("没有与RRSIG对应的DNSKEY（key tag %d）", sig.KeyTag)
+		}
+	}
+	if !supported && lastErr == nil {
+		return RRSIG{}, errUnsupported
+	}
+	return RRSIG{}, lastErr
+}
+
+// zone 校验区域的DNSKEY是否可信，结果按区域缓存
+func (v *validator) zone(name string) *ZoneResult {
+	name = strings.ToLower(Fqdn(name))
+	if z, ok := v.zones[name]; ok {
+		return z
+	}
+	// 先放入占位结果，防止异常数据导致循环
+	z := &ZoneResult{Zone: name, Status: StatusIndeterminate, Reason: "信任链存在循环"}
+	v.zones[name] = z
+	if name == "." {
+		z.DS = rootTrustAnchors()
+		z.Status, z.Link, z.Reason = StatusIndeterminate, "", ""
+		v.checkKeys(z)
+		return z
+	}
+	v.checkDelegation(z)
+	return z
+}
+
+// checkDelegation 通过上级区域中的DS记录校验区域
+func (v *validator) checkDelegation(z *ZoneResult) {
+	link := z.Zone + " DS"
+	msg, err := v.fetch(z.Zone, TypeDS)
+	if err != nil {
+		z.Status, z.Link, z.Reason = StatusIndeterminate, link, err.Error()
+		return
+	}
+	sets, sigs := groupRRsets(msg.Answer)
+	var dsSet, dsSigs []RR
+	for _, set := range sets {
+		if set[0].Type == TypeDS && strings.EqualFold(set[0].Name, z.Zone) {
+			dsSet, dsSigs = set, sigs[rrsetKey(set[0].Name, TypeDS)]
+		}
+	}
+
+	// 上级区域：DS 或否定应答签名者，没有签名时通过SOA查找
+	parent := ""
+	for _, s := range append(append([]RR(nil), dsSigs...), msg.Authority...) {
+		if s.Type != TypeRRSIG {
+			continue
+		}
+		if sig, err := parseRRSIG(s); err == nil && sig.Signer != z.Zone && inZone(z.Zone, sig.Signer) {
+			parent = sig.Signer
+			break
+		}
+	}
+	if parent == "" {
+		parent, err = v.findZone(parentName(z.Zone))
+		if err != nil {
+			z.Status, z.Link, z.Reason = StatusIndeterminate, link, err.Error()
+			return
+		}
+	}
+	p := v.zone(parent)
+	if p.Status != StatusSecure {
+		z.Status, z.Link, z.Reason = p.Status, p.Link, p.Reason
+		return
+	}
+
+	if len(dsSet) == 0 {
+		if err := v.proveNoDS(z.Zone, p, msg); err != nil {
+			z.Status, z.Link, z.Reason = StatusBogus, link, "无法证明DS记录不存在: "+err.Error()
+			return
+		}
+		z.Status, z.Link, z.Reason = StatusInsecure, link, "上级区域 "+p.Zone+" 证明没有DS记录（非安全委派）"
+		return
+	}
+	if _, err := v.verify(dsSet, dsSigs, p.Keys); err != nil {
+		if errors.Is(err, errUnsupported) {
+			err = errors.New("上级区域 " + p.Zone + " 已签名，但DS的签名算法均不受支持")
+		}
+		z.Status, z.Link, z.Reason = StatusBogus, link, err.Error()
+		return
+	}
+	for _, rr := range dsSet {
+		if ds, err := parseDS(rr); err == nil {
+			z.DS = append(z.DS, ds)
+		}
+	}
+	v.checkKeys(z)
+}
+
+// checkKeys 用区域的DS（根区为信任锚）校验其DNSKEY集合
+func (v *validator) checkKeys(z *ZoneResult) {
+	link := z.Zone + " DNSKEY"
+	var usable []DS
+	for _, ds := range z.DS {
+		if ds.supported() {
+			usable = append(usable, ds)
+		}
+	}
+	if len(usable) == 0 {
+		z.Status, z.Link, z.Reason = StatusInsecure, z.Zone+" DS", "DS的算法或摘要类型均不受支持"
+		return
+	}
+
+	msg, err := v.fetch(z.Zone, TypeDNSKEY)
+	if err != nil {
+		z.Status, z.Link, z.Reason = StatusIndeterminate, link, err.Error()
+		return
+	}
+	sets, sigs := groupRRsets(msg.Answer)
+	var keys, keySigs []RR
+	for _, set := range sets {
+		if set[0].Type == TypeDNSKEY && strings.EqualFold(set[0].Name, z.Zone) {
+			keys, keySigs = set, sigs[rrsetKey(set[0].Name, TypeDNSKEY)]
+		}
+	}
+	z.Keys = keys
+	if len(keys) == 0 {
+		z.Status, z.Link, z.Reason = StatusIndeterminate, link, "未获取到DNSKEY记录（上游服务器可能不支持DNSSEC）"
+		return
+	}
+
+	var anchored []RR
+	for _, key := range keys {
+		for _, ds := range usable {
+			if ds.matches(key) {
+				anchored = append(anchored, key)
+				break
+			}
+		}
+	}
+	if len(anchored) == 0 {
+		z.Status, z.Link, z.Reason = StatusBogus, link, "没有与DS匹配的DNSKEY"
+		return
+	}
+	if _, err := v.verify(keys, keySigs, anchored); err != nil {
+		z.Status, z.Link, z.Reason = StatusBogus, link, "DNSKEY集合的签名无效: "+err.Error()
+		return
+	}
+	z.Status, z.Link, z.Reason = StatusSecure, "", ""
+}
+
+// proveNoDS 检查上级区域对DS不存在的证明（RFC 4035 第5.2节、RFC 5155 第8.6节）：
+// 经过签名的NSEC在该名称处没有DS类型，或NSEC3精确匹配且没有DS类型、或覆盖该名称且设置了 opt-out
+func (v *validator) proveNoDS(zone string, parent *ZoneResult, msg *Message) error {
+	sets, sigs := groupRRsets(msg.Authority)
+	found := false
+	for _, set := range sets {
+		if set[0].Type != TypeNSEC && set[0].Type != TypeNSEC3 {
+			continue
+		}
+		found = true
+		if _, err := v.verify(set, sigs[rrsetKey(set[0].Name, set[0].Type)], parent.Keys); err != nil {
+			return fmt.Errorf("%s %s 的签名无效: %v", set[0].Name, TypeString(set[0].Type), err)
+		}
+		for _, rr := range set {
+			if ok := denialCovers(rr, zone, parent.Zone); ok {
+				return nil
+			}
+		}
+	}
+	if !found {
+		return errors.New("应答中没有NSEC/NSEC3记录")
+	}
+	return errors.New("NSEC/NSEC3记录未证明DS不存在")
+}
+
+// denialCovers 判断一条NSEC/NSEC3记录是否证明 name 处没有DS记录
+func denialCovers(rr RR, name, zone string) bool {
+	d, ok := parseDenial(rr)
+	if !ok || d.nsec3 && !strings.EqualFold(d.zone, Fqdn(zone)) {
+		return false
+	}
+	if d.matches(name) {
+		return !d.hasType(TypeDS) && d.hasType(TypeNS)
+	}
+	// opt-out：覆盖该散列的NSEC3表示其间可能存在未签名的委派
+	return d.nsec3 && d.optOut && d.covers(name)
+}
+
+// denial 解析后的NSEC/NSEC3记录
+type denial struct {
+	nsec3      bool
+	owner      string // NSEC 为小写域名，NSEC3 为散列（base32hex）
+	next       string
+	zone       string // 记录所在的区域，NSEC 的由签名者确定
+	types      []uint16
+	optOut     bool
+	iterations uint16
+	salt       []byte
+}
+
+// parseDenial 解析NSEC/NSEC3记录，NSEC3 只支持 SHA-1 散列
+func parseDenial(rr RR) (denial, bool) {
+	r, err := rr.toDNS()
+	if err != nil {
+		return denial{}, false
+	}
+	switch r := r.(type) {
+	case *dns.NSEC:
+		return denial{owner: strings.ToLower(Fqdn(rr.Name)), next: strings.ToLower(r.NextDomain), types: r.TypeBitMap}, true
+	case *dns.NSEC3:
+		salt, err := hex.DecodeString(r.Salt)
+		if r.Hash != dns.SHA1 || err != nil {
+			return denial{}, false
+		}
+		owner, zone, _ := strings.Cut(rr.Name, ".")
+		return denial{
+			nsec3:      true,
+			owner:      strings.ToUpper(owner),
+			next:       r.NextDomain,
+			zone:       strings.ToLower(Fqdn(zone)),
+			types:      r.TypeBitMap,
+			optOut:     r.Flags&1 != 0,
+			iterations: r.Iterations,
+			salt:       salt,
+		}, true
+	}
+	return denial{}, false
+}
+
+// key 返回 name 在记录链中的位置：NSEC 为小写域名，NSEC3 为散列
+func (d denial) key(name string) (string, bool) {
+	if !d.nsec3 {
+		return strings.ToLower(Fqdn(name)), true
+	}
+	hash, err := nsec3Hash(name, d.iterations, d.salt)
+	return hash, err == nil
+}
+
+func (d denial) less(a, b string) bool {
+	if d.nsec3 {
+		return a < b
+	}
+	return compareNames(a, b) < 0
+}
+
+// matches 判断记录的所有者是否为 name
+func (d denial) matches(name string) bool {
+	key, ok := d.key(name)
+	return ok && key == d.owner
+}
+
+// covers 判断 name 是否落在记录的所有者和下一个名称之间（不含两端），区域中最后一条记录的下一个名称回绕到开头
+func (d denial) covers(name string) bool {
+	key, ok := d.key(name)
+	if !ok || key == d.owner {
+		return false
+	}
+	if d.less(d.owner, d.next) {
+		return d.less(d.owner, key) && d.less(key, d.next)
+	}
+	return d.less(d.owner, key) || d.less(key, d.next)
+}
+
+func (d denial) hasType(t uint16) bool {
+	for _, typ := range d.types {
+		if typ == t {
+			return true
+		}
+	}
+	return false
+}
+
+// delegation 判断记录是否位于上级区域一侧的委派点，这样的记录不能证明委派之下的名称（RFC 6840 第4.1节）
+func (d denial) delegation() bool {
+	return d.hasType(TypeDNAME) || d.hasType(TypeNS) && !d.hasType(TypeSOA)
+}
+
+// noData 判断记录是否证明 name 存在但没有 qtype 类型的记录，也没有CNAME
+func (d denial) noData(name string, qtype uint16) bool {
+	if !d.matches(name) || d.hasType(qtype) || d.hasType(TypeCNAME) {
+		return false
+	}
+	// 委派点只能证明DS不存在（RFC 6840 第4.4节）
+	return qtype == TypeDS || !d.hasType(TypeNS) || d.hasType(TypeSOA)
+}
+
+// secureDenials 返回权威部分中已通过校验的NSEC/NSEC3记录，区域取自签名者
+func secureDenials(results []RRsetResult, rrs []RR) []denial {
+	var denials []denial
+	for _, r := range results {
+		if r.Section != "authority" || r.Status != StatusSecure || r.Type != TypeNSEC && r.Type != TypeNSEC3 {
+			continue
+		}
+		for _, rr := range rrs {
+			if rr.Type != r.Type || !strings.EqualFold(rr.Name, r.Name) {
+				continue
+			}
+			d, ok := parseDenial(rr)
+			if !ok || d.nsec3 && d.zone != r.Signer {
+				continue
+			}
+			d.zone = r.Signer
+			denials = append(denials, d)
+		}
+	}
+	return denials
+}
+
+// closestEncloser 找出 qname 最近的存在的祖先（RFC 5155 第7.2.1节），同时证明 qname 本身不存在：
+// NSEC 覆盖 qname 时为 qname 与该记录两端名称的最长公共后缀；NSEC3 为有记录匹配的最长祖先，
+// 且其下一个更近的名称被覆盖。optOut 为覆盖下一个更近名称的NSEC3是否设置了 opt-out
+func closestEncloser(denials []denial, qname string) (ce string, optOut bool, err error) {
+	qname = strings.ToLower(Fqdn(qname))
+	for _, d := range denials {
+		if d.nsec3 || !inZone(qname, d.zone) || !d.covers(qname) || inZone(qname, d.owner) && d.delegation() {
+			continue
+		}
+		ce = commonAncestor(qname, d.owner)
+		if next := commonAncestor(qname, d.next); labelCount(next) > labelCount(ce) && inZone(next, d.zone) {
+			ce = next
+		}
+		return ce, false, nil
+	}
+
+	next := ""
+	for name := qname; ; name = parentName(name) {
+		for _, d := range denials {
+			if !d.nsec3 || !inZone(name, d.zone) || !d.matches(name) {
+				continue
+			}
+			if next == "" {
+				return "", false, fmt.Errorf("NSEC3证明 %s 存在", qname)
+			}
+			if d.delegation() {
+				return "", false, fmt.Errorf("NSEC3记录 %s 位于委派点", name)
+			}
+			for _, c := range denials {
+				if c.nsec3 && c.zone == d.zone && c.covers(next) {
+					return name, c.optOut, nil
+				}
+			}
+			return "", false, fmt.Errorf("没有覆盖 %s 的NSEC3记录", next)
+		}
+		if name == "." {
+			break
+		}
+		next = name
+	}
+	return "", false, fmt.Errorf("NSEC/NSEC3记录未证明 %s 不存在", qname)
+}
+
+// proveNXDomain 证明 qname 不存在（RFC 4035 第5.4节、RFC 5155 第8.4节）：
+// 证明 qname 本身不存在，并且最近的祖先下没有通配符
+func proveNXDomain(denials []denial, qname string) error {
+	ce, _, err := closestEncloser(denials, qname)
+	if err != nil {
+		return err
+	}
+	wildcard := "*." + strings.TrimPrefix(ce, ".")
+	for _, d := range denials {
+		if inZone(wildcard, d.zone) && d.covers(wildcard) {
+			return nil
+		}
+	}
+	return fmt.Errorf("NSEC/NSEC3记录未证明通配符 %s 不存在", wildcard)
+}
+
+// proveNoData 证明 qname 没有 qtype 类型的记录（RFC 4035 第5.4节、RFC 5155 第8.5-8.7节）：
+// 匹配 qname 的记录中没有该类型，或 qname 不存在且匹配的通配符中没有该类型；
+// 查询DS时覆盖下一个更近名称的NSEC3设置了 opt-out 也可以证明
+func proveNoData(denials []denial, qname string, qtype uint16) error {
+	for _, d := range denials {
+		if inZone(qname, d.zone) && d.noData(qname, qtype) {
+			return nil
+		}
+	}
+	ce, optOut, err := closestEncloser(denials, qname)
+	if err == nil {
+		if optOut && qtype == TypeDS {
+			return nil
+		}
+		wildcard := "*." + strings.TrimPrefix(ce, ".")
+		for _, d := range denials {
+			if inZone(wildcard, d.zone) && d.noData(wildcard, qtype) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("NSEC/NSEC3记录未证明 %s 没有 %s 记录", Fqdn(qname), TypeString(qtype))
+}
+
+// proveWildcard 证明通配符展开的记录 name 没有更近的匹配（RFC 4035 第5.3.4节、RFC 5155 第8.8节）：
+// labels 为RRSIG的标签数，NSEC 需覆盖 name 本身，NSEC3 需覆盖下一个更近的名称
+func proveWildcard(denials []denial, name string, labels int) error {
+	next := ancestor(name, labels+1)
+	for _, d := range denials {
+		if !inZone(name, d.zone) {
+			continue
+		}
+		if !d.nsec3 && d.covers(name) && !(inZone(name, d.owner) && d.delegation()) || d.nsec3 && d.covers(next) {
+			return nil
+		}
+	}
+	return fmt.Errorf("NSEC/NSEC3记录未证明 %s 没有更近的匹配", Fqdn(name))
+}
+
+// ancestor 返回 name 最后 n 个标签组成的域名
+func ancestor(name string, n int) string {
+	labels := strings.Split(strings.TrimSuffix(strings.ToLower(Fqdn(name)), "."), ".")
+	if n >= len(labels) {
+		return strings.ToLower(Fqdn(name))
+	}
+	if n <= 0 {
+		return "."
+	}
+	return strings.Join(labels[len(labels)-n:], ".") + "."
+}
+
+// commonAncestor 返回两个域名的最长公共后缀
+func commonAncestor(a, b string) string {
+	la := strings.Split(strings.TrimSuffix(strings.ToLower(Fqdn(a)), "."), ".")
+	lb := strings.Split(strings.TrimSuffix(strings.ToLower(Fqdn(b)), "."), ".")
+	n := 0
+	for n < len(la) && n < len(lb) && la[len(la)-1-n] == lb[len(lb)-1-n] && la[len(la)-1-n] != "" {
+		n++
+	}
+	return ancestor(a, n)
+}
+
+// compareNames 按规范顺序比较两个域名（RFC 4034 第6.1节）：从最右边的标签开始逐个按小写字节比较
+func compareNames(a, b string) int {
+	la, lb := wireLabels(a), wireLabels(b)
+	for i := 1; i <= len(la) && i <= len(lb); i++ {
+		if c := bytes.Compare(la[len(la)-i], lb[len(lb)-i]); c != 0 {
+			return c
+		}
+	}
+	return len(la) - len(lb)
+}
+
+// wireLabels 返回域名的小写标签（已处理转义），不含根标签
+func wireLabels(name string) [][]byte {
+	wire, err := canonicalName(name)
+	if err != nil {
+		return nil
+	}
+	var labels [][]byte
+	for off := 0; off < len(wire) && wire[off] != 0; off += 1 + int(wire[off]) {
+		labels = append(labels, wire[off+1:off+1+int(wire[off])])
+	}
+	return labels
+}
+
+// findZone 通过SOA查询找到 name 所在的区域
+func (v *validator) findZone(name string) (string, error) {
+	name = strings.ToLower(Fqdn(name))
+	if name == "." {
+		return ".", nil
+	}
+	msg, err := v.fetch(name, TypeSOA)
+	if err != nil {
+		return "", err
+	}
+	for _, section := range [][]RR{msg.Answer, msg.Authority} {
+		for _, rr := range section {
+			if rr.Type == TypeSOA && inZone(name, rr.Name) {
+				return strings.ToLower(rr.Name), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("无法确定 %s 所在的区域", name)
+}
+
+// fetch 向上游服务器查询校验所需的记录，设置DO和CD标志，结果在一次校验内缓存
+func (v *validator) fetch(name string, qtype uint16) (*Message, error) {
+	key := rrsetKey(name, qtype)
+	if f, ok := v.fetches[key]; ok {
+		return f.msg, f.err
+	}
+	o := v.opts
+	o.Name, o.Type, o.Class = Fqdn(name), qtype, ClassIN
+	o.Recursion, o.DNSSEC, o.CD, o.NoEDNS = true, true, true, false
+	var msg *Message
+	resp, err := Query(v.ctx, o)
+	if err == nil {
+		msg = resp.Msg
+		if rcode := msg.Rcode(); rcode != 0 && rcode != 3 {
+			msg, err = nil, fmt.Errorf("查询 %s %s 返回 %s", o.Name, TypeString(qtype), RcodeString(rcode))
+		}
+	} else {
+		err = fmt.Errorf("查询 %s %s 失败: %v", o.Name, TypeString(qtype), err)
+	}
+	v.fetches[key] = fetchResult{msg, err}
+	return msg, err
+}
+
+// groupRRsets 将记录按名称和类型分为记录集合，RRSIG 按名称和覆盖的类型单独分组
+func groupRRsets(rrs []RR) ([][]RR, map[string][]RR) {
+	var sets [][]RR
+	index := map[string]int{}
+	sigs := map[string][]RR{}
+	for _, rr := range rrs {
+		if rr.Type == TypeRRSIG {
+			if len(rr.Data) >= 2 {
+				key := rrsetKey(rr.Name, binary.BigEndian.Uint16(rr.Data))
+				sigs[key] = append(sigs[key], rr)
+			}
+			continue
+		}
+		key := rrsetKey(rr.Name, rr.Type)
+		if i, ok := index[key]; ok {
+			sets[i] = append(sets[i], rr)
+			continue
+		}
+		index[key] = len(sets)
+		sets = append(sets, []RR{rr})
+	}
+	return sets, sigs
+}
+
+func rrsetKey(name string, qtype uint16) string {
+	return strings.ToLower(Fqdn(name)) + "/" + TypeString(qtype)
+}
+
+// parentName 返回去掉第一个标签后的域名
+func parentName(name string) string {
+	_, parent, _ := strings.Cut(Fqdn(name), ".")
+	if parent == "" {
+		return "."
+	}
+	return parent
+}
+
+// Map 返回校验的结构化结果
+func (r *Validation) Map() map[string]interface{} {
+	rrsets := make([]map[string]interface{}, 0, len(r.RRsets))
+	var link, reason string
+	for _, s := range r.RRsets {
+		m := map[string]interface{}{
+			"section": s.Section,
+			"name":    s.Name,
+			"type":    TypeString(s.Type),
+			"status":  s.Status,
+		}
+		if s.Status == StatusSecure {
+			m["signer"] = s.Signer
+			m["key_tag"] = s.KeyTag
+			m["algorithm"] = s.Algorithm
+			m["wildcard"] = s.Wildcard
+		} else {
+			m["link"] = s.Link
+			m["reason"] = s.Reason
+			if s.Status == r.Status && reason == "" {
+				link, reason = s.Link, s.Reason
+			}
+		}
+		rrsets = append(rrsets, m)
+	}
+
+	chain := make([]map[string]interface{}, 0, len(r.Zones))
+	for _, z := range r.Zones {
+		ds := make([]map[string]interface{}, 0, len(z.DS))
+		for _, d := range z.DS {
+			ds = append(ds, map[string]interface{}{"key_tag": d.KeyTag, "algorithm": d.Algorithm, "digest_type": d.DigestType})
+		}
+		keys := make([]map[string]interface{}, 0, len(z.Keys))
+		for _, k := range z.Keys {
+			if len(k.Data) < 4 {
+				continue
+			}
+			flags := binary.BigEndian.Uint16(k.Data)
+			keys = append(keys, map[string]interface{}{"key_tag": KeyTag(k.Data), "algorithm": k.Data[3], "flags": flags, "sep": flags&1 != 0})
+		}
+		link := map[string]interface{}{"zone": z.Zone, "status": z.Status, "ds": ds, "dnskey": keys}
+		if z.Status != StatusSecure {
+			link["link"] = z.Link
+			link["reason"] = z.Reason
+		}
+		chain = append(chain, link)
+	}
+
+	result := map[string]interface{}{
+		"status": r.Status,
+		"ad":     r.AD,
+		"rrsets": rrsets,
+		"chain":  chain,
+	}
+	if r.Status != StatusSecure {
+		result["link"] = link
+		result["reason"] = reason
+	}
+	return result
+}
+
+// Annotate 在应答结果（Response.Map 的返回值）的 answer/authority 记录中加入各自的校验状态
+func (r *Validation) Annotate(result map[string]interface{}) {
+	for section, statuses := range map[string][]string{"answer": r.answer, "authority": r.authority} {
+		records, _ := result[section].([]map[string]interface{})
+		for i, record := range records {
+			if i < len(statuses) && statuses[i] != "" {
+				record["dnssec"] = statuses[i]
+			}
+		}
+	}
+}
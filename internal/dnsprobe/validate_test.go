@@ -0,0 +1,363 @@
+package dnsprobe
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// setTestAnchors 在测试期间替换根区信任锚
+func setTestAnchors(t *testing.T, anchors []DS) {
+	t.Helper()
+	anchorsMu.Lock()
+	saved := trustAnchors
+	trustAnchors = anchors
+	anchorsMu.Unlock()
+	t.Cleanup(func() {
+		anchorsMu.Lock()
+		trustAnchors = saved
+		anchorsMu.Unlock()
+	})
+}
+
+func signed(t *testing.T, k *testKey, rrs ...RR) []RR {
+	t.Helper()
+	return append(rrs, k.signNow(t, rrs))
+}
+
+// withAlgorithm 返回修改了算法编号的RRSIG，用于模拟只剩下不支持算法的签名
+func withAlgorithm(sig RR, alg uint8) RR {
+	sig.Data = append([]byte(nil), sig.Data...)
+	sig.Data[2] = alg
+	return sig
+}
+
+func soa(zone string) RR {
+	return RR{Name: zone, Type: TypeSOA, Class: ClassIN, TTL: 300}
+}
+
+// testChain 构造一组区域，以及校验时上游服务器对各查询的应答：
+//
+//	.                     信任锚
+//	example.              secure（ECDSA P-256）
+//	sub.example.          secure（RSA/SHA-256）
+//	unsigned.example.     非安全委派，NSEC 证明没有DS
+//	optout.example.       非安全委派，NSEC3 opt-out 证明
+//	gost.example.         DS 使用不支持的算法
+//	downgrade.example.    DS 只有不支持算法的签名
+//	lame.example.         没有DS也没有否定证明
+//	rollover.example.     DNSKEY 与DS不匹配
+//	nokeys.example.       取不到DNSKEY
+//	broken.example.       SOA 查询失败
+func testChain(t *testing.T) (map[string]fetchResult, map[string]*testKey) {
+	root := newTestKey(t, ".", 257, algED25519)
+	ex := newTestKey(t, "example.", 257, algECDSAP256SHA256)
+	sub := newTestKey(t, "sub.example.", 257, algRSASHA256)
+	down := newTestKey(t, "downgrade.example.", 257, algED25519)
+	roll := newTestKey(t, "rollover.example.", 257, algED25519)
+	nokeys := newTestKey(t, "nokeys.example.", 257, algED25519)
+
+	anchor, err := parseDS(root.ds(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	setTestAnchors(t, []DS{anchor})
+
+	optoutHash, err := nsec3Hash("optout.example.", 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := nsec3Encoding.DecodeString(optoutHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gostDS := RR{Name: "gost.example.", Type: TypeDS, Class: ClassIN, TTL: 3600, Data: append([]byte{0x12, 0x34, 12, 3}, make([]byte, 32)...)}
+	downDS := signed(t, ex, down.ds(t))
+	downDS[1] = withAlgorithm(downDS[1], 253)
+	// rollover.example. 的DS对应另一把密钥
+	rollDS := newTestKey(t, "rollover.example.", 257, algECDSAP256SHA256).ds(t)
+
+	answer := func(rrs []RR) fetchResult { return fetchResult{msg: &Message{Answer: rrs}} }
+	authority := func(rrs ...RR) fetchResult { return fetchResult{msg: &Message{Authority: rrs}} }
+
+	fetches := map[string]fetchResult{
+		rrsetKey(".", TypeDNSKEY):         answer(signed(t, root, root.rr)),
+		rrsetKey("example.", TypeDS):      answer(signed(t, root, ex.ds(t))),
+		rrsetKey("example.", TypeDNSKEY):  answer(signed(t, ex, ex.rr)),
+		rrsetKey("example.", TypeSOA):     answer([]RR{soa("example.")}),
+		rrsetKey("www.example.", TypeSOA): authority(soa("example.")),
+
+		rrsetKey("sub.example.", TypeDS):     answer(signed(t, ex, sub.ds(t))),
+		rrsetKey("sub.example.", TypeDNSKEY): answer(signed(t, sub, sub.rr)),
+
+		rrsetKey("www.unsigned.example.", TypeSOA): authority(soa("unsigned.example.")),
+		rrsetKey("unsigned.example.", TypeDS): authority(signed(t, ex,
+			nsecRR("unsigned.example.", "z.example.", TypeNS, TypeRRSIG, TypeNSEC))...),
+
+		rrsetKey("www.optout.example.", TypeSOA): authority(soa("optout.example.")),
+		rrsetKey("optout.example.", TypeDS): authority(signed(t, ex,
+			nsec3RR("example.", addHash(h, -1), addHash(h, 1), true, TypeNS))...),
+
+		rrsetKey("www.gost.example.", TypeSOA): authority(soa("gost.example.")),
+		rrsetKey("gost.example.", TypeDS):      answer(signed(t, ex, gostDS)),
+
+		rrsetKey("downgrade.example.", TypeDS):     answer(downDS),
+		rrsetKey("downgrade.example.", TypeDNSKEY): answer(signed(t, down, down.rr)),
+
+		rrsetKey("www.lame.example.", TypeSOA): authority(soa("lame.example.")),
+		rrsetKey("lame.example.", TypeDS):      authority(soa("example.")),
+
+		rrsetKey("rollover.example.", TypeDS):     answer(signed(t, ex, rollDS)),
+		rrsetKey("rollover.example.", TypeDNSKEY): answer(signed(t, roll, roll.rr)),
+
+		rrsetKey("nokeys.example.", TypeDS):     answer(signed(t, ex, nokeys.ds(t))),
+		rrsetKey("nokeys.example.", TypeDNSKEY): answer(nil),
+
+		rrsetKey("www.broken.example.", TypeSOA): {err: errors.New("查询 www.broken.example. SOA 失败: timeout")},
+	}
+	keys := map[string]*testKey{"example.": ex, "sub.example.": sub, "downgrade.example.": down, "rollover.example.": roll, "nokeys.example.": nokeys}
+	return fetches, keys
+}
+
+func newTestValidator(fetches map[string]fetchResult) *validator {
+	// 已取消的上下文：缺少的应答不会发出真实查询
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return &validator{
+		ctx:     ctx,
+		opts:    Options{Class: ClassIN},
+		now:     testNow,
+		fetches: fetches,
+		zones:   map[string]*ZoneResult{},
+	}
+}
+
+func TestValidateChain(t *testing.T) {
+	fetches, keys := testChain(t)
+	ex := keys["example."]
+	www := aRecords("www.example.", [4]byte{192, 0, 2, 1})
+
+	tampered := signed(t, ex, www...)
+	tampered[0].Data = []byte{192, 0, 2, 99}
+	downgraded := signed(t, ex, www...)
+	downgraded[1] = withAlgorithm(downgraded[1], 253)
+	expired := append(aRecords("www.example.", [4]byte{192, 0, 2, 1}), ex.sign(t, www, testNow.Add(-48*time.Hour), testNow.Add(-24*time.Hour)))
+
+	tests := []struct {
+		name   string
+		answer []RR
+		status string
+		link   string
+	}{
+		{"secure", signed(t, ex, www...), StatusSecure, ""},
+		{"secure child zone", signed(t, keys["sub.example."], aRecords("www.sub.example.", [4]byte{192, 0, 2, 2})...), StatusSecure, ""},
+		{"insecure nsec delegation", aRecords("www.unsigned.example.", [4]byte{192, 0, 2, 3}), StatusInsecure, "unsigned.example. DS"},
+		{"insecure nsec3 opt-out", aRecords("www.optout.example.", [4]byte{192, 0, 2, 4}), StatusInsecure, "optout.example. DS"},
+		{"insecure unsupported ds algorithm", aRecords("www.gost.example.", [4]byte{192, 0, 2, 5}), StatusInsecure, "gost.example. DS"},
+		{"bogus tampered", tampered, StatusBogus, "www.example. A"},
+		{"bogus expired", expired, StatusBogus, "www.example. A"},
+		{"bogus stripped signature", www, StatusBogus, "www.example. A"},
+		{"bogus unsupported rrsig in secure zone", downgraded, StatusBogus, "www.example. A"},
+		{"bogus unsupported ds rrsig in secure zone", signed(t, keys["downgrade.example."], aRecords("www.downgrade.example.", [4]byte{192, 0, 2, 6})...), StatusBogus, "downgrade.example. DS"},
+		{"bogus missing denial", aRecords("www.lame.example.", [4]byte{192, 0, 2, 7}), StatusBogus, "lame.example. DS"},
+		{"bogus dnskey mismatch", signed(t, keys["rollover.example."], aRecords("www.rollover.example.", [4]byte{192, 0, 2, 8})...), StatusBogus, "rollover.example. DNSKEY"},
+		{"indeterminate no dnskey", signed(t, keys["nokeys.example."], aRecords("www.nokeys.example.", [4]byte{192, 0, 2, 9})...), StatusIndeterminate, "nokeys.example. DNSKEY"},
+		{"indeterminate soa failure", aRecords("www.broken.example.", [4]byte{192, 0, 2, 10}), StatusIndeterminate, "www.broken.example. A"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := newTestValidator(fetches).validate(&Message{Answer: tt.answer})
+			if result.Status != tt.status {
+				t.Fatalf("status = %s, want %s (%+v)", result.Status, tt.status, result.RRsets)
+			}
+			if len(result.RRsets) != 1 {
+				t.Fatalf("rrsets = %+v", result.RRsets)
+			}
+			if r := result.RRsets[0]; r.Link != tt.link {
+				t.Errorf("link = %q, want %q (reason %s)", r.Link, tt.link, r.Reason)
+			}
+			for i, status := range result.answer {
+				if status != tt.status {
+					t.Errorf("answer[%d] status = %s, want %s", i, status, tt.status)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateSecureDetails(t *testing.T) {
+	fetches, keys := testChain(t)
+	sub := keys["sub.example."]
+	result := newTestValidator(fetches).validate(&Message{Answer: signed(t, sub, aRecords("www.sub.example.", [4]byte{192, 0, 2, 2})...)})
+
+	r := result.RRsets[0]
+	if r.Signer != "sub.example." || r.KeyTag != KeyTag(sub.rr.Data) || r.Algorithm != algRSASHA256 || r.Wildcard {
+		t.Errorf("rrset = %+v", r)
+	}
+	var zones []string
+	for _, z := range result.Zones {
+		if z.Status != StatusSecure {
+			t.Errorf("zone %s status = %s", z.Zone, z.Status)
+		}
+		zones = append(zones, z.Zone)
+	}
+	if len(zones) != 3 || zones[0] != "." || zones[1] != "example." || zones[2] != "sub.example." {
+		t.Errorf("zones = %v", zones)
+	}
+}
+
+func TestValidateMergesStatuses(t *testing.T) {
+	fetches, keys := testChain(t)
+	ex := keys["example."]
+	answer := append(signed(t, ex, aRecords("www.example.", [4]byte{192, 0, 2, 1})...),
+		aRecords("www.unsigned.example.", [4]byte{192, 0, 2, 3})...)
+
+	result := newTestValidator(fetches).validate(&Message{Answer: answer})
+	if result.Status != StatusInsecure {
+		t.Errorf("status = %s, want %s", result.Status, StatusInsecure)
+	}
+	want := []string{StatusSecure, StatusSecure, StatusInsecure}
+	for i, status := range result.answer {
+		if status != want[i] {
+			t.Errorf("answer[%d] = %s, want %s", i, status, want[i])
+		}
+	}
+
+	// bogus 优先于其它状态
+	answer = append(answer, aRecords("www.lame.example.", [4]byte{192, 0, 2, 7})...)
+	if result := newTestValidator(fetches).validate(&Message{Answer: answer}); result.Status != StatusBogus {
+		t.Errorf("status = %s, want %s", result.Status, StatusBogus)
+	}
+
+	// 没有记录时无法判断
+	if result := newTestValidator(fetches).validate(&Message{}); result.Status != StatusIndeterminate {
+		t.Errorf("empty status = %s, want %s", result.Status, StatusIndeterminate)
+	}
+
+	// 只支持 IN 类别
+	v := newTestValidator(fetches)
+	v.opts.Class = 3
+	if result := v.validate(&Message{Answer: answer}); result.Status != StatusIndeterminate {
+		t.Errorf("CH status = %s, want %s", result.Status, StatusIndeterminate)
+	}
+}
+
+// hashOf 返回域名的NSEC3散列（0次迭代，无盐）
+func hashOf(t *testing.T, name string) []byte {
+	t.Helper()
+	hash, err := nsec3Hash(name, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := nsec3Encoding.DecodeString(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+// wildcardAnswer 返回 wildcard 展开为 name 后的记录和签名
+func wildcardAnswer(t *testing.T, k *testKey, wildcard, name string) []RR {
+	t.Helper()
+	sig := k.signNow(t, aRecords(wildcard, [4]byte{192, 0, 2, 20}))
+	sig.Name = name
+	return append(aRecords(name, [4]byte{192, 0, 2, 20}), sig)
+}
+
+func TestValidateDenial(t *testing.T) {
+	fetches, keys := testChain(t)
+	ex := keys["example."]
+	sign := func(rrs ...RR) []RR {
+		var out []RR
+		for _, rr := range rrs {
+			out = append(out, signed(t, ex, rr)...)
+		}
+		return out
+	}
+	covering := func(name string) RR {
+		h := hashOf(t, name)
+		return nsec3RR("example.", addHash(h, -1), addHash(h, 1), false, TypeA, TypeRRSIG)
+	}
+	matching := func(name string, types ...uint16) RR {
+		h := hashOf(t, name)
+		return nsec3RR("example.", h, addHash(h, 1), false, types...)
+	}
+	apex := []uint16{TypeNS, TypeSOA, TypeRRSIG, TypeDNSKEY, TypeNSEC3PARAM}
+	negative := func(rcode int, name string, qtype uint16, authority ...RR) *Message {
+		return &Message{
+			Header:    Header{Rcode: rcode},
+			Questions: []Question{{Name: name, Type: qtype, Class: ClassIN}},
+			Authority: append(signed(t, ex, soa("example.")), sign(authority...)...),
+		}
+	}
+
+	tests := []struct {
+		name   string
+		msg    *Message
+		status string
+	}{
+		// NSEC 否定应答
+		{"nsec nxdomain", negative(3, "nx.example.", TypeA,
+			nsecRR("example.", "www.example.", TypeNS, TypeSOA, TypeRRSIG, TypeNSEC, TypeDNSKEY)), StatusSecure},
+		{"nsec nxdomain unrelated record", negative(3, "nx.example.", TypeA,
+			nsecRR("www.example.", "z.example.", TypeA, TypeRRSIG, TypeNSEC)), StatusBogus},
+		{"nxdomain without denial", negative(3, "nx.example.", TypeA), StatusBogus},
+		{"nsec nxdomain wildcard not denied", negative(3, "nx.example.", TypeA,
+			nsecRR("m.example.", "p.example.", TypeA, TypeRRSIG, TypeNSEC)), StatusBogus},
+		{"nsec nxdomain below delegation", negative(3, "x.sub.example.", TypeA,
+			nsecRR("sub.example.", "z.example.", TypeNS, TypeDS, TypeRRSIG, TypeNSEC)), StatusBogus},
+		{"nsec nodata", negative(0, "www.example.", TypeAAAA,
+			nsecRR("www.example.", "z.example.", TypeA, TypeRRSIG, TypeNSEC)), StatusSecure},
+		{"nsec nodata type exists", negative(0, "www.example.", TypeA,
+			nsecRR("www.example.", "z.example.", TypeA, TypeRRSIG, TypeNSEC)), StatusBogus},
+		{"nsec nodata cname", negative(0, "www.example.", TypeAAAA,
+			nsecRR("www.example.", "z.example.", TypeCNAME, TypeRRSIG, TypeNSEC)), StatusBogus},
+		{"nsec nodata from delegation", negative(0, "sub.example.", TypeA,
+			nsecRR("sub.example.", "z.example.", TypeNS, TypeRRSIG, TypeNSEC)), StatusBogus},
+		{"nsec wildcard nodata", negative(0, "nx.example.", TypeAAAA,
+			nsecRR("*.example.", "www.example.", TypeA, TypeRRSIG, TypeNSEC)), StatusSecure},
+
+		// NSEC3 否定应答
+		{"nsec3 nxdomain", negative(3, "nx.example.", TypeA,
+			matching("example.", apex...), covering("nx.example."), covering("*.example.")), StatusSecure},
+		{"nsec3 nxdomain wildcard not denied", negative(3, "nx.example.", TypeA,
+			matching("example.", apex...), covering("nx.example.")), StatusBogus},
+		{"nsec3 nxdomain next closer not covered", negative(3, "nx.example.", TypeA,
+			matching("example.", apex...), covering("*.example.")), StatusBogus},
+		{"nsec3 nxdomain name exists", negative(3, "nx.example.", TypeA,
+			matching("nx.example.", TypeA, TypeRRSIG), matching("example.", apex...), covering("*.example.")), StatusBogus},
+		{"nsec3 nodata", negative(0, "www.example.", TypeAAAA, matching("www.example.", TypeA, TypeRRSIG)), StatusSecure},
+		{"nsec3 nodata type exists", negative(0, "www.example.", TypeA, matching("www.example.", TypeA, TypeRRSIG)), StatusBogus},
+		{"nsec3 wildcard nodata", negative(0, "nx.example.", TypeAAAA,
+			matching("example.", apex...), covering("nx.example."), matching("*.example.", TypeA, TypeRRSIG)), StatusSecure},
+
+		// 权威部分为空时按区域判断
+		{"nodata without authority in signed zone", &Message{Questions: []Question{{Name: "www.example.", Type: TypeAAAA, Class: ClassIN}}}, StatusBogus},
+		{"nodata without authority in unsigned zone", &Message{Questions: []Question{{Name: "www.unsigned.example.", Type: TypeAAAA, Class: ClassIN}}}, StatusInsecure},
+
+		// 通配符展开的应答
+		{"wildcard with nsec", &Message{
+			Answer:    wildcardAnswer(t, ex, "*.wild.example.", "a.wild.example."),
+			Authority: sign(nsecRR("*.wild.example.", "b.wild.example.", TypeA, TypeRRSIG, TypeNSEC)),
+		}, StatusSecure},
+		{"wildcard with nsec3", &Message{
+			Answer:    wildcardAnswer(t, ex, "*.wild.example.", "a.wild.example."),
+			Authority: sign(covering("a.wild.example.")),
+		}, StatusSecure},
+		{"wildcard without proof", &Message{Answer: wildcardAnswer(t, ex, "*.wild.example.", "a.wild.example.")}, StatusBogus},
+		{"wildcard with unrelated nsec", &Message{
+			Answer:    wildcardAnswer(t, ex, "*.wild.example.", "a.wild.example."),
+			Authority: sign(nsecRR("b.wild.example.", "c.wild.example.", TypeA, TypeRRSIG, TypeNSEC)),
+		}, StatusBogus},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := newTestValidator(fetches).validate(tt.msg)
+			if result.Status != tt.status {
+				t.Errorf("status = %s, want %s (%+v)", result.Status, tt.status, result.RRsets)
+			}
+		})
+	}
+}
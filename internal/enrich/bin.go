@@ -0,0 +1,217 @@
+package enrich
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// IP2Location 和 IP2Proxy 的 BIN 格式数据库，格式参考官方的 ip2location-go 和 ip2proxy-go。
+// 文件头（小端序）：数据库类型、列数、日期、IPv4/IPv6 的行数和起始地址等，地址从1开始计数；
+// 每行为起始IP加 列数-1 个字段，字段为指向长度前缀字符串的偏移
+const (
+	binProductIP2Location = 1
+	binProductIP2Proxy    = 2
+)
+
+// binColumns 各类型数据库中字段所在的列（从1开始，0表示不含该字段），下标为数据库类型
+type binColumns struct {
+	country, region, city, asn, as []uint8
+}
+
+// ip2locationColumns IP2Location DB1-DB26，ASN 和 AS 名称仅 DB26 提供
+var ip2locationColumns = binColumns{
+	country: []uint8{0, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2},
+	region:  []uint8{0, 0, 0, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3},
+	city:    []uint8{0, 0, 0, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4},
+	asn:     []uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 24},
+	as:      []uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 25},
+}
+
+// ip2proxyColumns IP2Proxy PX1-PX12，ASN 和 AS 名称从 PX7 开始提供
+var ip2proxyColumns = binColumns{
+	country: []uint8{0, 2, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3},
+	region:  []uint8{0, 0, 0, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4},
+	city:    []uint8{0, 0, 0, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5},
+	asn:     []uint8{0, 0, 0, 0, 0, 0, 0, 9, 9, 9, 9, 9, 9},
+	as:      []uint8{0, 0, 0, 0, 0, 0, 0, 10, 10, 10, 10, 10, 10},
+}
+
+// column 返回数据库类型 dbType 中字段的列，未知类型或不含该字段时为0
+func column(positions []uint8, dbType int) int {
+	if dbType <= 0 || dbType >= len(positions) {
+		return 0
+	}
+	return int(positions[dbType])
+}
+
+// binReader IP2Location/IP2Proxy BIN 文件的只读解析器
+type binReader struct {
+	path     string
+	buf      []byte
+	dbType   int
+	colCount int
+	columns  binColumns
+	ipv4     binTable
+	ipv6     binTable
+}
+
+// binTable 一个地址族的数据行
+type binTable struct {
+	count   int
+	base    int // 第一行的偏移（从0开始）
+	rowSize int
+	ipSize  int
+}
+
+func openBIN(path string) (*binReader, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(buf) < 30 {
+		return nil, fmt.Errorf("%s 不是有效的BIN文件", path)
+	}
+
+	r := &binReader{path: path, buf: buf, dbType: int(buf[0]), colCount: int(buf[1])}
+	if r.colCount < 2 {
+		return nil, fmt.Errorf("%s 列数无效: %d", path, r.colCount)
+	}
+	// 较新的文件在第30字节记录产品类型，旧文件为0时按文件名判断
+	product := int(buf[29])
+	if product == 0 {
+		product = binProductIP2Location
+		if name := strings.ToUpper(filepath.Base(path)); strings.Contains(name, "PROXY") || strings.HasPrefix(name, "PX") {
+			product = binProductIP2Proxy
+		}
+	}
+	switch product {
+	case binProductIP2Location:
+		r.columns = ip2locationColumns
+	case binProductIP2Proxy:
+		r.columns = ip2proxyColumns
+	default:
+		return nil, fmt.Errorf("%s 不支持的产品类型: %d", path, product)
+	}
+	if column(r.columns.country, r.dbType) == 0 {
+		return nil, fmt.Errorf("%s 不支持的数据库类型: %d", path, r.dbType)
+	}
+
+	r.ipv4 = binTable{
+		count:   int(binary.LittleEndian.Uint32(buf[5:])),
+		base:    int(binary.LittleEndian.Uint32(buf[9:])) - 1,
+		rowSize: r.colCount * 4,
+		ipSize:  net.IPv4len,
+	}
+	r.ipv6 = binTable{
+		count:   int(binary.LittleEndian.Uint32(buf[13:])),
+		base:    int(binary.LittleEndian.Uint32(buf[17:])) - 1,
+		rowSize: net.IPv6len + (r.colCount-1)*4,
+		ipSize:  net.IPv6len,
+	}
+	for _, t := range []binTable{r.ipv4, r.ipv6} {
+		if t.count > 0 && (t.base < 0 || t.base+t.count*t.rowSize > len(buf)) {
+			return nil, fmt.Errorf("%s 文件已损坏", path)
+		}
+	}
+	return r, nil
+}
+
+// rowIP 返回第 i 行的起始IP（大端序，便于比较）
+func (r *binReader) rowIP(t binTable, i int) []byte {
+	off := t.base + i*t.rowSize
+	ip := make([]byte, t.ipSize)
+	for j := range ip {
+		// 文件中为小端序
+		ip[j] = r.buf[off+t.ipSize-1-j]
+	}
+	return ip
+}
+
+// lookup 查询IP对应的记录，字段名与扁平格式的mmdb相同，没有记录时返回 nil
+func (r *binReader) lookup(ip net.IP) (map[string]interface{}, error) {
+	t, addr := r.ipv6, ip.To16()
+	if ip4 := ip.To4(); ip4 != nil && r.ipv4.count > 0 {
+		t, addr = r.ipv4, ip4
+	}
+	if t.count == 0 || addr == nil {
+		return nil, nil
+	}
+
+	// 找到最后一个起始IP不大于 addr 的行
+	i := sort.Search(t.count, func(i int) bool {
+		return bytes.Compare(r.rowIP(t, i), addr) > 0
+	}) - 1
+	if i < 0 {
+		return nil, nil
+	}
+	row := r.buf[t.base+i*t.rowSize : t.base+(i+1)*t.rowSize]
+	// field 返回第 col 列（从1开始，第1列为起始IP）的字段偏移
+	field := func(col int) (int, bool) {
+		if col < 2 || col > r.colCount {
+			return 0, false
+		}
+		off := t.ipSize + (col-2)*4
+		return int(binary.LittleEndian.Uint32(row[off:])), true
+	}
+
+	record := make(map[string]interface{})
+	if ptr, ok := field(column(r.columns.country, r.dbType)); ok {
+		code, err := r.readString(ptr)
+		if err != nil {
+			return nil, err
+		}
+		// 国家名称紧跟在3字节的国家代码（长度+2字母）之后
+		name, err := r.readString(ptr + 3)
+		if err != nil {
+			return nil, err
+		}
+		setString(record, "country", code)
+		setString(record, "country_name", name)
+	}
+	for key, positions := range map[string][]uint8{
+		"region":  r.columns.region,
+		"city":    r.columns.city,
+		"asn":     r.columns.asn,
+		"as_name": r.columns.as,
+	} {
+		ptr, ok := field(column(positions, r.dbType))
+		if !ok {
+			continue
+		}
+		s, err := r.readString(ptr)
+		if err != nil {
+			return nil, err
+		}
+		setString(record, key, s)
+	}
+	if len(record) == 0 {
+		return nil, nil
+	}
+	return record, nil
+}
+
+// readString 读取偏移处的长度前缀字符串
+func (r *binReader) readString(off int) (string, error) {
+	if off < 0 || off >= len(r.buf) {
+		return "", errors.New("字符串偏移越界")
+	}
+	end := off + 1 + int(r.buf[off])
+	if end > len(r.buf) {
+		return "", errors.New("字符串长度越界")
+	}
+	return string(r.buf[off+1 : end]), nil
+}
+
+// setString 写入非空字段，BIN 中 "-" 表示未知
+func setString(record map[string]interface{}, key, value string) {
+	if value != "" && value != "-" {
+		record[key] = value
+	}
+}
@@ -0,0 +1,198 @@
+package enrich
+
+import (
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// binRow 测试数据库中的一行：起始IP和除起始IP外各列的字符串（country 列为 代码|名称）
+type binRow struct {
+	from   string
+	fields []string
+}
+
+// writeTestBIN 按 BIN 格式生成数据库文件，v4 和 v6 为按起始IP排序的行
+func writeTestBIN(t *testing.T, name string, product, dbType, countryCol int, v4, v6 []binRow) string {
+	t.Helper()
+	colCount := 1
+	if len(v4) > 0 {
+		colCount += len(v4[0].fields)
+	} else {
+		colCount += len(v6[0].fields)
+	}
+	const headerSize = 64
+	v4Size := (len(v4) + 1) * colCount * 4
+	v6Size := (len(v6) + 1) * (16 + (colCount-1)*4)
+	buf := make([]byte, headerSize+v4Size+v6Size)
+	buf[0] = byte(dbType)
+	buf[1] = byte(colCount)
+	binary.LittleEndian.PutUint32(buf[5:], uint32(len(v4)))
+	binary.LittleEndian.PutUint32(buf[9:], headerSize+1)
+	binary.LittleEndian.PutUint32(buf[13:], uint32(len(v6)))
+	binary.LittleEndian.PutUint32(buf[17:], uint32(headerSize+v4Size+1))
+	buf[29] = byte(product)
+
+	// putString 在文件末尾写入长度前缀字符串，返回偏移
+	putString := func(s string) uint32 {
+		off := uint32(len(buf))
+		buf = append(buf, byte(len(s)))
+		buf = append(buf, s...)
+		return off
+	}
+	writeRows := func(base int, rows []binRow, ipSize int) {
+		rowSize := ipSize + (colCount-1)*4
+		for i, row := range rows {
+			off := base + i*rowSize
+			ip := net.ParseIP(row.from)
+			if ipSize == 4 {
+				binary.LittleEndian.PutUint32(buf[off:], binary.BigEndian.Uint32(ip.To4()))
+			} else {
+				for j, b := range ip.To16() {
+					buf[off+15-j] = b
+				}
+			}
+			for j, f := range row.fields {
+				var ptr uint32
+				if j+2 == countryCol {
+					// 国家名称紧跟在国家代码之后，代码占3字节（未知时为 "-" 并补齐）
+					code, name, _ := strings.Cut(f, "|")
+					ptr = putString(code)
+					buf = append(buf, make([]byte, 2-len(code))...)
+					putString(name)
+				} else {
+					ptr = putString(f)
+				}
+				binary.LittleEndian.PutUint32(buf[off+ipSize+j*4:], ptr)
+			}
+		}
+	}
+	writeRows(headerSize, v4, 4)
+	writeRows(headerSize+v4Size, v6, 16)
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, buf, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBINLookupIP2Location(t *testing.T) {
+	// DB3：国家、地区、城市
+	path := writeTestBIN(t, "IP2LOCATION-LITE-DB3.BIN", binProductIP2Location, 3, 2,
+		[]binRow{
+			{"0.0.0.0", []string{"-|-", "-", "-"}},
+			{"1.0.0.0", []string{"AU|Australia", "Queensland", "Brisbane"}},
+			{"1.0.1.0", []string{"CN|China", "Fujian", "Fuzhou"}},
+			{"1.0.4.0", []string{"-|-", "-", "-"}},
+		},
+		[]binRow{
+			{"::", []string{"-|-", "-", "-"}},
+			{"2400:3200::", []string{"CN|China", "Zhejiang", "Hangzhou"}},
+			{"2400:3201::", []string{"-|-", "-", "-"}},
+		})
+	r, err := openBIN(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		ip   string
+		want map[string]interface{}
+	}{
+		{"1.0.0.1", map[string]interface{}{"country": "AU", "country_name": "Australia", "region": "Queensland", "city": "Brisbane"}},
+		{"1.0.3.255", map[string]interface{}{"country": "CN", "country_name": "China", "region": "Fujian", "city": "Fuzhou"}},
+		{"1.0.4.0", nil},
+		{"0.1.2.3", nil},
+		{"255.255.255.255", nil},
+		{"2400:3200::1", map[string]interface{}{"country": "CN", "country_name": "China", "region": "Zhejiang", "city": "Hangzhou"}},
+		{"2400:3201::1", nil},
+	}
+	for _, tt := range tests {
+		got, err := r.lookup(net.ParseIP(tt.ip))
+		if err != nil {
+			t.Errorf("lookup(%s) error: %v", tt.ip, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("lookup(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestBINLookupIP2Proxy(t *testing.T) {
+	// PX8：代理类型、国家、地区、城市、ISP、域名、用途、ASN、AS、最后发现时间；产品类型为0时按文件名识别
+	path := writeTestBIN(t, "IP2PROXY-PX8.BIN", 0, 8, 3,
+		[]binRow{
+			{"0.0.0.0", []string{"-", "-|-", "-", "-", "-", "-", "-", "-", "-", "-"}},
+			{"8.8.8.0", []string{"DCH", "US|United States of America", "California", "Mountain View", "Google LLC", "google.com", "DCH", "15169", "Google LLC", "30"}},
+			{"8.8.9.0", []string{"-", "-|-", "-", "-", "-", "-", "-", "-", "-", "-"}},
+		}, nil)
+	r, err := openBIN(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := r.lookup(net.ParseIP("8.8.8.8"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"country":      "US",
+		"country_name": "United States of America",
+		"region":       "California",
+		"city":         "Mountain View",
+		"asn":          "15169",
+		"as_name":      "Google LLC",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("lookup = %v, want %v", got, want)
+	}
+
+	var info Info
+	mergeRecord(&info, got)
+	if info.ASN != 15169 || info.ASName != "Google LLC" || info.CountryCode != "US" || info.City != "Mountain View" {
+		t.Errorf("mergeRecord = %+v", info)
+	}
+}
+
+func TestOpenBINInvalid(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"short.bin", []byte{1, 2, 3}},
+		{"columns.bin", append([]byte{1, 1}, make([]byte, 40)...)},
+		{"type.bin", append([]byte{99, 4}, make([]byte, 40)...)},
+		{"rows.bin", func() []byte {
+			b := make([]byte, 64)
+			b[0], b[1], b[29] = 1, 2, binProductIP2Location
+			binary.LittleEndian.PutUint32(b[5:], 1000)
+			binary.LittleEndian.PutUint32(b[9:], 65)
+			return b
+		}()},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(dir, tt.name)
+		os.WriteFile(path, tt.data, 0o644)
+		if _, err := openBIN(path); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
+
+func TestOpenDatabaseByExtension(t *testing.T) {
+	path := writeTestBIN(t, "db1.BIN", binProductIP2Location, 1, 2,
+		[]binRow{{"0.0.0.0", []string{"CN|China"}}}, nil)
+	db, err := openDatabase(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := db.(*binReader); !ok {
+		t.Fatalf("openDatabase returned %T", db)
+	}
+}
@@ -0,0 +1,236 @@
+// Package enrich 为测试结果中的IP补充ASN、地理位置和反向解析（PTR）信息，
+// ASN和地理位置来自本地的 MaxMind DB（.mmdb）或 IP2Location/IP2Proxy BIN（.bin）格式数据库文件
+package enrich
+
+import (
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"linkmaster-node/internal/config"
+)
+
+const (
+	maxIPs         = 256             // 单个结果最多补充的IP数量
+	ptrConcurrency = 16              // 同时进行的反向解析数量
+	ptrTimeout     = 3 * time.Second // 反向解析的总等待时间
+)
+
+var (
+	mu      sync.RWMutex
+	readers []database
+	ptr     = newPTRCache(DefaultPTRCacheSize)
+)
+
+// database 一个本地数据库文件，lookup 没有记录时返回 nil
+type database interface {
+	lookup(ip net.IP) (map[string]interface{}, error)
+}
+
+// openDatabase 按扩展名打开数据库：.bin 为 IP2Location/IP2Proxy，其余按 MaxMind DB 解析
+func openDatabase(path string) (database, error) {
+	if strings.EqualFold(filepath.Ext(path), ".bin") {
+		return openBIN(path)
+	}
+	return openMMDB(path)
+}
+
+// Init 加载配置中的数据库文件，部分文件加载失败时仍使用其余文件
+func Init(cfg *config.Config) error {
+	var loaded []database
+	var errs []error
+	for _, path := range cfg.Enrich.Databases {
+		r, err := openDatabase(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		loaded = append(loaded, r)
+	}
+
+	mu.Lock()
+	readers = loaded
+	ptr = newPTRCache(cfg.Enrich.PTRCacheSize)
+	mu.Unlock()
+	return errors.Join(errs...)
+}
+
+// Info 一个IP的补充信息
+type Info struct {
+	ASN         uint64
+	ASName      string
+	Country     string
+	CountryCode string
+	City        string
+	PTR         string
+}
+
+// Map 返回结果中使用的字段，省略未知的字段
+func (i Info) Map() map[string]interface{} {
+	m := make(map[string]interface{})
+	if i.ASN != 0 {
+		m["asn"] = i.ASN
+	}
+	for k, v := range map[string]string{
+		"as_name":      i.ASName,
+		"country":      i.Country,
+		"country_code": i.CountryCode,
+		"city":         i.City,
+		"ptr":          i.PTR,
+	} {
+		if v != "" {
+			m[k] = v
+		}
+	}
+	return m
+}
+
+// Lookup 从所有数据库中查询IP的ASN和地理位置，多个数据库的字段合并
+func Lookup(ip net.IP) Info {
+	mu.RLock()
+	defer mu.RUnlock()
+	var info Info
+	for _, r := range readers {
+		record, err := r.lookup(ip)
+		if err != nil || record == nil {
+			continue
+		}
+		mergeRecord(&info, record)
+	}
+	return info
+}
+
+// mergeRecord 兼容 GeoLite2/GeoIP2 和 IPinfo/DB-IP/IP2Location 等扁平格式的字段
+func mergeRecord(info *Info, record map[string]interface{}) {
+	if info.ASN == 0 {
+		if asn := toUint(record["autonomous_system_number"]); asn != 0 {
+			info.ASN = asn
+		} else if asn, ok := record["asn"].(string); ok {
+			info.ASN, _ = strconv.ParseUint(strings.TrimPrefix(strings.ToUpper(asn), "AS"), 10, 32)
+		}
+	}
+	if info.ASName == "" {
+		info.ASName = firstString(record["autonomous_system_organization"], record["as_name"], record["as_organization"])
+	}
+
+	if country, ok := record["country"].(map[string]interface{}); ok {
+		if info.CountryCode == "" {
+			info.CountryCode, _ = country["iso_code"].(string)
+		}
+		if info.Country == "" {
+			info.Country = localizedName(country)
+		}
+	} else if code, ok := record["country"].(string); ok && info.CountryCode == "" {
+		info.CountryCode = code
+	}
+	if info.Country == "" {
+		info.Country = firstString(record["country_name"])
+	}
+
+	if city, ok := record["city"].(map[string]interface{}); ok {
+		if info.City == "" {
+			info.City = localizedName(city)
+		}
+	} else if info.City == "" {
+		info.City = firstString(record["city"])
+	}
+}
+
+// localizedName 优先使用中文名称
+func localizedName(m map[string]interface{}) string {
+	names, _ := m["names"].(map[string]interface{})
+	return firstString(names["zh-CN"], names["en"])
+}
+
+func firstString(values ...interface{}) string {
+	for _, v := range values {
+		if s, ok := v.(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// Annotate 收集结果中出现的所有IP，返回 IP -> 补充信息 的映射（包含PTR）
+func Annotate(ctx context.Context, result interface{}) map[string]interface{} {
+	ips := make(map[string]bool)
+	collectIPs(result, ips)
+
+	list := make([]string, 0, len(ips))
+	for ip := range ips {
+		list = append(list, ip)
+	}
+	sort.Strings(list)
+	if len(list) > maxIPs {
+		list = list[:maxIPs]
+	}
+
+	mu.RLock()
+	cache := ptr
+	mu.RUnlock()
+
+	infos := make([]Info, len(list))
+	ctx, cancel := context.WithTimeout(ctx, ptrTimeout)
+	defer cancel()
+	sem := make(chan struct{}, ptrConcurrency)
+	var wg sync.WaitGroup
+	for i, ip := range list {
+		infos[i] = Lookup(net.ParseIP(ip))
+		wg.Add(1)
+		go func(i int, ip string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			infos[i].PTR = cache.lookup(ctx, ip)
+		}(i, ip)
+	}
+	wg.Wait()
+
+	annotated := make(map[string]interface{}, len(list))
+	for i, ip := range list {
+		annotated[ip] = infos[i].Map()
+	}
+	return annotated
+}
+
+// collectIPs 递归查找结果中所有是IP地址的字符串，gin.H 等自定义的map/slice类型通过反射遍历
+func collectIPs(value interface{}, ips map[string]bool) {
+	switch v := value.(type) {
+	case nil:
+		return
+	case string:
+		if ip := net.ParseIP(v); ip != nil && !ip.IsUnspecified() {
+			ips[ip.String()] = true
+		}
+		return
+	case []string:
+		for _, s := range v {
+			collectIPs(s, ips)
+		}
+		return
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Map:
+		iter := rv.MapRange()
+		for iter.Next() {
+			collectIPs(iter.Value().Interface(), ips)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			collectIPs(rv.Index(i).Interface(), ips)
+		}
+	case reflect.Ptr, reflect.Interface:
+		if !rv.IsNil() {
+			collectIPs(rv.Elem().Interface(), ips)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package enrich
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+)
+
+// MaxMind DB 格式说明：https://maxmind.github.io/MaxMind-DB/
+var metadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+// maxDecodeDepth 解码时指针和嵌套容器的最大深度，避免损坏的文件导致无限递归
+const maxDecodeDepth = 32
+
+// mmdbReader MaxMind DB（.mmdb）文件的只读解析器，GeoLite2、DB-IP、IPinfo 等提供该格式
+type mmdbReader struct {
+	path         string
+	buf          []byte
+	nodeCount    uint
+	recordSize   uint
+	ipVersion    uint
+	databaseType string
+	treeSize     uint
+	dataStart    uint
+	ipv4Start    uint // IPv6树中 ::/96 对应的节点
+}
+
+func openMMDB(path string) (*mmdbReader, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	idx := bytes.LastIndex(buf, metadataMarker)
+	if idx < 0 {
+		return nil, fmt.Errorf("%s 不是有效的MaxMind DB文件", path)
+	}
+	metaStart := uint(idx + len(metadataMarker))
+	meta, _, err := decodeValue(buf[metaStart:], 0, nil, 0)
+	if err != nil {
+		return nil, fmt.Errorf("解析 %s 元数据失败: %v", path, err)
+	}
+	metaMap, ok := meta.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s 元数据格式错误", path)
+	}
+
+	r := &mmdbReader{path: path, buf: buf}
+	r.nodeCount = uint(toUint(metaMap["node_count"]))
+	r.recordSize = uint(toUint(metaMap["record_size"]))
+	r.ipVersion = uint(toUint(metaMap["ip_version"]))
+	r.databaseType, _ = metaMap["database_type"].(string)
+	if r.recordSize != 24 && r.recordSize != 28 && r.recordSize != 32 {
+		return nil, fmt.Errorf("%s 不支持的记录长度: %d", path, r.recordSize)
+	}
+	r.treeSize = r.recordSize * 2 / 8 * r.nodeCount
+	r.dataStart = r.treeSize + 16
+	if r.dataStart > metaStart {
+		return nil, fmt.Errorf("%s 文件已损坏", path)
+	}
+
+	if r.ipVersion == 6 {
+		node := uint(0)
+		for i := 0; i < 96 && node < r.nodeCount; i++ {
+			node = r.readNode(node, 0)
+		}
+		r.ipv4Start = node
+	}
+	return r, nil
+}
+
+// readNode 读取节点 node 的左（bit=0）或右（bit=1）记录
+func (r *mmdbReader) readNode(node uint, bit uint) uint {
+	switch r.recordSize {
+	case 24:
+		off := node*6 + bit*3
+		b := r.buf[off : off+3]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		b := r.buf[node*7 : node*7+7]
+		if bit == 0 {
+			return uint(b[3]&0xF0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0F)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		off := node*8 + bit*4
+		return uint(binary.BigEndian.Uint32(r.buf[off : off+4]))
+	}
+}
+
+// lookup 查询IP对应的记录，没有记录时返回 nil
+func (r *mmdbReader) lookup(ip net.IP) (map[string]interface{}, error) {
+	var addr []byte
+	node := uint(0)
+	if ip4 := ip.To4(); ip4 != nil {
+		addr = ip4
+		if r.ipVersion == 6 {
+			node = r.ipv4Start
+		}
+	} else {
+		if r.ipVersion == 4 {
+			return nil, nil
+		}
+		addr = ip.To16()
+	}
+
+	for i := 0; i < len(addr)*8 && node < r.nodeCount; i++ {
+		bit := uint(addr[i/8]>>(7-uint(i%8))) & 1
+		node = r.readNode(node, bit)
+	}
+	if node <= r.nodeCount {
+		// 等于 nodeCount 表示没有数据
+		return nil, nil
+	}
+
+	offset := node - r.nodeCount - 16
+	data := r.buf[r.dataStart:]
+	if offset >= uint(len(data)) {
+		return nil, errors.New("数据指针越界")
+	}
+	value, _, err := decodeValue(data, offset, data, 0)
+	if err != nil {
+		return nil, err
+	}
+	record, _ := value.(map[string]interface{})
+	return record, nil
+}
+
+// decodeValue 解码 buf[offset:] 处的一个值，返回值和下一个值的位置；
+// section 为指针解析所基于的数据段，元数据中没有指针时为 nil；depth 为当前的指针和嵌套深度
+func decodeValue(buf []byte, offset uint, section []byte, depth int) (interface{}, uint, error) {
+	if depth > maxDecodeDepth {
+		return nil, 0, errors.New("数据嵌套过深")
+	}
+	if offset >= uint(len(buf)) {
+		return nil, 0, errors.New("数据越界")
+	}
+	ctrl := buf[offset]
+	offset++
+	typ := uint(ctrl >> 5)
+
+	if typ == 1 {
+		// 指针
+		ss := uint(ctrl>>3) & 0x3
+		vvv := uint(ctrl & 0x7)
+		if offset+ss+1 > uint(len(buf)) {
+			return nil, 0, errors.New("数据越界")
+		}
+		var ptr uint
+		switch ss {
+		case 0:
+			ptr = vvv<<8 | uint(buf[offset])
+		case 1:
+			ptr = (vvv<<16 | uint(buf[offset])<<8 | uint(buf[offset+1])) + 2048
+		case 2:
+			ptr = (vvv<<24 | uint(buf[offset])<<16 | uint(buf[offset+1])<<8 | uint(buf[offset+2])) + 526336
+		default:
+			ptr = uint(binary.BigEndian.Uint32(buf[offset : offset+4]))
+		}
+		if section == nil {
+			return nil, 0, errors.New("元数据中不支持指针")
+		}
+		value, _, err := decodeValue(section, ptr, section, depth+1)
+		return value, offset + ss + 1, err
+	}
+
+	if typ == 0 {
+		// 扩展类型
+		if offset >= uint(len(buf)) {
+			return nil, 0, errors.New("数据越界")
+		}
+		typ = 7 + uint(buf[offset])
+		offset++
+	}
+
+	size := uint(ctrl & 0x1f)
+	if size >= 29 {
+		n := size - 28
+		if offset+n > uint(len(buf)) {
+			return nil, 0, errors.New("数据越界")
+		}
+		var v uint
+		for i := uint(0); i < n; i++ {
+			v = v<<8 | uint(buf[offset+i])
+		}
+		offset += n
+		switch size {
+		case 29:
+			size = 29 + v
+		case 30:
+			size = 285 + v
+		default:
+			size = 65821 + v
+		}
+	}
+
+	// 容器的每个元素至少占1字节（map的每项至少2字节），声明的大小超出剩余数据时文件已损坏，
+	// 提前拒绝，避免按声明的大小分配内存
+	remaining := uint(len(buf)) - offset
+	if (typ == 7 && size > remaining/2) || (typ == 11 && size > remaining) {
+		return nil, 0, errors.New("数据越界")
+	}
+
+	switch typ {
+	case 7: // map
+		m := make(map[string]interface{}, size)
+		for i := uint(0); i < size; i++ {
+			key, next, err := decodeValue(buf, offset, section, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			value, next, err := decodeValue(buf, next, section, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			if k, ok := key.(string); ok {
+				m[k] = value
+			}
+			offset = next
+		}
+		return m, offset, nil
+	case 11: // array
+		a := make([]interface{}, 0, size)
+		for i := uint(0); i < size; i++ {
+			value, next, err := decodeValue(buf, offset, section, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, value)
+			offset = next
+		}
+		return a, offset, nil
+	case 14: // boolean，值在size中
+		return size != 0, offset, nil
+	}
+
+	if offset+size > uint(len(buf)) {
+		return nil, 0, errors.New("数据越界")
+	}
+	b := buf[offset : offset+size]
+	offset += size
+	switch typ {
+	case 2: // utf8 string
+		return string(b), offset, nil
+	case 3: // double
+		if size != 8 {
+			return nil, 0, errors.New("double长度错误")
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), offset, nil
+	case 4: // bytes
+		return append([]byte(nil), b...), offset, nil
+	case 5, 6, 9: // uint16、uint32、uint64
+		var v uint64
+		for _, c := range b {
+			v = v<<8 | uint64(c)
+		}
+		return v, offset, nil
+	case 8: // int32
+		var v uint32
+		for _, c := range b {
+			v = v<<8 | uint32(c)
+		}
+		return int64(int32(v)), offset, nil
+	case 10: // uint128，以十六进制字符串表示
+		return fmt.Sprintf("%x", b), offset, nil
+	case 15: // float
+		if size != 4 {
+			return nil, 0, errors.New("float长度错误")
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), offset, nil
+	case 12, 13: // 数据缓存容器、结束标记
+		return nil, offset, nil
+	}
+	return nil, 0, fmt.Errorf("未知的数据类型: %d", typ)
+}
+
+func toUint(v interface{}) uint64 {
+	switch n := v.(type) {
+	case uint64:
+		return n
+	case int64:
+		return uint64(n)
+	case float64:
+		return uint64(n)
+	}
+	return 0
+}
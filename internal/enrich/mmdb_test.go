@@ -0,0 +1,185 @@
+package enrich
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// mmdbString 编码 MaxMind DB 的 utf8 string（长度小于29）
+func mmdbString(s string) []byte {
+	return append([]byte{0x40 | byte(len(s))}, s...)
+}
+
+// mmdbUint32 编码 uint32
+func mmdbUint32(v uint32) []byte {
+	return []byte{0xc4, byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}
+}
+
+// mmdbMap 编码 map，kv 为依次排列的已编码键值
+func mmdbMap(kv ...[]byte) []byte {
+	return append([]byte{0xe0 | byte(len(kv)/2)}, bytes.Join(kv, nil)...)
+}
+
+// writeTestMMDB 生成一个 IPv4 的数据库，1.0.0.0/8 指向 data 开头的记录，其余地址没有数据
+func writeTestMMDB(t *testing.T, data []byte) string {
+	t.Helper()
+	const nodeCount = 8
+	var tree []byte
+	put := func(v uint32) { tree = append(tree, byte(v>>16), byte(v>>8), byte(v)) }
+	prefix := byte(1) // 00000001
+	for i := 0; i < nodeCount; i++ {
+		next := uint32(i + 1)
+		if i == nodeCount-1 {
+			next = nodeCount + 16 // 指向数据段偏移0
+		}
+		if prefix>>(7-i)&1 == 0 {
+			put(next)
+			put(nodeCount)
+		} else {
+			put(nodeCount)
+			put(next)
+		}
+	}
+
+	var file []byte
+	file = append(file, tree...)
+	file = append(file, make([]byte, 16)...)
+	file = append(file, data...)
+	file = append(file, metadataMarker...)
+	file = append(file, mmdbMap(
+		mmdbString("node_count"), mmdbUint32(nodeCount),
+		mmdbString("record_size"), []byte{0xa1, 24},
+		mmdbString("ip_version"), []byte{0xa1, 4},
+		mmdbString("database_type"), mmdbString("Test"),
+	)...)
+
+	path := filepath.Join(t.TempDir(), "test.mmdb")
+	if err := os.WriteFile(path, file, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestMMDBLookup(t *testing.T) {
+	// 记录中的 names 通过指针引用同一数据段中后面的map
+	names := mmdbMap(mmdbString("en"), mmdbString("China"))
+	record := mmdbMap(
+		mmdbString("country"), mmdbMap(mmdbString("iso_code"), mmdbString("CN"), mmdbString("names"), []byte{0x20, 0}),
+		mmdbString("autonomous_system_number"), mmdbUint32(4134),
+	)
+	// 把指针改为指向 record 之后的 names
+	record = bytes.Replace(record, []byte{0x20, 0}, []byte{0x20, byte(len(record))}, 1)
+	path := writeTestMMDB(t, append(record, names...))
+
+	r, err := openMMDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		ip   string
+		want map[string]interface{}
+	}{
+		{"1.2.3.4", map[string]interface{}{
+			"country":                  map[string]interface{}{"iso_code": "CN", "names": map[string]interface{}{"en": "China"}},
+			"autonomous_system_number": uint64(4134),
+		}},
+		{"1.255.255.255", map[string]interface{}{
+			"country":                  map[string]interface{}{"iso_code": "CN", "names": map[string]interface{}{"en": "China"}},
+			"autonomous_system_number": uint64(4134),
+		}},
+		{"2.0.0.1", nil},
+		{"::1", nil},
+	}
+	for _, tt := range tests {
+		got, err := r.lookup(net.ParseIP(tt.ip))
+		if err != nil {
+			t.Errorf("lookup(%s) error: %v", tt.ip, err)
+			continue
+		}
+		if tt.want == nil {
+			if got != nil {
+				t.Errorf("lookup(%s) = %v, want nil", tt.ip, got)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("lookup(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+
+	var info Info
+	got, _ := r.lookup(net.ParseIP("1.1.1.1"))
+	mergeRecord(&info, got)
+	if info.ASN != 4134 || info.CountryCode != "CN" || info.Country != "China" {
+		t.Errorf("mergeRecord = %+v", info)
+	}
+}
+
+func TestDecodeValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		want    interface{}
+		wantErr string
+	}{
+		{"string", mmdbString("abc"), "abc", ""},
+		{"uint16", []byte{0xa2, 0x01, 0x02}, uint64(0x0102), ""},
+		{"uint32", mmdbUint32(7), uint64(7), ""},
+		{"int32 negative", []byte{0x04, 0x01, 0xff, 0xff, 0xff, 0xfe}, int64(-2), ""},
+		{"boolean", []byte{0x01, 0x07}, true, ""},
+		{"double", []byte{0x68, 0x3f, 0xf0, 0, 0, 0, 0, 0, 0}, 1.0, ""},
+		{"array", []byte{0x02, 0x04, 0x41, 'a', 0x41, 'b'}, []interface{}{"a", "b"}, ""},
+		{"map", mmdbMap(mmdbString("k"), mmdbString("v")), map[string]interface{}{"k": "v"}, ""},
+		{"long string", append([]byte{0x5d, 1}, strings.Repeat("x", 30)...), strings.Repeat("x", 30), ""},
+		{"truncated string", []byte{0x45, 'a', 'b'}, nil, "数据越界"},
+		{"truncated map", []byte{0xe2, 0x41, 'k'}, nil, "数据越界"},
+		{"pointer loop", []byte{0x20, 0x00}, nil, "数据嵌套过深"},
+		{"pointer out of range", []byte{0x20, 0x10}, nil, "数据越界"},
+		{"oversized map", []byte{0xff, 0xff, 0xff, 0xff, 0x40}, nil, "数据越界"},
+		{"oversized array", []byte{0x1f, 0x04, 0xff, 0xff, 0xff, 0x40}, nil, "数据越界"},
+		{"map size at limit", []byte{0xe1, 0x40, 0x40}, map[string]interface{}{"": ""}, ""},
+		{"unknown type", []byte{0x00, 0x09}, nil, "未知的数据类型"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := decodeValue(tt.data, 0, tt.data, 0)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeValueNestingLimit(t *testing.T) {
+	// 嵌套 maxDecodeDepth+1 层的数组
+	data := bytes.Repeat([]byte{0x01, 0x04}, maxDecodeDepth+2)
+	if _, _, err := decodeValue(data, 0, data, 0); err == nil {
+		t.Fatal("expected nesting error")
+	}
+	ok := append(bytes.Repeat([]byte{0x01, 0x04}, 3), 0x40)
+	if _, _, err := decodeValue(ok, 0, ok, 0); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenMMDBInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.mmdb")
+	os.WriteFile(path, []byte("not a database"), 0o644)
+	if _, err := openMMDB(path); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -0,0 +1,91 @@
+package enrich
+
+import (
+	"container/list"
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultPTRCacheSize = 4096
+	ptrCacheTTL         = time.Hour
+	ptrNegativeTTL      = 5 * time.Minute // 解析失败的结果缓存时间较短
+)
+
+// ptrCache 有容量上限的反向解析LRU缓存
+type ptrCache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	order   *list.List // 最近使用的在前
+}
+
+type ptrEntry struct {
+	ip      string
+	name    string
+	expires time.Time
+}
+
+func newPTRCache(size int) *ptrCache {
+	if size <= 0 {
+		size = DefaultPTRCacheSize
+	}
+	return &ptrCache{size: size, entries: make(map[string]*list.Element), order: list.New()}
+}
+
+func (c *ptrCache) get(ip string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[ip]
+	if !ok {
+		return "", false
+	}
+	entry := elem.Value.(*ptrEntry)
+	if time.Now().After(entry.expires) {
+		c.order.Remove(elem)
+		delete(c.entries, ip)
+		return "", false
+	}
+	c.order.MoveToFront(elem)
+	return entry.name, true
+}
+
+func (c *ptrCache) put(ip, name string, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[ip]; ok {
+		entry := elem.Value.(*ptrEntry)
+		entry.name = name
+		entry.expires = time.Now().Add(ttl)
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[ip] = c.order.PushFront(&ptrEntry{ip: ip, name: name, expires: time.Now().Add(ttl)})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*ptrEntry).ip)
+	}
+}
+
+// lookup 查询IP的PTR记录（优先使用缓存），没有记录时返回空字符串
+func (c *ptrCache) lookup(ctx context.Context, ip string) string {
+	if name, ok := c.get(ip); ok {
+		return name
+	}
+	names, err := net.DefaultResolver.LookupAddr(ctx, ip)
+	if err != nil || len(names) == 0 {
+		// 超时等临时错误不缓存，下次重试
+		if dnsErr, ok := err.(*net.DNSError); ok && (dnsErr.IsTimeout || dnsErr.IsTemporary) || ctx.Err() != nil {
+			return ""
+		}
+		c.put(ip, "", ptrNegativeTTL)
+		return ""
+	}
+	name := strings.TrimSuffix(names[0], ".")
+	c.put(ip, name, ptrCacheTTL)
+	return name
+}
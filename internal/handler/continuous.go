@@ -14,7 +14,12 @@ import (
 
 	"linkmaster-node/internal/config"
 	"linkmaster-node/internal/continuous"
+	"linkmaster-node/internal/dnsprobe"
 	"linkmaster-node/internal/heartbeat"
+	"linkmaster-node/internal/httpprobe"
+	"linkmaster-node/internal/netutil"
+	"linkmaster-node/internal/pinger"
+	"linkmaster-node/internal/traceroute"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -61,14 +66,41 @@ type ContinuousTask struct {
 	IsRunning   bool
 	pingTask    *continuous.PingTask
 	tcpingTask  *continuous.TCPingTask
+	mtrTask     *continuous.MTRTask
+	dnsTask     *continuous.DNSTask
+	httpTask    *continuous.HTTPTask
+}
+
+// continuousFamily 返回任务的地址族：优先使用顶层的 ip_version，未设置时使用 params 中的 ip_version，两者都设置且不一致时报错
+func continuousFamily(ipVersion interface{}, params map[string]interface{}) (netutil.Family, error) {
+	family, err := netutil.ParseFamily(map[string]interface{}{"ip_version": ipVersion})
+	if err != nil {
+		return "", err
+	}
+	if _, ok := params["ip_version"]; !ok {
+		return family, nil
+	}
+	inner, err := netutil.ParseFamily(params)
+	if err != nil {
+		return "", err
+	}
+	if ipVersion == nil {
+		return inner, nil
+	}
+	if inner != family {
+		return "", fmt.Errorf("ip_version (%s) 与 params.ip_version (%s) 不一致", family, inner)
+	}
+	return family, nil
 }
 
 func HandleContinuousStart(c *gin.Context) {
 	var req struct {
 		Type        string `json:"type" binding:"required"`
 		Target      string `json:"target" binding:"required"`
-		Interval    int    `json:"interval"`     // 秒
-		MaxDuration int    `json:"max_duration"` // 分钟
+		Interval    int         `json:"interval"`     // 秒
+		MaxDuration int         `json:"max_duration"` // 分钟
+		IPVersion   interface{} `json:"ip_version"`   // 4、6、auto
+		Params      map[string]interface{} `json:"params"` // ping/mtr/dns/http的测试参数，与cePing/ceTrace/ceDns/ceGet相同
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -76,6 +108,16 @@ func HandleContinuousStart(c *gin.Context) {
 		return
 	}
 
+	family, err := continuousFamily(req.IPVersion, req.Params)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if family == netutil.FamilyDual {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "持续测试不支持dual，请分别创建IPv4和IPv6任务"})
+		return
+	}
+
 	// 生成任务ID
 	taskID := generateTaskID()
 
@@ -105,15 +147,89 @@ func HandleContinuousStart(c *gin.Context) {
 
 	// 根据类型创建对应的任务
 	if req.Type == "ping" {
-		pingTask := continuous.NewPingTask(taskID, req.Target, interval, maxDuration)
+		params := map[string]interface{}{}
+		for k, v := range req.Params {
+			params[k] = v
+		}
+		params["ip_version"] = string(family)
+		opts, err := pinger.ParseOptions(params)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		pingTask, err := continuous.NewPingTask(taskID, req.Target, interval, maxDuration, opts)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "error_code": netutil.ErrorCode(err)})
+			return
+		}
 		task.pingTask = pingTask
 	} else if req.Type == "tcping" {
-		tcpingTask, err := continuous.NewTCPingTask(taskID, req.Target, interval, maxDuration)
+		tcpingTask, err := continuous.NewTCPingTask(taskID, req.Target, interval, maxDuration, family)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "error_code": netutil.ErrorCode(err)})
 			return
 		}
 		task.tcpingTask = tcpingTask
+	} else if req.Type == "mtr" {
+		params := map[string]interface{}{}
+		for k, v := range req.Params {
+			params[k] = v
+		}
+		params["ip_version"] = string(family)
+		opts, err := traceroute.ParseOptions(params)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		// 与mtr一致，默认每轮每跳只发一个探测
+		if _, ok := params["queries"]; !ok {
+			opts.Queries = 1
+		}
+		mtrTask, err := continuous.NewMTRTask(taskID, req.Target, interval, maxDuration, opts)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "error_code": netutil.ErrorCode(err)})
+			return
+		}
+		task.mtrTask = mtrTask
+	} else if req.Type == "dns" {
+		hostname, err := dnsprobe.ParseName(req.Target)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "error_code": netutil.ErrorCode(err)})
+			return
+		}
+		params := map[string]interface{}{}
+		for k, v := range req.Params {
+			params[k] = v
+		}
+		params["ip_version"] = string(family)
+		opts, err := dnsprobe.ParseOptions(hostname, params)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		dnsTask, err := continuous.NewDNSTask(taskID, req.Target, interval, maxDuration, opts)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		task.dnsTask = dnsTask
+	} else if req.Type == "http" {
+		params := map[string]interface{}{}
+		for k, v := range req.Params {
+			params[k] = v
+		}
+		params["ip_version"] = string(family)
+		opts, err := httpprobe.ParseOptions(req.Target, params, http.MethodGet)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "error_code": netutil.ErrorCode(err)})
+			return
+		}
+		httpTask, err := continuous.NewHTTPTask(taskID, req.Target, interval, maxDuration, opts)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		task.httpTask = httpTask
 	} else {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的持续测试类型"})
 		return
@@ -133,11 +249,34 @@ func HandleContinuousStart(c *gin.Context) {
 		go task.tcpingTask.Start(ctx, func(result map[string]interface{}) {
 			pushResultToBackend(taskID, result)
 		})
+	} else if task.mtrTask != nil {
+		go task.mtrTask.Start(ctx, func(result map[string]interface{}) {
+			pushResultToBackend(taskID, result)
+		})
+	} else if task.dnsTask != nil {
+		go task.dnsTask.Start(ctx, func(result map[string]interface{}) {
+			pushResultToBackend(taskID, result)
+		})
+	} else if task.httpTask != nil {
+		go task.httpTask.Start(ctx, func(result map[string]interface{}) {
+			pushResultToBackend(taskID, result)
+		})
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"task_id": taskID,
-	})
+	}
+	if task.pingTask != nil {
+		// 回显实际生效的ping参数
+		response["settings"] = task.pingTask.Options.Settings()
+	}
+	if task.mtrTask != nil {
+		response["settings"] = task.mtrTask.Options.Settings()
+	}
+	if task.dnsTask != nil {
+		response["settings"] = task.dnsTask.Options.Settings()
+	}
+	c.JSON(http.StatusOK, response)
 }
 
 func HandleContinuousStop(c *gin.Context) {
@@ -160,6 +299,15 @@ func HandleContinuousStop(c *gin.Context) {
 		if task.tcpingTask != nil {
 			task.tcpingTask.Stop()
 		}
+		if task.mtrTask != nil {
+			task.mtrTask.Stop()
+		}
+		if task.dnsTask != nil {
+			task.dnsTask.Stop()
+		}
+		if task.httpTask != nil {
+			task.httpTask.Stop()
+		}
 		close(task.StopCh)
 		delete(continuousTasks, req.TaskID)
 	}
@@ -191,6 +339,15 @@ func HandleContinuousStatus(c *gin.Context) {
 		if task.tcpingTask != nil {
 			task.tcpingTask.UpdateLastRequest()
 		}
+		if task.mtrTask != nil {
+			task.mtrTask.UpdateLastRequest()
+		}
+		if task.dnsTask != nil {
+			task.dnsTask.UpdateLastRequest()
+		}
+		if task.httpTask != nil {
+			task.httpTask.UpdateLastRequest()
+		}
 	}
 	taskMutex.RUnlock()
 
@@ -269,7 +426,6 @@ func addToPushBuffer(taskID string, nodeID uint, nodeIP string, result map[strin
 	bufferMutex.Unlock()
 	
 	buffer.mu.Lock()
-	defer buffer.mu.Unlock()
 	
 	// 添加结果到缓冲
 	buffer.results = append(buffer.results, result)
@@ -291,6 +447,7 @@ func addToPushBuffer(taskID string, nodeID uint, nodeIP string, result map[strin
 			flushPushBuffer(taskID, nodeID, nodeIP)
 		})
 	}
+	buffer.mu.Unlock()
 }
 
 // flushPushBuffer 刷新并推送缓冲中的结果
@@ -467,6 +624,15 @@ func stopTaskByTaskID(taskID string) {
 	if task.tcpingTask != nil {
 		task.tcpingTask.Stop()
 	}
+	if task.mtrTask != nil {
+		task.mtrTask.Stop()
+	}
+	if task.dnsTask != nil {
+		task.dnsTask.Stop()
+	}
+	if task.httpTask != nil {
+		task.httpTask.Stop()
+	}
 	
 	// 关闭停止通道
 	select {
@@ -533,6 +699,15 @@ func StartTaskCleanup() {
 					if task.tcpingTask != nil {
 						task.tcpingTask.Stop()
 					}
+					if task.mtrTask != nil {
+						task.mtrTask.Stop()
+					}
+					if task.dnsTask != nil {
+						task.dnsTask.Stop()
+					}
+					if task.httpTask != nil {
+						task.httpTask.Stop()
+					}
 					delete(continuousTasks, taskID)
 					continue
 				}
@@ -546,6 +721,15 @@ func StartTaskCleanup() {
 					if task.tcpingTask != nil {
 						task.tcpingTask.Stop()
 					}
+					if task.mtrTask != nil {
+						task.mtrTask.Stop()
+					}
+					if task.dnsTask != nil {
+						task.dnsTask.Stop()
+					}
+					if task.httpTask != nil {
+						task.httpTask.Stop()
+					}
 					delete(continuousTasks, taskID)
 				}
 			}
@@ -2,45 +2,36 @@ package handler
 
 import (
 	"encoding/base64"
-	"fmt"
-	"net"
-	"os/exec"
 	"strings"
-	"time"
+
+	"linkmaster-node/internal/config"
+	"linkmaster-node/internal/dnsprobe"
 
 	"github.com/gin-gonic/gin"
 )
 
-func handleDns(c *gin.Context, url string, params map[string]interface{}) {
+var (
+	publicResolvers []string // ceDnsCompare 默认对比的公共DNS
+	rootHints       []string // ceDnsTrace 使用的根服务器，为空时使用内置的根提示
+)
+
+// InitDnsHandler 读取DNS测试相关的配置
+func InitDnsHandler(cfg *config.Config) {
+	publicResolvers = cfg.DNS.PublicResolvers
+	rootHints = cfg.DNS.RootHints
+}
+
+func handleDns(c *gin.Context, url string, params map[string]interface{}) gin.H {
 	// 获取seq参数
 	seq := ""
 	if seqVal, ok := params["seq"].(string); ok {
 		seq = seqVal
 	}
 
-	// 获取dig类型参数
-	digType := ""
-	if dt, ok := params["dt"].(string); ok {
-		digType = dt
-	}
-
-	// 获取DNS服务器参数
-	dnsServer := ""
-	if ds, ok := params["ds"].(string); ok {
-		dnsServer = ds
-	}
-
-	// 解析URL，提取hostname
-	hostname := url
-	if strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://") {
-		parts := strings.Split(url, "//")
-		if len(parts) > 1 {
-			hostParts := strings.Split(parts[1], "/")
-			hostname = hostParts[0]
-			if idx := strings.Index(hostname, ":"); idx != -1 {
-				hostname = hostname[:idx]
-			}
-		}
+	// 解析目标，提取查询的域名
+	hostname, err := dnsprobe.ParseName(url)
+	if err != nil {
+		return targetError(seq, "ceDns", url, err)
 	}
 
 	// 准备结果
@@ -52,111 +43,57 @@ func handleDns(c *gin.Context, url string, params map[string]interface{}) {
 		"cnames": []interface{}{},
 	}
 
-	// 构建dig命令
-	args := []string{hostname}
-	if digType != "" {
-		args = append([]string{"-t", digType}, args...)
-	}
-	if dnsServer != "" {
-		args = append([]string{"@" + dnsServer}, args...)
+	// dt 为记录类型，ds 为DNS服务器；ip_version 决定与DNS服务器通信使用的地址族
+	opts, err := dnsprobe.ParseOptions(hostname, params)
+	if err != nil {
+		result["error"] = err.Error()
+		return result
 	}
+	result["ip_version"] = string(opts.Family)
+	result["settings"] = opts.Settings()
 
-	cmd := exec.Command("dig", args...)
-	output, err := cmd.CombinedOutput()
-	outputStr := string(output)
-
-	// 编码完整输出为base64（header字段）
-	result["header"] = base64.StdEncoding.EncodeToString([]byte(outputStr))
-
+	resp, err := dnsprobe.Query(c.Request.Context(), opts)
 	if err != nil {
 		result["error"] = err.Error()
-		c.JSON(200, result)
-		return
+		return result
+	}
+	for k, v := range resp.Map() {
+		result[k] = v
 	}
 
-	// 解析dig输出
-	lines := strings.Split(outputStr, "\n")
-	inAnswerSection := false
-	ipList := make([]map[string]interface{}, 0)
-	cnameList := make([]map[string]interface{}, 0)
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if strings.Contains(line, "ANSWER SECTION") {
-			inAnswerSection = true
-			continue
-		}
-		if inAnswerSection {
-			if line == "" {
-				break
-			}
-			// 解析dig输出行，格式如：example.com.  300  IN  A  192.168.1.1
-			// 或者：www.baidu.com.  430  IN  CNAME  www.a.shifen.com.
-			parts := strings.Fields(line)
-			if len(parts) >= 5 {
-				// parts[0] = 域名
-				// parts[1] = TTL
-				// parts[2] = "IN" (class)
-				// parts[3] = 记录类型 (A, AAAA, CNAME等)
-				// parts[4] = 记录值
-				recordClass := parts[3] // A, AAAA, CNAME等
-				recordValue := ""
-				if len(parts) >= 5 {
-					recordValue = strings.Join(parts[4:], " ")
-					// 移除值末尾的点（如果有）
-					recordValue = strings.TrimSuffix(recordValue, ".")
-				}
-
-				domain := strings.TrimSuffix(parts[0], ".") // 移除域名末尾的点
-
-				// 分别处理A/AAAA记录和CNAME记录
-				if recordClass == "A" || recordClass == "AAAA" {
-					ipItem := map[string]interface{}{
-						"url":  domain,
-						"type": recordClass,
-						"ip":   recordValue,
-					}
-					ipList = append(ipList, ipItem)
-				} else if recordClass == "CNAME" {
-					cnameItem := map[string]interface{}{
-						"url":  domain,
-						"type": recordClass,
-						"cname": recordValue, // CNAME值
-					}
-					cnameList = append(cnameList, cnameItem)
-				}
-			}
-		}
+	// dnssec 参数：本地校验信任链，结果与上游的AD标志一并返回
+	if opts.DNSSEC {
+		validation := dnsprobe.Validate(c.Request.Context(), opts, resp)
+		result["dnssec"] = validation.Map()
+		validation.Annotate(result)
 	}
 
-	// 如果没有从dig输出解析到IP，尝试使用net.LookupIP
-	if len(ipList) == 0 {
-		start := time.Now()
-		ips, err := net.LookupIP(hostname)
-		lookupTime := time.Since(start)
+	// 与 dig 输出格式相近的文本（header字段）
+	result["header"] = base64.StdEncoding.EncodeToString([]byte(resp.Text()))
 
-		if err == nil {
-			for _, ip := range ips {
-				ipType := "A"
-				if ip.To4() == nil {
-					ipType = "AAAA"
-				}
-				ipItem := map[string]interface{}{
-					"url":  hostname,
-					"type": ipType,
-					"ip":   ip.String(),
-				}
-				ipList = append(ipList, ipItem)
-			}
-			// 更新header，包含lookup时间信息
-			lookupInfo := fmt.Sprintf("Lookup time: %v\n", lookupTime)
-			result["header"] = base64.StdEncoding.EncodeToString([]byte(outputStr + lookupInfo))
-		} else {
-			result["error"] = err.Error()
+	// 兼容原有的 ips/cnames 字段
+	ipList := make([]map[string]interface{}, 0)
+	cnameList := make([]map[string]interface{}, 0)
+	for _, rr := range resp.Msg.Answer {
+		record := dnsprobe.RecordMap(rr)
+		domain := strings.TrimSuffix(rr.Name, ".")
+		switch rr.Type {
+		case dnsprobe.TypeA, dnsprobe.TypeAAAA:
+			ipList = append(ipList, map[string]interface{}{
+				"url":  domain,
+				"type": record["type"],
+				"ip":   record["ip"],
+			})
+		case dnsprobe.TypeCNAME:
+			cname, _ := record["target"].(string)
+			cnameList = append(cnameList, map[string]interface{}{
+				"url":   domain,
+				"type":  record["type"],
+				"cname": strings.TrimSuffix(cname, "."),
+			})
 		}
 	}
-
 	result["ips"] = ipList
 	result["cnames"] = cnameList
-	c.JSON(200, result)
+	return result
 }
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"strings"
+
+	"linkmaster-node/internal/dnsprobe"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleDnsCompare 向系统DNS、公共DNS和区域的权威服务器发送同一查询并对比应答
+func handleDnsCompare(c *gin.Context, url string, params map[string]interface{}) gin.H {
+	seq := ""
+	if seqVal, ok := params["seq"].(string); ok {
+		seq = seqVal
+	}
+
+	hostname, err := dnsprobe.ParseName(url)
+	if err != nil {
+		return targetError(seq, "ceDnsCompare", url, err)
+	}
+	result := gin.H{
+		"seq":    seq,
+		"type":   "ceDnsCompare",
+		"requrl": hostname,
+	}
+
+	opts, err := dnsprobe.ParseOptions(hostname, params)
+	if err != nil {
+		result["error"] = err.Error()
+		return result
+	}
+	result["ip_version"] = string(opts.Family)
+	result["settings"] = opts.Settings()
+
+	// system、public、authoritative 默认都参与对比；resolvers 参数替换默认的公共DNS
+	var resolvers []dnsprobe.Resolver
+	if enabled(params, "system") {
+		resolvers = append(resolvers, dnsprobe.Resolver{Kind: dnsprobe.ResolverSystem, Label: dnsprobe.ResolverSystem})
+	}
+	if list, ok := params["resolvers"].([]interface{}); ok {
+		custom := make([]string, 0, len(list))
+		for _, v := range list {
+			if s, ok := v.(string); ok && strings.TrimSpace(s) != "" {
+				custom = append(custom, strings.TrimSpace(s))
+			}
+		}
+		for _, ds := range custom {
+			resolvers = append(resolvers, dnsprobe.Resolver{Kind: dnsprobe.ResolverCustom, Label: ds, Server: ds})
+		}
+	} else if enabled(params, "public") {
+		resolvers = append(resolvers, dnsprobe.PublicResolvers(publicResolvers, dnsprobe.ResolverPublic, opts.Family)...)
+	}
+	if enabled(params, "authoritative") {
+		zone, servers, err := dnsprobe.FindAuthoritative(c.Request.Context(), opts.Name, opts)
+		if err != nil {
+			result["authoritative_error"] = err.Error()
+		} else {
+			result["zone"] = zone
+			for _, ns := range servers {
+				resolvers = append(resolvers, dnsprobe.Resolver{Kind: dnsprobe.ResolverAuthoritative, Label: ns.Name, Server: ns.Address()})
+			}
+		}
+	}
+
+	results := dnsprobe.Compare(c.Request.Context(), opts, resolvers)
+	rows, sets, disagree := dnsprobe.CompareMaps(results, opts.Type)
+	result["results"] = rows
+	result["answer_sets"] = sets
+	result["disagree"] = disagree
+	return result
+}
+
+// enabled 读取默认为 true 的布尔参数
+func enabled(params map[string]interface{}, key string) bool {
+	v, ok := params[key].(bool)
+	return !ok || v
+}
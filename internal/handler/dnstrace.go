@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"time"
+
+	"linkmaster-node/internal/dnsprobe"
+	"linkmaster-node/internal/stats"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleDnsTrace 从根服务器开始迭代解析域名（与 dig +trace 相同），记录每一级的引用和应答
+func handleDnsTrace(c *gin.Context, url string, params map[string]interface{}) gin.H {
+	seq := ""
+	if seqVal, ok := params["seq"].(string); ok {
+		seq = seqVal
+	}
+
+	hostname, err := dnsprobe.ParseName(url)
+	if err != nil {
+		return targetError(seq, "ceDnsTrace", url, err)
+	}
+	result := gin.H{
+		"seq":    seq,
+		"type":   "ceDnsTrace",
+		"requrl": hostname,
+	}
+
+	opts, err := dnsprobe.ParseOptions(hostname, params)
+	if err != nil {
+		result["error"] = err.Error()
+		return result
+	}
+	// 迭代查询总是从根服务器开始且不请求递归，ds 和 rd 参数不生效
+	opts.Server = ""
+	opts.Recursion = false
+	settings := opts.Settings()
+	delete(settings, "server")
+	result["ip_version"] = string(opts.Family)
+	result["settings"] = settings
+
+	roots := dnsprobe.RootServers(rootHints, opts.Family)
+	if len(roots) == 0 {
+		result["error"] = "没有可用的 IPv" + string(opts.Family) + " 根服务器"
+		return result
+	}
+
+	start := time.Now()
+	trace, err := dnsprobe.TraceQuery(c.Request.Context(), opts, roots)
+	steps := make([]map[string]interface{}, 0, len(trace.Steps))
+	for i, s := range trace.Steps {
+		step := s.Map()
+		step["step"] = i + 1
+		steps = append(steps, step)
+	}
+	result["steps"] = steps
+	result["total_time"] = stats.Round(stats.Milliseconds(time.Since(start)))
+	result["complete"] = trace.Final != nil
+	if err != nil {
+		result["error"] = err.Error()
+	}
+	if trace.Final != nil {
+		final := trace.Final.Map()
+		for _, k := range []string{"rcode", "flags", "answer", "authority", "server", "server_name"} {
+			result[k] = final[k]
+		}
+	}
+	return result
+}
@@ -1,14 +1,19 @@
 package handler
 
 import (
+	"context"
+	"errors"
 	"net"
 	"os/exec"
 	"sync"
+	"time"
+
+	"linkmaster-node/internal/pinger"
 
 	"github.com/gin-gonic/gin"
 )
 
-func handleFindPing(c *gin.Context, url string, params map[string]interface{}) {
+func handleFindPing(c *gin.Context, url string, params map[string]interface{}) gin.H {
 	// 获取seq参数
 	seq := ""
 	if seqVal, ok := params["seq"].(string); ok {
@@ -24,12 +29,11 @@ func handleFindPing(c *gin.Context, url string, params map[string]interface{}) {
 	// 解析CIDR
 	_, ipNet, err := net.ParseCIDR(cidr)
 	if err != nil {
-		c.JSON(200, gin.H{
+		return gin.H{
 			"seq":    seq,
 			"type":   "ceFindPing",
 			"error":  "无效的CIDR格式",
-		})
-		return
+		}
 	}
 
 	// 生成IP列表
@@ -59,9 +63,7 @@ func handleFindPing(c *gin.Context, url string, params map[string]interface{}) {
 			defer func() { <-semaphore }()
 
 			// 执行ping（只ping一次，快速检测）
-			cmd := exec.Command("ping", "-c", "1", "-W", "1", ipAddr)
-			err := cmd.Run()
-			if err == nil {
+			if isAlive(c.Request.Context(), ipAddr) {
 				mu.Lock()
 				aliveIPs = append(aliveIPs, ipAddr)
 				mu.Unlock()
@@ -71,14 +73,26 @@ func handleFindPing(c *gin.Context, url string, params map[string]interface{}) {
 
 	wg.Wait()
 
-	c.JSON(200, gin.H{
+	return gin.H{
 		"seq":         seq,
 		"type":        "ceFindPing",
 		"cidr":        cidr,
 		"alive_ips":   aliveIPs,
 		"alive_count": len(aliveIPs),
 		"total_ips":   len(ipList),
-	})
+	}
+}
+
+// isAlive 发送一个ICMP请求检测IP是否存活，无ICMP套接字权限时回退到系统ping命令
+func isAlive(ctx context.Context, ip string) bool {
+	opts := pinger.DefaultOptions()
+	opts.Count = 1
+	opts.Timeout = time.Second
+	res, err := pinger.Ping(ctx, ip, opts, nil)
+	if errors.Is(err, pinger.ErrUnavailable) {
+		return exec.Command("ping", "-c", "1", "-W", "1", ip).Run() == nil
+	}
+	return err == nil && res.Received > 0
 }
 
 func incIP(ip net.IP) {
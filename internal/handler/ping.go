@@ -2,41 +2,34 @@ package handler
 
 import (
 	"encoding/base64"
+	"errors"
 	"net"
 	"os/exec"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
+
+	"linkmaster-node/internal/netutil"
+	"linkmaster-node/internal/pinger"
+	"linkmaster-node/internal/stats"
 
 	"github.com/gin-gonic/gin"
 )
 
-func handlePing(c *gin.Context, url string, params map[string]interface{}) {
+func handlePing(c *gin.Context, url string, params map[string]interface{}) gin.H {
 	// 获取seq参数
 	seq := ""
 	if seqVal, ok := params["seq"].(string); ok {
 		seq = seqVal
 	}
 
-	// 解析URL，提取hostname
-	hostname := url
-	if strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://") {
-		// 从URL中提取hostname
-		parts := strings.Split(url, "//")
-		if len(parts) > 1 {
-			hostParts := strings.Split(parts[1], "/")
-			hostname = hostParts[0]
-			// 移除端口号
-			if idx := strings.Index(hostname, ":"); idx != -1 {
-				hostname = hostname[:idx]
-			}
-		}
+	// 解析目标，提取hostname
+	target, err := netutil.ParseTarget(url)
+	if err != nil {
+		return targetError(seq, "cePing", url, err)
 	}
-
-	// 执行ping命令
-	cmd := exec.Command("ping", "-c", "10", "-i", "0.5", hostname)
-	output, err := cmd.CombinedOutput()
-	outputStr := string(output)
+	hostname := target.Host
 
 	// 准备结果
 	result := map[string]interface{}{
@@ -46,13 +39,81 @@ func handlePing(c *gin.Context, url string, params map[string]interface{}) {
 		"ip":   "",
 	}
 
+	// 解析ping参数（count、interval_ms、size、ttl、timeout_ms、dont_fragment、source）
+	opts, err := pinger.ParseOptions(params)
+	if err != nil {
+		result["error"] = err.Error()
+		return result
+	}
+	result["ip_version"] = string(opts.Family)
+	result["settings"] = opts.Settings()
+
+	// 优先使用原生ICMP实现
+	res, err := pinger.Ping(c.Request.Context(), hostname, opts, nil)
+	if errors.Is(err, pinger.ErrUnavailable) {
+		// 没有ICMP套接字权限时回退到系统ping命令
+		return pingExec(result, hostname, opts)
+	}
+	result["engine"] = "native"
+	if err != nil {
+		result["error"] = err.Error()
+		return result
+	}
+
+	result["ip"] = res.IP
+	result["bytes"] = strconv.Itoa(res.Size)
+	result["header"] = base64.StdEncoding.EncodeToString([]byte(res.Text()))
+	result["packets_total"] = strconv.Itoa(res.Sent)
+	result["packets_recv"] = strconv.Itoa(res.Received)
+	result["packets_losrat"] = res.LossPercent()
+	result["packets"] = packetResults(res.Replies)
+
+	if res.Received == 0 {
+		result["error"] = "所有ICMP请求均超时"
+		return result
+	}
+	rtts, lost := res.Samples()
+	result["rtts"] = stats.RTTs(rtts, lost)
+	for k, v := range stats.Summarize(rtts, lost).Fields() {
+		result[k] = v
+	}
+
+	return result
+}
+
+// packetResults 返回每个包的序号、RTT（毫秒）、TTL和回复来源
+func packetResults(replies []pinger.Reply) []map[string]interface{} {
+	packets := make([]map[string]interface{}, 0, len(replies))
+	for _, reply := range replies {
+		packet := map[string]interface{}{
+			"seq":  reply.Seq,
+			"lost": reply.Lost,
+		}
+		if !reply.Lost {
+			packet["rtt"] = roundFloat(float64(reply.RTT)/float64(time.Millisecond), 3)
+			packet["ttl"] = reply.TTL
+			packet["from"] = reply.From
+		}
+		packets = append(packets, packet)
+	}
+	return packets
+}
+
+// pingExec 使用系统ping命令执行测试并解析文本输出
+func pingExec(result map[string]interface{}, hostname string, opts pinger.Options) gin.H {
+	result["engine"] = "exec"
+
+	// 执行ping命令
+	cmd := exec.Command("ping", append(opts.ExecArgs(), hostname)...)
+	output, err := cmd.CombinedOutput()
+	outputStr := string(output)
+
 	// 编码完整输出为base64（header字段）
 	result["header"] = base64.StdEncoding.EncodeToString([]byte(outputStr))
 
 	if err != nil {
 		result["error"] = err.Error()
-		c.JSON(200, result)
-		return
+		return result
 	}
 
 	// 解析ping输出
@@ -62,7 +123,7 @@ func handlePing(c *gin.Context, url string, params map[string]interface{}) {
 	for _, line := range lines {
 		if strings.Contains(line, "PING") {
 			// 提取IP地址，格式如：PING example.com (192.168.1.1) 56(84) bytes of data.
-			re := regexp.MustCompile(`\(([0-9.]+)\)`)
+			re := regexp.MustCompile(`\(([0-9a-fA-F.:]+)\)`)
 			matches := re.FindStringSubmatch(line)
 			if len(matches) > 1 {
 				result["ip"] = matches[1]
@@ -132,9 +193,12 @@ func handlePing(c *gin.Context, url string, params map[string]interface{}) {
 		// 解析时间统计（min/avg/max）
 		if strings.Contains(line, "min/avg/max") || strings.Contains(line, "rtt min/avg/max") {
 			// 格式如：rtt min/avg/max/mdev = 10.123/12.456/15.789/2.345 ms
-			re := regexp.MustCompile(`=\s*([0-9.]+)/([0-9.]+)/([0-9.]+)`)
+			re := regexp.MustCompile(`=\s*([0-9.]+)/([0-9.]+)/([0-9.]+)(?:/([0-9.]+))?`)
 			matches := re.FindStringSubmatch(line)
 			if len(matches) >= 4 {
+				if mdev, err := strconv.ParseFloat(matches[4], 64); err == nil {
+					result["time_mdev"] = mdev
+				}
 				if min, err := strconv.ParseFloat(matches[1], 64); err == nil {
 					result["time_min"] = min
 				}
@@ -167,5 +231,42 @@ func handlePing(c *gin.Context, url string, params map[string]interface{}) {
 		}
 	}
 
-	c.JSON(200, result)
+	// 从逐包输出中解析延迟，计算抖动和百分位
+	if rtts, lost := parseExecSamples(lines, opts.Count); len(rtts) > 0 {
+		result["rtts"] = stats.RTTs(rtts, lost)
+		for k, v := range stats.Summarize(rtts, lost).Fields() {
+			result[k] = v
+		}
+	}
+
+	return result
+}
+
+// parseExecSamples 解析ping命令逐包输出中的 icmp_seq 和 time，未出现的序号视为丢失
+func parseExecSamples(lines []string, count int) (rtts []float64, lost []bool) {
+	re := regexp.MustCompile(`icmp_seq=(\d+).*time[=<]([0-9.]+)\s*ms`)
+	received := make(map[int]float64)
+	for _, line := range lines {
+		matches := re.FindStringSubmatch(line)
+		if len(matches) < 3 {
+			continue
+		}
+		seq, err1 := strconv.Atoi(matches[1])
+		rtt, err2 := strconv.ParseFloat(matches[2], 64)
+		if err1 != nil || err2 != nil || seq < 1 || seq > count {
+			continue
+		}
+		received[seq] = rtt
+	}
+	if len(received) == 0 {
+		return nil, nil
+	}
+	rtts = make([]float64, count)
+	lost = make([]bool, count)
+	for seq := 1; seq <= count; seq++ {
+		rtt, ok := received[seq]
+		rtts[seq-1] = rtt
+		lost[seq-1] = !ok
+	}
+	return rtts, lost
 }
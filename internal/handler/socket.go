@@ -3,79 +3,50 @@ package handler
 import (
 	"net"
 	"strconv"
-	"strings"
 	"time"
 
+	"linkmaster-node/internal/netutil"
+
 	"github.com/gin-gonic/gin"
 )
 
-func handleSocket(c *gin.Context, url string, params map[string]interface{}) {
+func handleSocket(c *gin.Context, url string, params map[string]interface{}) gin.H {
 	// 获取seq参数
 	seq := ""
 	if seqVal, ok := params["seq"].(string); ok {
 		seq = seqVal
 	}
 
-	// 解析host:port格式
-	var host, portStr string
-	var port int
+	// 解析host:port格式（支持 [IPv6]:port、国际化域名和URL）
+	target, err := netutil.ParseTarget(url)
+	if err != nil {
+		return targetError(seq, "ceSocket", url, err)
+	}
+	host := target.Host
+	port := target.Port
 
-	// 尝试从URL中解析
-	if strings.Contains(url, ":") {
-		parts := strings.Split(url, ":")
-		if len(parts) >= 2 {
-			host = parts[0]
-			portStr = parts[len(parts)-1]
-		}
-	} else {
-		// 尝试从params中获取
-		if hostVal, ok := params["host"].(string); ok {
-			host = hostVal
+	// URL中没有端口时，尝试从params中获取host和port
+	if port == 0 {
+		if hostVal, ok := params["host"].(string); ok && hostVal != "" {
+			paramTarget, err := netutil.ParseTarget(hostVal)
+			if err != nil {
+				return targetError(seq, "ceSocket", url, err)
+			}
+			host = paramTarget.Host
 		}
 		if portVal, ok := params["port"].(string); ok {
-			portStr = portVal
+			port, _ = strconv.Atoi(portVal)
 		} else if portVal, ok := params["port"].(float64); ok {
-			portStr = strconv.Itoa(int(portVal))
+			port = int(portVal)
 		}
-	}
-
-	// 如果host为空，尝试从URL解析
-	if host == "" {
-		if strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://") {
-			parts := strings.Split(url, "//")
-			if len(parts) > 1 {
-				hostParts := strings.Split(parts[1], "/")
-				hostPort := hostParts[0]
-				if idx := strings.Index(hostPort, ":"); idx != -1 {
-					host = hostPort[:idx]
-					portStr = hostPort[idx+1:]
-				} else {
-					host = hostPort
-					if strings.HasPrefix(url, "https://") {
-						portStr = "443"
-					} else {
-						portStr = "80"
-					}
-				}
-			}
-		} else {
-			host = url
-			portStr = "80"
+		if port < 0 || port > 65535 {
+			return targetError(seq, "ceSocket", url, &netutil.TargetError{Code: netutil.ErrCodeInvalidPort, Input: url, Reason: "端口格式错误"})
+		}
+		if port == 0 {
+			port = 80
 		}
 	}
-
-	// 解析端口
-	var err error
-	port, err = strconv.Atoi(portStr)
-	if err != nil {
-		c.JSON(200, gin.H{
-			"seq":   seq,
-			"type":  "ceSocket",
-			"url":   url,
-			"error": "端口格式错误",
-		})
-		return
-	}
+	portStr := strconv.Itoa(port)
 
 	// 准备结果
 	result := map[string]interface{}{
@@ -87,32 +58,43 @@ func handleSocket(c *gin.Context, url string, params map[string]interface{}) {
 		"result": "false",
 	}
 
-	// 解析域名或IP
-	var ip string
-	parsedIP := net.ParseIP(host)
-	if parsedIP != nil {
-		ip = host
-	} else {
-		// DNS解析
-		ips, err := net.LookupIP(host)
-		if err != nil {
-			result["ip"] = ""
-			result["result"] = "域名无法解析"
-			c.JSON(200, result)
-			return
-		}
-		if len(ips) > 0 {
-			ip = ips[0].String()
-		}
+	// 主机地址覆盖（resolve/connect_to）
+	rules, err := netutil.ParseResolveRules(params)
+	if err != nil {
+		result["error"] = err.Error()
+		return result
+	}
+	overrideAddr, overridePort, pinned := rules.Lookup(host, portStr)
+	result["pinned"] = pinned
+	if pinned {
+		host, portStr = overrideAddr, overridePort
+	}
+
+	// 按地址族解析域名或IP
+	family, err := netutil.ParseFamily(params)
+	if err != nil {
+		result["error"] = err.Error()
+		return result
+	}
+	result["ip_version"] = string(family)
+	if hostIP := net.ParseIP(host); hostIP != nil && !family.Match(hostIP) {
+		result["error"] = "IP地址与ip_version不匹配"
+		return result
+	}
+	primaryIP, err := family.LookupPrimaryIP(c.Request.Context(), host)
+	if err != nil {
+		result["ip"] = ""
+		result["result"] = "域名无法解析"
+		return result
 	}
+	ip := primaryIP.String()
 
 	result["ip"] = ip
 
 	// 检查IP是否有效
 	if ip == "" || ip == "0.0.0.0" || ip == "127.0.0.0" {
 		result["result"] = "false"
-		c.JSON(200, result)
-		return
+		return result
 	}
 
 	// 执行TCP连接测试
@@ -122,11 +104,10 @@ func handleSocket(c *gin.Context, url string, params map[string]interface{}) {
 		if err.Error() != "" {
 			result["error"] = err.Error()
 		}
-		c.JSON(200, result)
-		return
+		return result
 	}
 	defer conn.Close()
 
 	result["result"] = "true"
-	c.JSON(200, result)
+	return result
 }
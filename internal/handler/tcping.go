@@ -3,74 +3,77 @@ package handler
 import (
 	"net"
 	"strconv"
-	"strings"
 	"time"
 
+	"linkmaster-node/internal/netutil"
+	"linkmaster-node/internal/stats"
+
 	"github.com/gin-gonic/gin"
 )
 
-func handleTCPing(c *gin.Context, url string, params map[string]interface{}) {
+func handleTCPing(c *gin.Context, url string, params map[string]interface{}) gin.H {
 	// 获取seq参数
 	seq := ""
 	if seqVal, ok := params["seq"].(string); ok {
 		seq = seqVal
 	}
 
-	// 解析host:port格式
-	parts := strings.Split(url, ":")
-	if len(parts) != 2 {
-		c.JSON(200, gin.H{
-			"seq":    seq,
-			"type":   "ceTCPing",
-			"url":    url,
-			"error":  "格式错误，需要 host:port",
-		})
-		return
+	// 解析host:port格式（支持 [IPv6]:port、国际化域名和URL）
+	target, err := netutil.ParseHostPort(url)
+	if err != nil {
+		return targetError(seq, "ceTCPing", url, err)
 	}
+	host := target.Host
+	port := target.Port
+	portStr := target.PortString()
 
-	host := parts[0]
-	portStr := parts[1]
-	port, err := strconv.Atoi(portStr)
+	// 主机地址覆盖（resolve/connect_to）
+	rules, err := netutil.ParseResolveRules(params)
 	if err != nil {
-		c.JSON(200, gin.H{
-			"seq":    seq,
-			"type":   "ceTCPing",
-			"url":    url,
-			"error":  "端口格式错误",
-		})
-		return
+		return gin.H{
+			"seq":   seq,
+			"type":  "ceTCPing",
+			"url":   url,
+			"error": err.Error(),
+		}
+	}
+	lookupHost, dialPort := host, portStr
+	overrideAddr, overridePort, pinned := rules.Lookup(host, portStr)
+	if pinned {
+		lookupHost, dialPort = overrideAddr, overridePort
 	}
 
-	// 解析hostname获取IP
-	var primaryIP string
-	ips, err := net.LookupIP(host)
-	if err == nil && len(ips) > 0 {
-		// 优先使用IPv4
-		for _, ip := range ips {
-			if ip.To4() != nil {
-				primaryIP = ip.String()
-				break
-			}
-		}
-		if primaryIP == "" && len(ips) > 0 {
-			primaryIP = ips[0].String()
+	// 按地址族解析hostname获取IP，所有测试都连接同一个IP
+	family, err := netutil.ParseFamily(params)
+	if err != nil {
+		return gin.H{
+			"seq":   seq,
+			"type":  "ceTCPing",
+			"url":   url,
+			"error": err.Error(),
 		}
 	}
+	var primaryIP string
+	dialAddr := net.JoinHostPort(lookupHost, dialPort)
+	if ip, err := family.LookupPrimaryIP(c.Request.Context(), lookupHost); err == nil {
+		primaryIP = ip.String()
+		dialAddr = net.JoinHostPort(primaryIP, dialPort)
+	}
 
 	// 执行多次TCP连接测试（默认10次，和PING一致）
 	const testCount = 10
-	var latencies []float64
+	rtts := make([]float64, testCount)
+	lost := make([]bool, testCount)
 	successCount := 0
-	failureCount := 0
 
 	for i := 0; i < testCount; i++ {
 		start := time.Now()
-		conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, portStr), 5*time.Second)
-		latency := time.Since(start).Milliseconds()
+		conn, err := net.DialTimeout(family.Network("tcp"), dialAddr, 5*time.Second)
+		rtt := time.Since(start)
 
 		if err == nil {
-			// 成功：记录延迟
-			latencies = append(latencies, float64(latency))
+			// 成功：记录延迟（保留亚毫秒精度）
+			rtts[i] = stats.Milliseconds(rtt)
 			successCount++
 			conn.Close()
 
@@ -82,52 +85,12 @@ func handleTCPing(c *gin.Context, url string, params map[string]interface{}) {
 			}
 		} else {
 			// 失败：记录为丢包
-			failureCount++
+			lost[i] = true
 		}
 	}
 
 	// 计算统计信息
-	packetsTotal := testCount
-	packetsRecv := successCount
-	packetsLosrat := float64(failureCount) / float64(testCount) * 100.0
-
-	var timeMin, timeMax, timeAvg float64
-	if len(latencies) > 0 {
-		timeMin = latencies[0]
-		timeMax = latencies[0]
-		sum := 0.0
-		for _, lat := range latencies {
-			if lat < timeMin {
-				timeMin = lat
-			}
-			if lat > timeMax {
-				timeMax = lat
-			}
-			sum += lat
-		}
-		timeAvg = sum / float64(len(latencies))
-	} else {
-		// 全部失败
-		timeMin = -1
-		timeMax = -1
-		timeAvg = -1
-	}
-
-	// 如果之前没有获取到IP，尝试从host解析
-	if primaryIP == "" {
-		ips, err := net.LookupIP(host)
-		if err == nil && len(ips) > 0 {
-			for _, ip := range ips {
-				if ip.To4() != nil {
-					primaryIP = ip.String()
-					break
-				}
-			}
-			if primaryIP == "" && len(ips) > 0 {
-				primaryIP = ips[0].String()
-			}
-		}
-	}
+	summary := stats.Summarize(rtts, lost)
 
 	// 返回格式和PING一致
 	result := gin.H{
@@ -137,20 +100,23 @@ func handleTCPing(c *gin.Context, url string, params map[string]interface{}) {
 		"ip":              primaryIP,
 		"host":            host,
 		"port":            port,
-		"packets_total":  strconv.Itoa(packetsTotal),
-		"packets_recv":   strconv.Itoa(packetsRecv),
-		"packets_losrat": packetsLosrat, // float64类型，百分比值（如10.5表示10.5%）
+		"pinned":          pinned,
+		"ip_version":      string(family),
+		"packets_total":  strconv.Itoa(summary.Sent),
+		"packets_recv":   strconv.Itoa(summary.Received),
+		"packets_losrat": summary.LossPercent(), // float64类型，百分比值（如10.5表示10.5%）
+		"rtts":           stats.RTTs(rtts, lost),
 	}
 	
-	// 时间字段：如果是-1（全部失败），返回字符串"-"，否则返回float64
-	if timeMin < 0 {
+	// 时间字段：全部失败时返回字符串"-"，否则返回float64（毫秒）
+	if fields := summary.Fields(); fields != nil {
+		for k, v := range fields {
+			result[k] = v
+		}
+	} else {
 		result["time_min"] = "-"
 		result["time_max"] = "-"
 		result["time_avg"] = "-"
-	} else {
-		result["time_min"] = timeMin
-		result["time_max"] = timeMax
-		result["time_avg"] = timeAvg
 	}
 
 	// 如果全部失败，添加error字段
@@ -158,6 +124,6 @@ func handleTCPing(c *gin.Context, url string, params map[string]interface{}) {
 		result["error"] = "所有TCP连接测试均失败"
 	}
 
-	c.JSON(200, result)
+	return result
 }
 
@@ -2,15 +2,36 @@ package handler
 
 import (
 	"net/http"
+	"sync"
+
+	"linkmaster-node/internal/enrich"
+	"linkmaster-node/internal/netutil"
 
 	"github.com/gin-gonic/gin"
 )
 
+// testHandler 单个测试类型的处理函数，返回测试结果
+type testHandler func(c *gin.Context, url string, params map[string]interface{}) gin.H
+
+var testHandlers = map[string]testHandler{
+	"ceGet":        handleGet,
+	"cePost":       handlePost,
+	"cePing":       handlePing,
+	"ceDns":        handleDns,
+	"ceDnsCompare": handleDnsCompare,
+	"ceDnsTrace":   handleDnsTrace,
+	"ceTrace":      handleTrace,
+	"ceSocket":     handleSocket,
+	"ceTCPing":     handleTCPing,
+	"ceUDP":        handleUDP,
+	"ceFindPing":   handleFindPing,
+}
+
 // HandleTest 统一测试接口
 func HandleTest(c *gin.Context) {
 	var req struct {
-		Type  string                 `json:"type" binding:"required"`
-		URL   string                 `json:"url" binding:"required"`
+		Type   string                 `json:"type" binding:"required"`
+		URL    string                 `json:"url" binding:"required"`
 		Params map[string]interface{} `json:"params"`
 	}
 
@@ -18,27 +39,79 @@ func HandleTest(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if req.Params == nil {
+		req.Params = map[string]interface{}{}
+	}
 
 	// 根据类型分发到不同的处理器
-	switch req.Type {
-	case "ceGet":
-		handleGet(c, req.URL, req.Params)
-	case "cePost":
-		handlePost(c, req.URL, req.Params)
-	case "cePing":
-		handlePing(c, req.URL, req.Params)
-	case "ceDns":
-		handleDns(c, req.URL, req.Params)
-	case "ceTrace":
-		handleTrace(c, req.URL, req.Params)
-	case "ceSocket":
-		handleSocket(c, req.URL, req.Params)
-	case "ceTCPing":
-		handleTCPing(c, req.URL, req.Params)
-	case "ceFindPing":
-		handleFindPing(c, req.URL, req.Params)
-	default:
+	handler, ok := testHandlers[req.Type]
+	if !ok {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的测试类型"})
+		return
+	}
+
+	family, err := netutil.ParseFamily(req.Params)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// ceFindPing 的地址族由CIDR决定，无需双栈执行
+	var result gin.H
+	if family == netutil.FamilyDual && req.Type != "ceFindPing" {
+		result = runDual(c, handler, req.Type, req.URL, req.Params)
+	} else {
+		result = handler(c, req.URL, req.Params)
+	}
+
+	// 为结果中的IP补充ASN、地理位置和PTR信息
+	if enabled, _ := req.Params["enrich"].(bool); enabled && result != nil {
+		result["ip_info"] = enrich.Annotate(c.Request.Context(), result)
+	}
+
+	c.JSON(200, result)
+}
+
+// runDual 分别使用IPv4和IPv6执行同一测试，并排返回两个结果
+func runDual(c *gin.Context, handler testHandler, testType, url string, params map[string]interface{}) gin.H {
+	seq, _ := params["seq"].(string)
+	families := []netutil.Family{netutil.FamilyIPv4, netutil.FamilyIPv6}
+	results := make([]gin.H, len(families))
+
+	var wg sync.WaitGroup
+	for i, family := range families {
+		// 每个地址族使用独立的参数副本
+		familyParams := make(map[string]interface{}, len(params))
+		for k, v := range params {
+			familyParams[k] = v
+		}
+		familyParams["ip_version"] = string(family)
+
+		wg.Add(1)
+		go func(i int, familyParams map[string]interface{}) {
+			defer wg.Done()
+			results[i] = handler(c, url, familyParams)
+		}(i, familyParams)
+	}
+	wg.Wait()
+
+	return gin.H{
+		"seq":        seq,
+		"type":       testType,
+		"url":        url,
+		"ip_version": string(netutil.FamilyDual),
+		"ipv4":       results[0],
+		"ipv6":       results[1],
+	}
+}
+
+// targetError 返回目标解析失败的结果，附带结构化错误码
+func targetError(seq, testType, url string, err error) gin.H {
+	return gin.H{
+		"seq":        seq,
+		"type":       testType,
+		"url":        url,
+		"error":      err.Error(),
+		"error_code": netutil.ErrorCode(err),
 	}
 }
 
@@ -46,4 +119,3 @@ func HandleTest(c *gin.Context) {
 func HandleHealth(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"status": "ok"})
 }
-
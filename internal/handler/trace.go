@@ -1,60 +1,130 @@
 package handler
 
 import (
+	"encoding/base64"
+	"errors"
 	"os/exec"
 	"strings"
 
+	"linkmaster-node/internal/netutil"
+	"linkmaster-node/internal/traceroute"
+
 	"github.com/gin-gonic/gin"
 )
 
-func handleTrace(c *gin.Context, url string, params map[string]interface{}) {
+func handleTrace(c *gin.Context, url string, params map[string]interface{}) gin.H {
 	// 获取seq参数
 	seq := ""
 	if seqVal, ok := params["seq"].(string); ok {
 		seq = seqVal
 	}
 
-	// 解析URL，提取hostname
-	hostname := url
-	if strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://") {
-		parts := strings.Split(url, "//")
-		if len(parts) > 1 {
-			hostParts := strings.Split(parts[1], "/")
-			hostname = hostParts[0]
-			if idx := strings.Index(hostname, ":"); idx != -1 {
-				hostname = hostname[:idx]
-			}
-		}
+	// 解析目标，提取hostname
+	target, err := netutil.ParseTarget(url)
+	if err != nil {
+		return targetError(seq, "ceTrace", url, err)
 	}
+	hostname := target.Host
 
-	// 执行traceroute命令
-	cmd := exec.Command("traceroute", "-m", "30", "-n", hostname)
-	output, err := cmd.CombinedOutput()
+	// 解析追踪参数（protocol、port、max_hops、queries、wait_ms、first_ttl、parallel）
+	opts, err := traceroute.ParseOptions(params)
 	if err != nil {
-		c.JSON(200, gin.H{
+		return gin.H{
 			"seq":   seq,
 			"type":  "ceTrace",
 			"url":   url,
 			"error": err.Error(),
-		})
-		return
+		}
+	}
+
+	result := gin.H{
+		"seq":        seq,
+		"type":       "ceTrace",
+		"url":        url,
+		"ip_version": string(opts.Family),
+		"settings":   opts.Settings(),
 	}
 
-	// 解析输出
-	lines := strings.Split(string(output), "\n")
-	traceResult := make([]string, 0)
+	if opts.Multipath {
+		return traceMultipath(c, result, hostname, opts)
+	}
+
+	// 优先使用原生实现
+	res, err := traceroute.Run(c.Request.Context(), hostname, opts)
+	if errors.Is(err, traceroute.ErrUnavailable) {
+		if opts.Paris {
+			result["error"] = errParisUnavailable
+			return result
+		}
+		// 没有原始套接字权限时回退到traceroute命令
+		return traceExec(result, hostname, opts)
+	}
+	result["engine"] = "native"
+	if err != nil {
+		result["error"] = err.Error()
+		return result
+	}
+
+	return traceResult(result, res, res.Text())
+}
+
+// traceroute 命令不支持保持流标识不变，Paris和多路径模式只能使用原生实现
+const errParisUnavailable = "Paris/多路径追踪需要原始套接字权限（root或CAP_NET_RAW）"
+
+// traceMultipath 用多个流分别追踪，返回各流的路径和合并后的路径图
+func traceMultipath(c *gin.Context, result gin.H, hostname string, opts traceroute.Options) gin.H {
+	m, err := traceroute.RunMultipath(c.Request.Context(), hostname, opts)
+	if errors.Is(err, traceroute.ErrUnavailable) {
+		result["error"] = errParisUnavailable
+		return result
+	}
+	result["engine"] = "native"
+	if err != nil {
+		result["error"] = err.Error()
+		return result
+	}
+
+	// 第一个流作为主要结果，与单路径追踪的字段一致
+	primary := m.Flows[0]
+	traceResult(result, primary, primary.Text())
+	paths, count := m.Paths()
+	result["paths"] = paths
+	result["path_count"] = count
+	result["graph"] = m.Graph()
+	return result
+}
+
+// traceExec 使用系统traceroute命令执行追踪并解析文本输出
+func traceExec(result gin.H, hostname string, opts traceroute.Options) gin.H {
+	result["engine"] = "exec"
+
+	// 执行traceroute命令
+	cmd := exec.Command("traceroute", append(opts.ExecArgs(), hostname)...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		result["error"] = err.Error()
+		return result
+	}
+
+	return traceResult(result, traceroute.ParseOutput(string(output)), string(output))
+}
+
+// traceResult 填充原始输出和结构化的逐跳结果
+func traceResult(result gin.H, res *traceroute.Result, output string) gin.H {
+	// 原始输出按行返回
+	lines := strings.Split(output, "\n")
+	traceLines := make([]string, 0)
 	for _, line := range lines {
 		line = strings.TrimSpace(line)
 		if line != "" {
-			traceResult = append(traceResult, line)
+			traceLines = append(traceLines, line)
 		}
 	}
 
-	c.JSON(200, gin.H{
-		"seq":          seq,
-		"type":         "ceTrace",
-		"url":          url,
-		"trace_result": traceResult,
-	})
+	result["ip"] = res.IP
+	result["header"] = base64.StdEncoding.EncodeToString([]byte(output))
+	result["trace_result"] = traceLines
+	result["hops"] = res.HopMaps()
+	result["reached"] = res.Reached
+	return result
 }
-
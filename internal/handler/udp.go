@@ -0,0 +1,167 @@
+package handler
+
+import (
+	"encoding/hex"
+	"net"
+	"strconv"
+
+	"linkmaster-node/internal/netutil"
+	"linkmaster-node/internal/stats"
+	"linkmaster-node/internal/udpprobe"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxReplyHex 结果中应答十六进制内容的最大字节数
+const maxReplyHex = 256
+
+func handleUDP(c *gin.Context, url string, params map[string]interface{}) gin.H {
+	// 获取seq参数
+	seq := ""
+	if seqVal, ok := params["seq"].(string); ok {
+		seq = seqVal
+	}
+
+	opts, err := udpprobe.ParseOptions(params)
+	if err != nil {
+		return gin.H{
+			"seq":   seq,
+			"type":  "ceUDP",
+			"url":   url,
+			"error": err.Error(),
+		}
+	}
+
+	// 解析host:port，使用负载模板时可省略端口（dns 53、ntp 123、snmp 161、stun 3478、quic 443）
+	target, err := netutil.ParseTarget(url)
+	if err != nil {
+		return targetError(seq, "ceUDP", url, err)
+	}
+	if target.Port == 0 {
+		target.Port = opts.DefaultPort()
+	}
+	if target.Port == 0 {
+		return targetError(seq, "ceUDP", url, &netutil.TargetError{Code: netutil.ErrCodeMissingPort, Input: url, Reason: "缺少端口，需要 host:port"})
+	}
+	host := target.Host
+	portStr := target.PortString()
+
+	result := gin.H{
+		"seq":        seq,
+		"type":       "ceUDP",
+		"url":        url,
+		"ip":         "",
+		"host":       host,
+		"port":       target.Port,
+		"ip_version": string(opts.Family),
+		"settings":   opts.Settings(),
+	}
+
+	// 主机地址覆盖（resolve/connect_to）
+	rules, err := netutil.ParseResolveRules(params)
+	if err != nil {
+		result["error"] = err.Error()
+		return result
+	}
+	lookupHost, dialPort := host, portStr
+	overrideAddr, overridePort, pinned := rules.Lookup(host, portStr)
+	if pinned {
+		lookupHost, dialPort = overrideAddr, overridePort
+	}
+	result["pinned"] = pinned
+
+	// 按地址族解析，所有探测都发往同一个IP
+	ip, err := opts.Family.LookupPrimaryIP(c.Request.Context(), lookupHost)
+	if err != nil {
+		result["error"] = err.Error()
+		return result
+	}
+	result["ip"] = ip.String()
+
+	attempts := udpprobe.Run(c.Request.Context(), net.JoinHostPort(ip.String(), dialPort), opts)
+
+	rtts := make([]float64, len(attempts))
+	lost := make([]bool, len(attempts))
+	probes := make([]map[string]interface{}, 0, len(attempts))
+	portUnreachable := 0
+	var reply map[string]interface{}
+	for i, a := range attempts {
+		probe := map[string]interface{}{
+			"seq":    a.Seq,
+			"status": a.Status,
+		}
+		if a.RTT > 0 {
+			probe["rtt"] = stats.Round(stats.Milliseconds(a.RTT))
+		}
+		if a.Err != nil && a.Status != udpprobe.StatusReply {
+			probe["error"] = a.Err.Error()
+		}
+		if a.Status == udpprobe.StatusReply {
+			// 成功：记录延迟（保留亚毫秒精度）
+			rtts[i] = stats.Milliseconds(a.RTT)
+			probe["size"] = a.Size
+			if reply == nil {
+				reply = udpReply(a)
+			}
+		} else {
+			// ICMP端口不可达等同样没有应答，记为丢包
+			lost[i] = true
+		}
+		if a.Status == udpprobe.StatusPortUnreachable {
+			portUnreachable++
+		}
+		probes = append(probes, probe)
+	}
+
+	// 计算统计信息，格式与ceTCPing一致
+	summary := stats.Summarize(rtts, lost)
+	result["packets_total"] = strconv.Itoa(summary.Sent)
+	result["packets_recv"] = strconv.Itoa(summary.Received)
+	result["packets_losrat"] = summary.LossPercent()
+	result["rtts"] = stats.RTTs(rtts, lost)
+	result["probes"] = probes
+	result["port_unreachable"] = portUnreachable > 0
+	result["port_unreachable_count"] = portUnreachable
+	if reply != nil {
+		result["reply"] = reply
+	}
+
+	// 时间字段：全部失败时返回字符串"-"，否则返回float64（毫秒）
+	if fields := summary.Fields(); fields != nil {
+		for k, v := range fields {
+			result[k] = v
+		}
+	} else {
+		result["time_min"] = "-"
+		result["time_max"] = "-"
+		result["time_avg"] = "-"
+	}
+
+	if summary.Received == 0 {
+		if portUnreachable > 0 {
+			result["error"] = "端口不可达（收到ICMP端口不可达）"
+		} else {
+			result["error"] = "所有UDP探测均未收到应答"
+		}
+	}
+	return result
+}
+
+// udpReply 返回第一个应答的内容：大小、十六进制（最多 maxReplyHex 字节）以及按模板解析的字段
+func udpReply(a udpprobe.Attempt) map[string]interface{} {
+	data := a.Reply
+	if len(data) > maxReplyHex {
+		data = data[:maxReplyHex]
+	}
+	reply := map[string]interface{}{
+		"size": a.Size,
+		"hex":  hex.EncodeToString(data),
+	}
+	for k, v := range a.Info {
+		reply[k] = v
+	}
+	if a.Err != nil {
+		reply["decode_error"] = a.Err.Error()
+	}
+	return reply
+}
@@ -0,0 +1,291 @@
+package httpprobe
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 断言类型
+const (
+	AssertStatus          = "status"
+	AssertBodyContains    = "body_contains"
+	AssertBodyNotContains = "body_not_contains"
+	AssertBodyRegex       = "body_regex"
+	AssertJSONPath        = "json_path"
+	AssertHeaderPresent   = "header_present"
+	AssertHeaderEquals    = "header_equals"
+	AssertMaxTotalTime    = "max_total_ms"
+)
+
+// Assertion 对响应内容的一条断言
+type Assertion struct {
+	Type     string
+	Target   string      // 头名称、JSON路径或正则表达式
+	Expected interface{} // 期望值
+	regex    *regexp.Regexp
+	statuses []statusRange
+}
+
+// AssertionResult 单条断言的执行结果
+type AssertionResult struct {
+	Type     string      `json:"type"`
+	Target   string      `json:"target,omitempty"`
+	Expected interface{} `json:"expected"`
+	Actual   interface{} `json:"actual"`
+	Passed   bool        `json:"passed"`
+	Message  string      `json:"message,omitempty"`
+}
+
+type statusRange struct {
+	min, max int
+}
+
+// ParseAssertions 解析 params 中的 assert 对象
+//
+// 格式示例：
+//
+//	{"status": ["200", "3xx", "400-404"], "body_contains": ["ok"], "body_not_contains": ["error"],
+//	 "body_regex": "version\":\\s*\"\\d+", "json_path": {"data.items[0].id": 1},
+//	 "header_present": ["X-Cache"], "header_equals": {"Content-Type": "application/json"},
+//	 "max_total_ms": 500}
+func ParseAssertions(raw interface{}) ([]Assertion, error) {
+	spec, ok := raw.(map[string]interface{})
+	if !ok {
+		if raw == nil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("assert 参数必须是对象")
+	}
+
+	var assertions []Assertion
+	if v, ok := spec[AssertStatus]; ok {
+		a := Assertion{Type: AssertStatus, Expected: v}
+		for _, item := range toList(v) {
+			r, err := parseStatusRange(item)
+			if err != nil {
+				return nil, err
+			}
+			a.statuses = append(a.statuses, r)
+		}
+		assertions = append(assertions, a)
+	}
+	for _, s := range toStrings(spec[AssertBodyContains]) {
+		assertions = append(assertions, Assertion{Type: AssertBodyContains, Expected: s})
+	}
+	for _, s := range toStrings(spec[AssertBodyNotContains]) {
+		assertions = append(assertions, Assertion{Type: AssertBodyNotContains, Expected: s})
+	}
+	for _, s := range toStrings(spec[AssertBodyRegex]) {
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return nil, fmt.Errorf("无效的正则表达式 %q: %v", s, err)
+		}
+		assertions = append(assertions, Assertion{Type: AssertBodyRegex, Target: s, Expected: true, regex: re})
+	}
+	if paths, ok := spec[AssertJSONPath].(map[string]interface{}); ok {
+		for _, path := range sortedKeys(paths) {
+			assertions = append(assertions, Assertion{Type: AssertJSONPath, Target: path, Expected: paths[path]})
+		}
+	}
+	for _, name := range toStrings(spec[AssertHeaderPresent]) {
+		assertions = append(assertions, Assertion{Type: AssertHeaderPresent, Target: name, Expected: true})
+	}
+	if headers, ok := spec[AssertHeaderEquals].(map[string]interface{}); ok {
+		for _, name := range sortedKeys(headers) {
+			assertions = append(assertions, Assertion{Type: AssertHeaderEquals, Target: name, Expected: fmt.Sprint(headers[name])})
+		}
+	}
+	if ms, ok := spec[AssertMaxTotalTime].(float64); ok {
+		assertions = append(assertions, Assertion{Type: AssertMaxTotalTime, Expected: ms})
+	}
+	return assertions, nil
+}
+
+// Evaluate 对探测结果执行全部断言，返回每条断言的结果和整体是否通过
+func Evaluate(res *Result, assertions []Assertion) ([]AssertionResult, bool) {
+	results := make([]AssertionResult, 0, len(assertions))
+	allPassed := true
+
+	var body []byte
+	var bodyDecoded bool
+	var jsonDoc interface{}
+	var jsonErr error
+	var jsonParsed bool
+
+	for _, a := range assertions {
+		r := AssertionResult{Type: a.Type, Target: a.Target, Expected: a.Expected}
+
+		if res.StatusCode == 0 {
+			r.Message = "未收到响应"
+			allPassed = false
+			results = append(results, r)
+			continue
+		}
+		if !bodyDecoded {
+			body = decodeBody(res)
+			bodyDecoded = true
+		}
+
+		switch a.Type {
+		case AssertStatus:
+			r.Actual = res.StatusCode
+			for _, sr := range a.statuses {
+				if res.StatusCode >= sr.min && res.StatusCode <= sr.max {
+					r.Passed = true
+					break
+				}
+			}
+		case AssertBodyContains:
+			r.Passed = bytes.Contains(body, []byte(a.Expected.(string)))
+			r.Actual = r.Passed
+		case AssertBodyNotContains:
+			r.Passed = !bytes.Contains(body, []byte(a.Expected.(string)))
+			r.Actual = !r.Passed
+		case AssertBodyRegex:
+			r.Passed = a.regex.Match(body)
+			r.Actual = r.Passed
+		case AssertJSONPath:
+			if !jsonParsed {
+				jsonErr = json.Unmarshal(body, &jsonDoc)
+				jsonParsed = true
+			}
+			if jsonErr != nil {
+				r.Message = "响应体不是合法JSON: " + jsonErr.Error()
+				break
+			}
+			actual, err := lookupJSONPath(jsonDoc, a.Target)
+			if err != nil {
+				r.Message = err.Error()
+				break
+			}
+			r.Actual = actual
+			r.Passed = reflect.DeepEqual(actual, a.Expected)
+		case AssertHeaderPresent:
+			_, r.Passed = res.Header[http.CanonicalHeaderKey(a.Target)]
+			r.Actual = r.Passed
+		case AssertHeaderEquals:
+			actual := res.Header.Get(a.Target)
+			r.Actual = actual
+			r.Passed = actual == a.Expected.(string)
+		case AssertMaxTotalTime:
+			actual := float64(res.Total) / float64(time.Millisecond)
+			r.Actual = actual
+			r.Passed = actual <= a.Expected.(float64)
+		}
+
+		if !r.Passed {
+			allPassed = false
+		}
+		results = append(results, r)
+	}
+	return results, allPassed
+}
+
+// decodeBody 返回解压后的响应体（请求时带了 Accept-Encoding: gzip）
+func decodeBody(res *Result) []byte {
+	if !strings.EqualFold(res.Header.Get("Content-Encoding"), "gzip") {
+		return res.Body
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(res.Body))
+	if err != nil {
+		return res.Body
+	}
+	defer zr.Close()
+	decoded, err := io.ReadAll(io.LimitReader(zr, 4*MaxBodySize))
+	if err != nil && len(decoded) == 0 {
+		return res.Body
+	}
+	return decoded
+}
+
+// lookupJSONPath 按 a.b[0].c 或 a.b.0.c 形式的路径取值
+func lookupJSONPath(doc interface{}, path string) (interface{}, error) {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	path = strings.NewReplacer("[", ".", "]", "").Replace(path)
+	current := doc
+	if path == "" {
+		return current, nil
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			v, ok := node[key]
+			if !ok {
+				return nil, fmt.Errorf("路径 %s 不存在", path)
+			}
+			current = v
+		case []interface{}:
+			idx, err := strconv.Atoi(key)
+			if err != nil || idx < 0 || idx >= len(node) {
+				return nil, fmt.Errorf("路径 %s 的数组下标 %s 无效", path, key)
+			}
+			current = node[idx]
+		default:
+			return nil, fmt.Errorf("路径 %s 不存在", path)
+		}
+	}
+	return current, nil
+}
+
+// parseStatusRange 解析 200、"2xx"、"200-299" 形式的状态码期望
+func parseStatusRange(v interface{}) (statusRange, error) {
+	if n, ok := v.(float64); ok {
+		return statusRange{int(n), int(n)}, nil
+	}
+	s := strings.ToLower(strings.TrimSpace(fmt.Sprint(v)))
+	if len(s) == 3 && strings.HasSuffix(s, "xx") && s[0] >= '1' && s[0] <= '5' {
+		base := int(s[0]-'0') * 100
+		return statusRange{base, base + 99}, nil
+	}
+	if lo, hi, found := strings.Cut(s, "-"); found {
+		min, err1 := strconv.Atoi(lo)
+		max, err2 := strconv.Atoi(hi)
+		if err1 == nil && err2 == nil && min <= max {
+			return statusRange{min, max}, nil
+		}
+	}
+	if n, err := strconv.Atoi(s); err == nil {
+		return statusRange{n, n}, nil
+	}
+	return statusRange{}, fmt.Errorf("无效的状态码断言: %v", v)
+}
+
+// sortedKeys 返回排序后的键，保证断言顺序稳定
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func toList(v interface{}) []interface{} {
+	if list, ok := v.([]interface{}); ok {
+		return list
+	}
+	if v == nil {
+		return nil
+	}
+	return []interface{}{v}
+}
+
+func toStrings(v interface{}) []string {
+	var out []string
+	for _, item := range toList(v) {
+		if s, ok := item.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
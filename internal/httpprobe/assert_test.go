@@ -0,0 +1,265 @@
+package httpprobe
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseStatusRange(t *testing.T) {
+	tests := []struct {
+		in      interface{}
+		want    statusRange
+		wantErr bool
+	}{
+		{float64(200), statusRange{200, 200}, false},
+		{"204", statusRange{204, 204}, false},
+		{" 2xx ", statusRange{200, 299}, false},
+		{"3XX", statusRange{300, 399}, false},
+		{"5xx", statusRange{500, 599}, false},
+		{"400-404", statusRange{400, 404}, false},
+		{"404-404", statusRange{404, 404}, false},
+		{"6xx", statusRange{}, true},
+		{"0xx", statusRange{}, true},
+		{"404-400", statusRange{}, true},
+		{"400-", statusRange{}, true},
+		{"ok", statusRange{}, true},
+		{"", statusRange{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseStatusRange(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseStatusRange(%v) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseStatusRange(%v) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLookupJSONPath(t *testing.T) {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(`{"a":{"b":[{"c":1},{"c":"two"}]},"ok":true,"list":[10,20]}`), &doc); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path    string
+		want    interface{}
+		wantErr bool
+	}{
+		{"a.b[0].c", float64(1), false},
+		{"a.b.1.c", "two", false},
+		{"$.a.b[1].c", "two", false},
+		{".ok", true, false},
+		{"list[1]", float64(20), false},
+		{"a.b[2].c", nil, true},  // 下标越界
+		{"a.b[-1].c", nil, true}, // 负数下标
+		{"a.b[x]", nil, true},    // 下标不是数字
+		{"a.missing", nil, true},
+		{"ok.deeper", nil, true}, // 标量之下没有子路径
+	}
+	for _, tt := range tests {
+		got, err := lookupJSONPath(doc, tt.path)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("lookupJSONPath(%q) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("lookupJSONPath(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+
+	// 空路径返回整个文档
+	if got, err := lookupJSONPath(doc, "$"); err != nil || got == nil {
+		t.Errorf("lookupJSONPath($) = %v, %v", got, err)
+	}
+}
+
+func TestParseAssertions(t *testing.T) {
+	var spec interface{}
+	err := json.Unmarshal([]byte(`{
+		"status": ["200", "3xx", 404],
+		"body_contains": ["ok", 1],
+		"body_not_contains": "error",
+		"body_regex": "v\\d+",
+		"json_path": {"b": 2, "a": 1},
+		"header_present": ["X-Cache"],
+		"header_equals": {"Content-Type": "application/json", "X-Count": 3},
+		"max_total_ms": 500
+	}`), &spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertions, err := ParseAssertions(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, a := range assertions {
+		got = append(got, a.Type+":"+a.Target)
+	}
+	want := []string{
+		"status:", "body_contains:", "body_not_contains:", "body_regex:v\\d+",
+		"json_path:a", "json_path:b", "header_present:X-Cache",
+		"header_equals:Content-Type", "header_equals:X-Count", "max_total_ms:",
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("assertions = %v, want %v", got, want)
+	}
+	if len(assertions[0].statuses) != 3 {
+		t.Errorf("status ranges = %v", assertions[0].statuses)
+	}
+	if assertions[8].Expected != "3" {
+		t.Errorf("header_equals expected = %#v, want \"3\"", assertions[8].Expected)
+	}
+
+	// 单个状态码不需要数组
+	if a, err := ParseAssertions(map[string]interface{}{"status": "2xx"}); err != nil || len(a) != 1 || a[0].statuses[0] != (statusRange{200, 299}) {
+		t.Errorf("single status = %+v, %v", a, err)
+	}
+	if a, err := ParseAssertions(nil); err != nil || a != nil {
+		t.Errorf("nil spec = %v, %v", a, err)
+	}
+
+	for _, bad := range []interface{}{
+		"status",
+		[]interface{}{"2xx"},
+		map[string]interface{}{"status": []interface{}{"2xx", "bad"}},
+		map[string]interface{}{"body_regex": "("},
+	} {
+		if _, err := ParseAssertions(bad); err == nil {
+			t.Errorf("ParseAssertions(%v) expected error", bad)
+		}
+	}
+}
+
+func gzipBytes(t *testing.T, s string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(s))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeBody(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+		want     string
+	}{
+		{"plain", "", []byte("hello"), "hello"},
+		{"gzip", "gzip", gzipBytes(t, "hello gzip"), "hello gzip"},
+		{"gzip header case", "GZIP", gzipBytes(t, "upper"), "upper"},
+		// 声明了gzip但内容不是gzip时返回原始内容
+		{"invalid gzip", "gzip", []byte("not gzip"), "not gzip"},
+		{"other encoding", "br", []byte("raw"), "raw"},
+	}
+	for _, tt := range tests {
+		res := &Result{Header: http.Header{}, Body: tt.body}
+		if tt.encoding != "" {
+			res.Header.Set("Content-Encoding", tt.encoding)
+		}
+		if got := string(decodeBody(res)); got != tt.want {
+			t.Errorf("%s: decodeBody = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeBodyTruncated(t *testing.T) {
+	full := strings.Repeat("0123456789", 1000)
+	body := gzipBytes(t, full)
+	body = body[:len(body)-8] // 去掉CRC和长度
+	res := &Result{Header: http.Header{"Content-Encoding": {"gzip"}}, Body: body}
+	// 截断的gzip返回已解压的部分
+	if got := string(decodeBody(res)); got == "" || !strings.HasPrefix(full, got) {
+		t.Errorf("decodeBody = %d bytes", len(got))
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	spec := func(s string) []Assertion {
+		t.Helper()
+		var raw interface{}
+		if err := json.Unmarshal([]byte(s), &raw); err != nil {
+			t.Fatal(err)
+		}
+		a, err := ParseAssertions(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return a
+	}
+	res := &Result{
+		StatusCode: 201,
+		Header:     http.Header{"Content-Type": {"application/json"}, "Content-Encoding": {"gzip"}, "X-Cache": {"HIT"}},
+		Body:       gzipBytes(t, `{"status":"ok","data":{"items":[{"id":7}]},"version":"v12"}`),
+		Total:      120 * time.Millisecond,
+	}
+
+	tests := []struct {
+		name   string
+		spec   string
+		passed []bool
+	}{
+		{"status range", `{"status":"2xx"}`, []bool{true}},
+		{"status list", `{"status":[200,"400-404"]}`, []bool{false}},
+		{"body contains gzip", `{"body_contains":["\"status\":\"ok\"","missing"]}`, []bool{true, false}},
+		{"body not contains", `{"body_not_contains":["error","items"]}`, []bool{true, false}},
+		{"body regex", `{"body_regex":["v\\d+","^x"]}`, []bool{true, false}},
+		{"json path", `{"json_path":{"data.items[0].id":7,"status":"fail","data.items[1].id":7}}`, []bool{true, false, false}},
+		{"header present", `{"header_present":["x-cache","X-Missing"]}`, []bool{true, false}},
+		{"header equals", `{"header_equals":{"content-type":"application/json","X-Cache":"MISS"}}`, []bool{false, true}},
+		{"max total", `{"max_total_ms":100}`, []bool{false}},
+		{"max total ok", `{"max_total_ms":120}`, []bool{true}},
+	}
+	for _, tt := range tests {
+		results, ok := Evaluate(res, spec(tt.spec))
+		if len(results) != len(tt.passed) {
+			t.Errorf("%s: %d results, want %d", tt.name, len(results), len(tt.passed))
+			continue
+		}
+		wantOK := true
+		for i, r := range results {
+			if r.Passed != tt.passed[i] {
+				t.Errorf("%s: result[%d] (%s %s) passed = %v, want %v (actual %v, %s)", tt.name, i, r.Type, r.Target, r.Passed, tt.passed[i], r.Actual, r.Message)
+			}
+			wantOK = wantOK && tt.passed[i]
+		}
+		if ok != wantOK {
+			t.Errorf("%s: ok = %v, want %v", tt.name, ok, wantOK)
+		}
+	}
+}
+
+func TestEvaluateNonJSONBody(t *testing.T) {
+	res := &Result{StatusCode: 200, Header: http.Header{}, Body: []byte("<html>")}
+	results, ok := Evaluate(res, []Assertion{{Type: AssertJSONPath, Target: "a", Expected: float64(1)}})
+	if ok || results[0].Passed || !strings.Contains(results[0].Message, "JSON") {
+		t.Errorf("results = %+v", results)
+	}
+}
+
+func TestEvaluateNoResponse(t *testing.T) {
+	assertions, err := ParseAssertions(map[string]interface{}{"status": "2xx", "body_not_contains": "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, ok := Evaluate(&Result{}, assertions)
+	if ok {
+		t.Error("expected failure without a response")
+	}
+	for _, r := range results {
+		if r.Passed || r.Message == "" {
+			t.Errorf("result = %+v", r)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package httpprobe
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"linkmaster-node/internal/netutil"
+)
+
+const (
+	DefaultTimeout      = 15 * time.Second
+	MaxTimeout          = 60 * time.Second
+	DefaultMaxRedirects = 20
+	MaxRedirectsLimit   = 30
+	MaxBodySize         = 1024 * 1024 // 请求体和读取响应体的上限：1MB
+)
+
+// UserAgents 可通过 ua 参数选择的 User-Agent 预设
+var UserAgents = map[string]string{
+	"iphone":  "Mozilla/5.0 (iPhone; CPU iPhone OS 11_0 like Mac OS X) AppleWebKit/604.1.38",
+	"android": "Mozilla/5.0 (Linux; Android 7.0; SM-G892A Build/NRD90M; wv) AppleWebKit/537.36",
+	"huawei":  "Mozilla/5.0 (Linux; Android 8.1; EML-L29 Build/HUAWEIEML-L29) AppleWebKit/537.36",
+	"chrome":  "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+	"curl":    "curl/8.5.0",
+}
+
+const defaultUserAgent = "iphone"
+
+var allowedMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodDelete:  true,
+	http.MethodOptions: true,
+	http.MethodPatch:   true,
+}
+
+// Options HTTP探测的请求参数
+type Options struct {
+	Method          string
+	URL             string
+	Headers         map[string]string
+	Body            string
+	ContentType     string
+	Timeout         time.Duration
+	FollowRedirects bool
+	MaxRedirects    int
+	UserAgent       string
+	Insecure        bool // 证书校验失败时仍继续请求（结果中照常记录校验结果）
+	Assertions      []Assertion
+	Resolve         netutil.ResolveRules // 主机地址覆盖，Host头和SNI保持原主机名
+	Family          netutil.Family       // 连接使用的地址族
+	KeepAlive       bool                 // 多次探测之间复用连接（持续测试使用）
+}
+
+// ParseOptions 从测试请求的 params 中解析探测参数，defaultMethod 为未指定 method 时使用的方法
+//
+// 支持的参数：method、headers（对象）、body/data、content_type、timeout（秒）、
+// follow_redirects、max_redirects、ua（预设名称或完整UA字符串）、insecure、assert（见 ParseAssertions）、
+// resolve/connect_to（见 netutil.ParseResolveRules）、ip_version（4/6/auto）、keep_alive
+func ParseOptions(rawURL string, params map[string]interface{}, defaultMethod string) (Options, error) {
+	opts := Options{
+		Method:          defaultMethod,
+		URL:             rawURL,
+		Headers:         map[string]string{},
+		Timeout:         DefaultTimeout,
+		FollowRedirects: true,
+		MaxRedirects:    DefaultMaxRedirects,
+		UserAgent:       UserAgents[defaultUserAgent],
+	}
+
+	if !strings.HasPrefix(opts.URL, "http://") && !strings.HasPrefix(opts.URL, "https://") {
+		opts.URL = "http://" + opts.URL
+	}
+	if _, err := netutil.ParseTarget(opts.URL); err != nil {
+		return opts, err
+	}
+
+	if method, ok := params["method"].(string); ok && method != "" {
+		opts.Method = strings.ToUpper(method)
+	}
+	if !allowedMethods[opts.Method] {
+		return opts, fmt.Errorf("不支持的请求方法: %s", opts.Method)
+	}
+
+	if headers, ok := params["headers"].(map[string]interface{}); ok {
+		for k, v := range headers {
+			opts.Headers[k] = fmt.Sprint(v)
+		}
+	}
+
+	// body 优先，兼容旧的 data 参数
+	if body, ok := params["body"].(string); ok {
+		opts.Body = body
+	} else if data, ok := params["data"].(string); ok {
+		opts.Body = data
+	}
+	if len(opts.Body) > MaxBodySize {
+		return opts, fmt.Errorf("请求体超过 %d 字节", MaxBodySize)
+	}
+	if ct, ok := params["content_type"].(string); ok {
+		opts.ContentType = ct
+	}
+
+	if timeout, ok := params["timeout"].(float64); ok && timeout > 0 {
+		opts.Timeout = time.Duration(timeout * float64(time.Second))
+		if opts.Timeout > MaxTimeout {
+			opts.Timeout = MaxTimeout
+		}
+	}
+
+	if follow, ok := params["follow_redirects"].(bool); ok {
+		opts.FollowRedirects = follow
+	}
+	if limit, ok := params["max_redirects"].(float64); ok && limit >= 0 {
+		opts.MaxRedirects = int(limit)
+		if opts.MaxRedirects > MaxRedirectsLimit {
+			opts.MaxRedirects = MaxRedirectsLimit
+		}
+	}
+
+	if insecure, ok := params["insecure"].(bool); ok {
+		opts.Insecure = insecure
+	}
+	if keepAlive, ok := params["keep_alive"].(bool); ok {
+		opts.KeepAlive = keepAlive
+	}
+
+	if ua, ok := params["ua"].(string); ok && ua != "" {
+		if preset, exists := UserAgents[strings.ToLower(ua)]; exists {
+			opts.UserAgent = preset
+		} else {
+			opts.UserAgent = ua
+		}
+	}
+
+	family, err := netutil.ParseFamily(params)
+	if err != nil {
+		return opts, err
+	}
+	if family == netutil.FamilyDual {
+		// 双栈由调用方拆分为两次探测
+		family = netutil.FamilyAuto
+	}
+	opts.Family = family
+
+	rules, err := netutil.ParseResolveRules(params)
+	if err != nil {
+		return opts, err
+	}
+	opts.Resolve = rules
+
+	assertions, err := ParseAssertions(params["assert"])
+	if err != nil {
+		return opts, err
+	}
+	opts.Assertions = assertions
+
+	return opts, nil
+}
@@ -0,0 +1,242 @@
+package httpprobe
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Hop 重定向链中的单跳请求及其实测时间
+type Hop struct {
+	URL          string
+	IP           string
+	Reused       bool
+	Pinned       bool   // 连接地址来自 resolve/connect_to 覆盖
+	StatusCode   int    // 未收到响应时为0
+	Location     string // 响应的 Location 头
+	DNS          time.Duration
+	Connect      time.Duration
+	TLSHandshake time.Duration
+	WroteRequest time.Duration // 从本跳开始到请求写完
+	FirstByte    time.Duration // 从本跳开始到收到首字节
+	Total        time.Duration // 从本跳开始到响应体读完
+}
+
+// Result 一次HTTP探测的结果，Err 不为空时其余字段可能只有部分有效
+type Result struct {
+	StatusCode int
+	Status     string
+	Proto      string
+	Header     http.Header
+	Body       []byte
+	IP         string
+	Hops       []Hop
+	TLS        *TLSInfo
+
+	// 断言结果，仅在设置了断言时有效
+	Assertions []AssertionResult
+	AssertOK   bool
+
+	// 以下时间均为最终响应（最后一跳）的实测值，Total 为全部跳加上读取响应体的总耗时
+	DNS          time.Duration
+	Connect      time.Duration
+	TLSHandshake time.Duration
+	FirstByte    time.Duration
+	Download     time.Duration
+	Total        time.Duration
+
+	Err error
+}
+
+// Prober HTTP探测器，可重复调用 Probe；同一时刻只执行一个探测
+type Prober struct {
+	opts      Options
+	client    *http.Client
+	transport *http.Transport
+	active    atomic.Pointer[collector]
+	mu        sync.Mutex
+}
+
+// NewProber 根据参数创建探测器，未开启 KeepAlive 时每次探测都会新建连接
+func NewProber(opts Options) *Prober {
+	p := &Prober{opts: opts}
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	p.transport = &http.Transport{
+		Proxy:             http.ProxyFromEnvironment,
+		DialContext:       opts.Resolve.DialContext(dialer, opts.Family),
+		DisableKeepAlives: !opts.KeepAlive,
+		ForceAttemptHTTP2: true,
+		TLSClientConfig: &tls.Config{
+			// 由VerifyConnection自行校验，以便校验失败时仍能记录证书链
+			InsecureSkipVerify: true,
+			VerifyConnection:   p.verifyConnection,
+		},
+	}
+	p.client = &http.Client{
+		Timeout:       opts.Timeout,
+		CheckRedirect: p.checkRedirect,
+	}
+	return p
+}
+
+// Close 关闭探测器持有的空闲连接
+func (p *Prober) Close() {
+	p.transport.CloseIdleConnections()
+}
+
+func (p *Prober) checkRedirect(req *http.Request, via []*http.Request) error {
+	if !p.opts.FollowRedirects {
+		return http.ErrUseLastResponse
+	}
+	if len(via) > p.opts.MaxRedirects {
+		// 达到上限时返回最后一个重定向响应，保留其状态码和 Location
+		return http.ErrUseLastResponse
+	}
+	return nil
+}
+
+// verifyConnection 校验证书链并把TLS会话信息记录到当前跳
+func (p *Prober) verifyConnection(cs tls.ConnectionState) error {
+	c := p.active.Load()
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	hop := c.current()
+	if hop == nil {
+		return nil
+	}
+	err := verifyPeer(cs, hop.host)
+	hop.tls = newTLSInfo(cs, err)
+	if p.opts.Insecure {
+		return nil
+	}
+	return err
+}
+
+// newRequest 按参数构造请求
+func (p *Prober) newRequest(ctx context.Context) (*http.Request, error) {
+	var body io.Reader
+	if p.opts.Body != "" {
+		body = strings.NewReader(p.opts.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, p.opts.Method, p.opts.URL, body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("User-Agent", p.opts.UserAgent)
+	req.Header.Set("Accept-Encoding", "gzip")
+	if p.opts.ContentType != "" {
+		req.Header.Set("Content-Type", p.opts.ContentType)
+	} else if p.opts.Body != "" {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	for k, v := range p.opts.Headers {
+		if strings.EqualFold(k, "Host") {
+			req.Host = v
+			continue
+		}
+		req.Header.Set(k, v)
+	}
+	return req, nil
+}
+
+// Probe 执行一次探测
+func (p *Prober) Probe(ctx context.Context) *Result {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	c := &collector{}
+	p.active.Store(c)
+	defer p.active.Store(nil)
+
+	result := p.probe(ctx, c)
+	if len(p.opts.Assertions) > 0 {
+		result.Assertions, result.AssertOK = Evaluate(result, p.opts.Assertions)
+	}
+	return result
+}
+
+func (p *Prober) probe(ctx context.Context, c *collector) *Result {
+	result := &Result{}
+	req, err := p.newRequest(ctx)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	client := *p.client
+	client.Transport = &timingTransport{transport: p.transport, collector: c, resolve: p.opts.Resolve}
+	resp, err := client.Do(req)
+	if err != nil {
+		result.Err = err
+		p.fillTimings(result, c)
+		return result
+	}
+
+	// 读取响应体（限制大小），读完后关闭以记录结束时间
+	body, err := io.ReadAll(io.LimitReader(resp.Body, MaxBodySize))
+	if err != nil && err != io.EOF {
+		result.Err = err
+	}
+	resp.Body.Close()
+
+	result.StatusCode = resp.StatusCode
+	result.Status = resp.Status
+	result.Proto = resp.Proto
+	result.Header = resp.Header
+	result.Body = body
+	p.fillTimings(result, c)
+	if result.IP == "" {
+		// 连接信息缺失时（如代理），直接使用URL中的IP
+		if u, err := url.Parse(p.opts.URL); err == nil && net.ParseIP(u.Hostname()) != nil {
+			result.IP = u.Hostname()
+		}
+	}
+	return result
+}
+
+// fillTimings 将收集到的时间点换算为各跳和最终响应的耗时
+func (p *Prober) fillTimings(result *Result, c *collector) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.hops) == 0 {
+		return
+	}
+
+	for _, h := range c.hops {
+		result.Hops = append(result.Hops, Hop{
+			URL:          h.url,
+			IP:           h.remoteIP,
+			Reused:       h.reused,
+			Pinned:       h.pinned,
+			StatusCode:   h.statusCode,
+			Location:     h.location,
+			DNS:          span(h.dnsStart, h.dnsDone),
+			Connect:      span(h.connectStart, h.connectDone),
+			TLSHandshake: span(h.tlsStart, h.tlsDone),
+			WroteRequest: span(h.start, h.wroteRequest),
+			FirstByte:    span(h.start, h.firstByte),
+			Total:        span(h.start, h.bodyDone),
+		})
+	}
+
+	last := c.hops[len(c.hops)-1]
+	result.IP = last.remoteIP
+	result.TLS = last.tls
+	result.DNS = span(last.dnsStart, last.dnsDone)
+	result.Connect = span(last.connectStart, last.connectDone)
+	result.TLSHandshake = span(last.tlsStart, last.tlsDone)
+	result.FirstByte = span(last.start, last.firstByte)
+	result.Download = span(last.firstByte, last.bodyDone)
+	result.Total = span(c.hops[0].start, last.bodyDone)
+}
@@ -0,0 +1,55 @@
+package httpprobe
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestProbeMaxRedirects(t *testing.T) {
+	// /0 -> /1 -> /2 -> /3，/3 返回200
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(r.URL.Path[1:])
+		if n < 3 {
+			http.Redirect(w, r, "/"+strconv.Itoa(n+1), http.StatusFound)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		params   map[string]interface{}
+		status   int
+		hops     int
+		location string
+	}{
+		{"no redirects allowed", map[string]interface{}{"max_redirects": float64(0)}, http.StatusFound, 1, "/1"},
+		{"limit reached", map[string]interface{}{"max_redirects": float64(2)}, http.StatusFound, 3, "/3"},
+		{"within limit", map[string]interface{}{"max_redirects": float64(3)}, http.StatusOK, 4, ""},
+		{"not following", map[string]interface{}{"follow_redirects": false}, http.StatusFound, 1, "/1"},
+	}
+	for _, tt := range tests {
+		opts, err := ParseOptions(srv.URL+"/0", tt.params, http.MethodGet)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := NewProber(opts)
+		res := p.Probe(context.Background())
+		p.Close()
+		if res.Err != nil {
+			t.Errorf("%s: err = %v", tt.name, res.Err)
+			continue
+		}
+		if res.StatusCode != tt.status || len(res.Hops) != tt.hops {
+			t.Errorf("%s: status = %d, hops = %d, want %d, %d", tt.name, res.StatusCode, len(res.Hops), tt.status, tt.hops)
+			continue
+		}
+		if last := res.Hops[len(res.Hops)-1]; last.StatusCode != tt.status || last.Location != tt.location {
+			t.Errorf("%s: last hop = %d %q, want %d %q", tt.name, last.StatusCode, last.Location, tt.status, tt.location)
+		}
+	}
+}
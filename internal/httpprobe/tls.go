@@ -0,0 +1,95 @@
+package httpprobe
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"strings"
+	"time"
+)
+
+// TLSInfo TLS会话及对端证书链信息
+type TLSInfo struct {
+	Version         string     `json:"version"`
+	CipherSuite     string     `json:"cipher_suite"`
+	ALPN            string     `json:"alpn"`
+	ServerName      string     `json:"server_name"`
+	OCSPStapled     bool       `json:"ocsp_stapled"`
+	Resumed         bool       `json:"resumed"`
+	Verified        bool       `json:"verified"`
+	VerifyError     string     `json:"verify_error"`
+	DaysUntilExpiry int        `json:"days_until_expiry"`
+	CertChain       []CertInfo `json:"cert_chain"`
+}
+
+// CertInfo 单张证书的摘要信息
+type CertInfo struct {
+	Subject            string   `json:"subject"`
+	Issuer             string   `json:"issuer"`
+	SANs               []string `json:"sans"`
+	Serial             string   `json:"serial"`
+	NotBefore          string   `json:"not_before"`
+	NotAfter           string   `json:"not_after"`
+	DaysUntilExpiry    int      `json:"days_until_expiry"`
+	SignatureAlgorithm string   `json:"signature_algorithm"`
+	IsCA               bool     `json:"is_ca"`
+}
+
+// verifyPeer 使用系统根证书校验对端证书链，serverName 为请求的主机名或IP
+func verifyPeer(cs tls.ConnectionState, serverName string) error {
+	if len(cs.PeerCertificates) == 0 {
+		return x509.CertificateInvalidError{Reason: x509.NotAuthorizedToSign, Detail: "对端未提供证书"}
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range cs.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+		DNSName:       serverName,
+		Intermediates: intermediates,
+	})
+	return err
+}
+
+// newTLSInfo 将TLS会话、证书链和校验结果转换为 TLSInfo
+func newTLSInfo(cs tls.ConnectionState, verifyErr error) *TLSInfo {
+	info := &TLSInfo{
+		Version:     tls.VersionName(cs.Version),
+		CipherSuite: tls.CipherSuiteName(cs.CipherSuite),
+		ALPN:        cs.NegotiatedProtocol,
+		ServerName:  cs.ServerName,
+		OCSPStapled: len(cs.OCSPResponse) > 0,
+		Resumed:     cs.DidResume,
+		Verified:    verifyErr == nil,
+		CertChain:   make([]CertInfo, 0, len(cs.PeerCertificates)),
+	}
+	if verifyErr != nil {
+		info.VerifyError = verifyErr.Error()
+	}
+	if len(cs.PeerCertificates) > 0 {
+		info.DaysUntilExpiry = daysUntil(cs.PeerCertificates[0].NotAfter)
+	}
+	for _, cert := range cs.PeerCertificates {
+		sans := make([]string, 0, len(cert.DNSNames)+len(cert.IPAddresses))
+		sans = append(sans, cert.DNSNames...)
+		for _, ip := range cert.IPAddresses {
+			sans = append(sans, ip.String())
+		}
+		info.CertChain = append(info.CertChain, CertInfo{
+			Subject:            cert.Subject.String(),
+			Issuer:             cert.Issuer.String(),
+			SANs:               sans,
+			Serial:             strings.ToUpper(cert.SerialNumber.Text(16)),
+			NotBefore:          cert.NotBefore.UTC().Format(time.RFC3339),
+			NotAfter:           cert.NotAfter.UTC().Format(time.RFC3339),
+			DaysUntilExpiry:    daysUntil(cert.NotAfter),
+			SignatureAlgorithm: cert.SignatureAlgorithm.String(),
+			IsCA:               cert.IsCA,
+		})
+	}
+	return info
+}
+
+// daysUntil 返回距离指定时间的天数，已过期时为负数
+func daysUntil(t time.Time) int {
+	return int(time.Until(t).Hours() / 24)
+}
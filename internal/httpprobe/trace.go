@@ -0,0 +1,171 @@
+package httpprobe
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sync"
+	"time"
+
+	"linkmaster-node/internal/netutil"
+)
+
+// hopTiming 记录单次请求（一个重定向跳）各阶段的实际时间点
+type hopTiming struct {
+	url          string
+	host         string
+	start        time.Time
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	wroteRequest time.Time
+	firstByte    time.Time
+	bodyDone     time.Time
+	remoteIP     string
+	reused       bool
+	pinned       bool
+	statusCode   int
+	location     string
+	tls          *TLSInfo
+}
+
+// collector 收集一次探测中所有跳的时间记录
+type collector struct {
+	hops []*hopTiming
+	mu   sync.Mutex
+}
+
+func (c *collector) mark(f func()) {
+	c.mu.Lock()
+	f()
+	c.mu.Unlock()
+}
+
+// current 返回正在进行的一跳
+func (c *collector) current() *hopTiming {
+	if len(c.hops) == 0 {
+		return nil
+	}
+	return c.hops[len(c.hops)-1]
+}
+
+// timingTransport 通过 httptrace 跟踪每一跳HTTP请求在实际连接上的各阶段时间
+type timingTransport struct {
+	transport http.RoundTripper
+	collector *collector
+	resolve   netutil.ResolveRules
+}
+
+func (t *timingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c := t.collector
+	hop := &hopTiming{url: req.URL.String(), host: req.URL.Hostname(), start: time.Now()}
+	_, _, hop.pinned = t.resolve.Lookup(req.URL.Hostname(), urlPort(req.URL))
+	c.mark(func() { c.hops = append(c.hops, hop) })
+
+	// 各回调可能在拨号goroutine中触发，统一加锁写入
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			c.mark(func() { hop.dnsStart = time.Now() })
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			c.mark(func() { hop.dnsDone = time.Now() })
+		},
+		ConnectStart: func(network, addr string) {
+			c.mark(func() {
+				// 多地址拨号时只记录第一次开始
+				if hop.connectStart.IsZero() {
+					hop.connectStart = time.Now()
+				}
+			})
+		},
+		ConnectDone: func(network, addr string, err error) {
+			if err != nil {
+				return
+			}
+			c.mark(func() { hop.connectDone = time.Now() })
+		},
+		TLSHandshakeStart: func() {
+			c.mark(func() { hop.tlsStart = time.Now() })
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			c.mark(func() { hop.tlsDone = time.Now() })
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			c.mark(func() {
+				hop.reused = info.Reused
+				if addr, ok := info.Conn.RemoteAddr().(*net.TCPAddr); ok {
+					hop.remoteIP = addr.IP.String()
+				}
+			})
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			c.mark(func() { hop.wroteRequest = time.Now() })
+		},
+		GotFirstResponseByte: func() {
+			c.mark(func() { hop.firstByte = time.Now() })
+		},
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+	resp, err := t.transport.RoundTrip(req)
+	if resp != nil {
+		c.mark(func() {
+			hop.statusCode = resp.StatusCode
+			hop.location = resp.Header.Get("Location")
+		})
+	}
+	if resp != nil && resp.Body != nil {
+		resp.Body = &timedBody{ReadCloser: resp.Body, done: func() {
+			c.mark(func() {
+				if hop.bodyDone.IsZero() {
+					hop.bodyDone = time.Now()
+				}
+			})
+		}}
+	}
+	return resp, err
+}
+
+// urlPort 返回URL的端口，未指定时按协议取默认端口
+func urlPort(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return port
+	}
+	if u.Scheme == "https" {
+		return "443"
+	}
+	return "80"
+}
+
+// timedBody 在响应体读完或关闭时记录时间
+type timedBody struct {
+	io.ReadCloser
+	done func()
+}
+
+func (b *timedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.done()
+	}
+	return n, err
+}
+
+func (b *timedBody) Close() error {
+	b.done()
+	return b.ReadCloser.Close()
+}
+
+// span 计算两个时间点之间的间隔，任一未发生时返回0
+func span(from, to time.Time) time.Duration {
+	if from.IsZero() || to.IsZero() || to.Before(from) {
+		return 0
+	}
+	return to.Sub(from)
+}
@@ -0,0 +1,121 @@
+package netutil
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Family 探测使用的地址族
+type Family string
+
+const (
+	FamilyAuto Family = "auto" // 默认：优先IPv4
+	FamilyIPv4 Family = "4"
+	FamilyIPv6 Family = "6"
+	FamilyDual Family = "dual" // 分别使用IPv4和IPv6执行并同时返回
+)
+
+// ParseFamily 解析 params 中的 ip_version 参数，支持 4、6、auto、dual
+func ParseFamily(params map[string]interface{}) (Family, error) {
+	v, ok := params["ip_version"]
+	if !ok || v == nil {
+		return FamilyAuto, nil
+	}
+	s := strings.ToLower(strings.TrimSpace(fmt.Sprint(v)))
+	switch s {
+	case "", "auto", "0":
+		return FamilyAuto, nil
+	case "4", "ipv4":
+		return FamilyIPv4, nil
+	case "6", "ipv6":
+		return FamilyIPv6, nil
+	case "dual", "both":
+		return FamilyDual, nil
+	}
+	return FamilyAuto, fmt.Errorf("无效的 ip_version: %v", v)
+}
+
+// Network 返回指定地址族的网络类型，如 tcp -> tcp4
+func (f Family) Network(network string) string {
+	switch f {
+	case FamilyIPv4:
+		return network + "4"
+	case FamilyIPv6:
+		return network + "6"
+	}
+	return network
+}
+
+// IPNetwork 返回 LookupIP 使用的网络类型
+func (f Family) IPNetwork() string {
+	return f.Network("ip")
+}
+
+// Match 判断IP是否属于该地址族
+func (f Family) Match(ip net.IP) bool {
+	switch f {
+	case FamilyIPv4:
+		return ip.To4() != nil
+	case FamilyIPv6:
+		return ip.To4() == nil
+	}
+	return true
+}
+
+// PickIP 从解析结果中选取一个IP：指定地址族时取该族的第一个，auto 时优先IPv4
+func (f Family) PickIP(ips []net.IP) net.IP {
+	if f == FamilyIPv4 || f == FamilyIPv6 {
+		for _, ip := range ips {
+			if f.Match(ip) {
+				return ip
+			}
+		}
+		return nil
+	}
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			return ip
+		}
+	}
+	if len(ips) > 0 {
+		return ips[0]
+	}
+	return nil
+}
+
+// LookupIP 按地址族解析主机名，host 本身是IP时直接校验地址族
+func (f Family) LookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		if !f.Match(ip) {
+			return nil, fmt.Errorf("%s 不是 IPv%s 地址", host, f)
+		}
+		return []net.IP{ip}, nil
+	}
+	return net.DefaultResolver.LookupIP(ctx, f.IPNetwork(), host)
+}
+
+// LookupPrimaryIP 按地址族解析主机名并选取一个IP
+func (f Family) LookupPrimaryIP(ctx context.Context, host string) (net.IP, error) {
+	ips, err := f.LookupIP(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	ip := f.PickIP(ips)
+	if ip == nil {
+		return nil, fmt.Errorf("%s 没有可用的地址", host)
+	}
+	return ip, nil
+}
+
+// Flag 返回系统命令（ping/traceroute/dig）对应的地址族参数，auto 时为空
+func (f Family) Flag() string {
+	switch f {
+	case FamilyIPv4:
+		return "-4"
+	case FamilyIPv6:
+		return "-6"
+	}
+	return ""
+}
@@ -0,0 +1,162 @@
+package netutil
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// ResolveRule 主机地址覆盖规则，等价于 curl 的 --resolve / --connect-to
+type ResolveRule struct {
+	Host     string // 原始主机名
+	Port     string // 原始端口，为空表示任意端口
+	Addr     string // 实际连接的地址（IP或主机名）
+	AddrPort string // 实际连接的端口，为空表示保持原端口
+}
+
+// ResolveRules 一组覆盖规则
+type ResolveRules []ResolveRule
+
+// ParseResolveRules 从 params 中解析 resolve 与 connect_to 参数
+//
+// resolve:    "host:port:ip"，port 可为 * 表示任意端口，IPv6 需要方括号，如 "example.com:443:[2001:db8::1]"
+// connect_to: "host:port:connect_host:connect_port"，connect_port 为空时保持原端口
+//
+// 两个参数都可以是单个字符串或字符串数组
+func ParseResolveRules(params map[string]interface{}) (ResolveRules, error) {
+	var rules ResolveRules
+	for _, entry := range stringList(params["resolve"]) {
+		host, port, rest, err := splitRuleHead(entry)
+		if err != nil {
+			return nil, err
+		}
+		addr := strings.TrimSuffix(strings.TrimPrefix(rest, "["), "]")
+		if net.ParseIP(addr) == nil {
+			return nil, fmt.Errorf("resolve 规则 %q 的地址不是合法IP", entry)
+		}
+		rules = append(rules, ResolveRule{Host: host, Port: port, Addr: addr})
+	}
+	for _, entry := range stringList(params["connect_to"]) {
+		host, port, rest, err := splitRuleHead(entry)
+		if err != nil {
+			return nil, err
+		}
+		addr, addrPort, err := splitHostPortLoose(rest)
+		if err != nil || addr == "" {
+			return nil, fmt.Errorf("connect_to 规则 %q 格式错误，需要 host:port:connect_host:connect_port", entry)
+		}
+		rules = append(rules, ResolveRule{Host: host, Port: port, Addr: addr, AddrPort: addrPort})
+	}
+	return rules, nil
+}
+
+// Lookup 返回 host:port 对应的覆盖地址
+func (r ResolveRules) Lookup(host, port string) (addr, addrPort string, ok bool) {
+	host = strings.TrimSuffix(host, ".")
+	for _, rule := range r {
+		if !strings.EqualFold(rule.Host, host) {
+			continue
+		}
+		if rule.Port != "" && rule.Port != port {
+			continue
+		}
+		addrPort = rule.AddrPort
+		if addrPort == "" {
+			addrPort = port
+		}
+		return rule.Addr, addrPort, true
+	}
+	return "", "", false
+}
+
+// Rewrite 按规则改写拨号地址，未命中时原样返回
+func (r ResolveRules) Rewrite(address string) string {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+	if addr, addrPort, ok := r.Lookup(host, port); ok {
+		return net.JoinHostPort(addr, addrPort)
+	}
+	return address
+}
+
+// DialContext 返回按规则改写目标地址并限定地址族的拨号函数
+func (r ResolveRules) DialContext(dialer *net.Dialer, family Family) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		return dialer.DialContext(ctx, family.Network(network), r.Rewrite(address))
+	}
+}
+
+// DialTimeout 与 net.DialTimeout 相同，但会先按规则改写目标地址
+func (r ResolveRules) DialTimeout(network, address string, timeout time.Duration) (net.Conn, error) {
+	return net.DialTimeout(network, r.Rewrite(address), timeout)
+}
+
+// splitRuleHead 拆出规则中的 host 和 port，返回剩余部分
+func splitRuleHead(entry string) (host, port, rest string, err error) {
+	entry = strings.TrimSpace(entry)
+	if strings.HasPrefix(entry, "[") {
+		end := strings.Index(entry, "]")
+		if end < 0 || len(entry) <= end+1 || entry[end+1] != ':' {
+			return "", "", "", fmt.Errorf("规则 %q 格式错误", entry)
+		}
+		host = entry[1:end]
+		entry = entry[end+2:]
+	} else {
+		idx := strings.Index(entry, ":")
+		if idx < 0 {
+			return "", "", "", fmt.Errorf("规则 %q 格式错误", entry)
+		}
+		host = entry[:idx]
+		entry = entry[idx+1:]
+	}
+	idx := strings.Index(entry, ":")
+	if idx < 0 || host == "" {
+		return "", "", "", fmt.Errorf("规则 %q 格式错误", entry)
+	}
+	port = entry[:idx]
+	if port == "*" {
+		port = ""
+	}
+	return strings.TrimSuffix(host, "."), port, entry[idx+1:], nil
+}
+
+// splitHostPortLoose 拆分 host:port，允许端口为空
+func splitHostPortLoose(s string) (host, port string, err error) {
+	if strings.HasPrefix(s, "[") {
+		end := strings.Index(s, "]")
+		if end < 0 {
+			return "", "", fmt.Errorf("缺少 ]")
+		}
+		host = s[1:end]
+		s = s[end+1:]
+		return host, strings.TrimPrefix(s, ":"), nil
+	}
+	idx := strings.LastIndex(s, ":")
+	if idx < 0 {
+		return s, "", nil
+	}
+	return s[:idx], s[idx+1:], nil
+}
+
+func stringList(v interface{}) []string {
+	switch val := v.(type) {
+	case string:
+		if val == "" {
+			return nil
+		}
+		return []string{val}
+	case []interface{}:
+		out := make([]string, 0, len(val))
+		for _, item := range val {
+			if s, ok := item.(string); ok && s != "" {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package netutil
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// 目标解析错误码
+const (
+	ErrCodeEmpty       = "empty_target"
+	ErrCodeInvalidURL  = "invalid_url"
+	ErrCodeInvalidHost = "invalid_host"
+	ErrCodeInvalidPort = "invalid_port"
+	ErrCodeMissingPort = "missing_port"
+)
+
+// TargetError 目标解析失败时返回的结构化错误
+type TargetError struct {
+	Code   string // 错误码，见 ErrCode* 常量
+	Input  string // 原始输入
+	Reason string // 可读的错误说明
+}
+
+func (e *TargetError) Error() string {
+	return fmt.Sprintf("无效的目标 %q: %s", e.Input, e.Reason)
+}
+
+// ErrorCode 返回错误对应的错误码，非 TargetError 时返回空字符串
+func ErrorCode(err error) string {
+	var te *TargetError
+	if errors.As(err, &te) {
+		return te.Code
+	}
+	return ""
+}
+
+// 常见协议的默认端口
+var defaultPorts = map[string]int{
+	"http":  80,
+	"https": 443,
+	"ws":    80,
+	"wss":   443,
+	"ftp":   21,
+	"ssh":   22,
+	"smtp":  25,
+	"dns":   53,
+	"tls":   853,
+	"quic":  853,
+}
+
+// Target 解析后的探测目标
+type Target struct {
+	Raw    string
+	Scheme string // 未带协议时为空
+	Host   string // 不带方括号；域名已转换为 punycode
+	Port   int    // 未指定且协议无默认端口时为0
+	Path   string // URL 的路径和查询部分
+	IsIP   bool
+}
+
+// ParseTarget 解析 host、host:port、[v6]:port、裸IPv6 以及带协议和路径的URL，
+// 国际化域名统一转换为 punycode
+func ParseTarget(raw string) (*Target, error) {
+	input := strings.TrimSpace(raw)
+	if input == "" {
+		return nil, &TargetError{Code: ErrCodeEmpty, Input: raw, Reason: "目标为空"}
+	}
+
+	t := &Target{Raw: raw}
+	var hostport string
+	if idx := strings.Index(input, "://"); idx > 0 {
+		u, err := url.Parse(input)
+		if err != nil || u.Host == "" {
+			return nil, &TargetError{Code: ErrCodeInvalidURL, Input: raw, Reason: "URL格式错误"}
+		}
+		t.Scheme = strings.ToLower(u.Scheme)
+		t.Path = u.RequestURI()
+		hostport = u.Host
+	} else {
+		hostport = input
+		// 裸IPv6中没有路径，其余情况从第一个 / 开始视为路径
+		if idx := strings.Index(input, "/"); idx >= 0 && net.ParseIP(input) == nil {
+			hostport, t.Path = input[:idx], input[idx:]
+		}
+	}
+
+	host, portStr, err := splitTargetHostPort(hostport)
+	if err != nil {
+		return nil, &TargetError{Code: ErrCodeInvalidHost, Input: raw, Reason: err.Error()}
+	}
+
+	if portStr != "" {
+		port, err := strconv.Atoi(portStr)
+		if err != nil || port < 1 || port > 65535 {
+			return nil, &TargetError{Code: ErrCodeInvalidPort, Input: raw, Reason: fmt.Sprintf("端口 %q 无效", portStr)}
+		}
+		t.Port = port
+	} else if port, ok := defaultPorts[t.Scheme]; ok {
+		t.Port = port
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		t.Host = ip.String()
+		t.IsIP = true
+		return t, nil
+	}
+
+	ascii, err := hostProfile.ToASCII(strings.TrimSuffix(host, "."))
+	if err != nil || ascii == "" || !validHostname(ascii) {
+		return nil, &TargetError{Code: ErrCodeInvalidHost, Input: raw, Reason: fmt.Sprintf("主机名 %q 无效", host)}
+	}
+	t.Host = ascii
+	return t, nil
+}
+
+// hostProfile 国际化域名转换规则：不启用STD3规则以允许 _ 等实际可解析的主机名，同时校验标签长度
+var hostProfile = idna.New(idna.MapForLookup(), idna.BidiRule(), idna.VerifyDNSLength(true), idna.StrictDomainName(false))
+
+// validHostname 检查转换后的主机名只含字母、数字、'-'、'_' 和 '.'
+func validHostname(host string) bool {
+	for _, c := range host {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// ParseHostPort 解析必须带端口的目标
+func ParseHostPort(raw string) (*Target, error) {
+	t, err := ParseTarget(raw)
+	if err != nil {
+		return nil, err
+	}
+	if t.Port == 0 {
+		return nil, &TargetError{Code: ErrCodeMissingPort, Input: raw, Reason: "缺少端口，需要 host:port"}
+	}
+	return t, nil
+}
+
+// PortString 返回端口字符串，未指定端口时为空
+func (t *Target) PortString() string {
+	if t.Port == 0 {
+		return ""
+	}
+	return strconv.Itoa(t.Port)
+}
+
+// Address 返回可用于拨号的 host:port
+func (t *Target) Address() string {
+	return net.JoinHostPort(t.Host, t.PortString())
+}
+
+// splitTargetHostPort 拆分主机和端口，支持 [v6]:port 与不带端口的裸IPv6
+func splitTargetHostPort(hostport string) (host, port string, err error) {
+	if hostport == "" {
+		return "", "", errors.New("缺少主机名")
+	}
+	if strings.HasPrefix(hostport, "[") {
+		end := strings.Index(hostport, "]")
+		if end < 0 {
+			return "", "", errors.New("IPv6地址缺少 ]")
+		}
+		host = hostport[1:end]
+		rest := hostport[end+1:]
+		if rest != "" {
+			if !strings.HasPrefix(rest, ":") {
+				return "", "", errors.New("IPv6地址后格式错误")
+			}
+			port = rest[1:]
+			if port == "" {
+				return "", "", errors.New("端口为空")
+			}
+		}
+		if net.ParseIP(host) == nil {
+			return "", "", fmt.Errorf("%q 不是合法的IPv6地址", host)
+		}
+		return host, port, nil
+	}
+
+	switch strings.Count(hostport, ":") {
+	case 0:
+		return hostport, "", nil
+	case 1:
+		host, port, _ = strings.Cut(hostport, ":")
+		if host == "" {
+			return "", "", errors.New("缺少主机名")
+		}
+		if port == "" {
+			return "", "", errors.New("端口为空")
+		}
+		return host, port, nil
+	default:
+		// 多个冒号只可能是不带端口的裸IPv6
+		if net.ParseIP(hostport) == nil {
+			return "", "", errors.New("IPv6地址带端口时需要使用 [addr]:port 格式")
+		}
+		return hostport, "", nil
+	}
+}
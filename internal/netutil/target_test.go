@@ -0,0 +1,149 @@
+package netutil
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseTarget(t *testing.T) {
+	tests := []struct {
+		in     string
+		scheme string
+		host   string
+		port   int
+		path   string
+		isIP   bool
+	}{
+		// 主机名和端口
+		{"example.com", "", "example.com", 0, "", false},
+		{"  Example.COM.  ", "", "example.com", 0, "", false},
+		{"example.com:8080", "", "example.com", 8080, "", false},
+		{"example.com/status?x=1", "", "example.com", 0, "/status?x=1", false},
+		{"_dmarc.example.com", "", "_dmarc.example.com", 0, "", false},
+		{"my_host.internal:22", "", "my_host.internal", 22, "", false},
+
+		// IPv4
+		{"192.0.2.1", "", "192.0.2.1", 0, "", true},
+		{"192.0.2.1:53", "", "192.0.2.1", 53, "", true},
+
+		// IPv6
+		{"2001:db8::1", "", "2001:db8::1", 0, "", true},
+		{"2001:DB8:0:0::1", "", "2001:db8::1", 0, "", true},
+		{"[2001:db8::1]", "", "2001:db8::1", 0, "", true},
+		{"[2001:db8::1]:443", "", "2001:db8::1", 443, "", true},
+		{"::1", "", "::1", 0, "", true},
+		{"::ffff:192.0.2.1", "", "192.0.2.1", 0, "", true},
+		{"http://[2001:db8::1]:8080/a", "http", "2001:db8::1", 8080, "/a", true},
+
+		// 国际化域名
+		{"münchen.de", "", "xn--mnchen-3ya.de", 0, "", false},
+		{"MÜNCHEN.de:443", "", "xn--mnchen-3ya.de", 443, "", false},
+		{"例子.测试", "", "xn--fsqu00a.xn--0zwm56d", 0, "", false},
+		{"xn--mnchen-3ya.de", "", "xn--mnchen-3ya.de", 0, "", false},
+		{"https://bücher.example/p?q=1", "https", "xn--bcher-kva.example", 443, "/p?q=1", false},
+
+		// URL 的默认端口
+		{"https://example.com", "https", "example.com", 443, "/", false},
+		{"HTTP://example.com/x", "http", "example.com", 80, "/x", false},
+		{"https://example.com:8443/", "https", "example.com", 8443, "/", false},
+		{"tls://dns.example", "tls", "dns.example", 853, "/", false},
+		{"gopher://example.com", "gopher", "example.com", 0, "/", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseTarget(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Scheme != tt.scheme || got.Host != tt.host || got.Port != tt.port || got.Path != tt.path || got.IsIP != tt.isIP {
+				t.Errorf("got scheme=%q host=%q port=%d path=%q isIP=%v", got.Scheme, got.Host, got.Port, got.Path, got.IsIP)
+			}
+			if got.Raw != tt.in {
+				t.Errorf("Raw = %q", got.Raw)
+			}
+		})
+	}
+}
+
+func TestParseTargetErrors(t *testing.T) {
+	tests := []struct {
+		in   string
+		code string
+	}{
+		{"", ErrCodeEmpty},
+		{"   ", ErrCodeEmpty},
+		{"http://", ErrCodeInvalidURL},
+		{"http://exa mple.com", ErrCodeInvalidURL},
+		{"example.com:0", ErrCodeInvalidPort},
+		{"example.com:65536", ErrCodeInvalidPort},
+		{"example.com:http", ErrCodeInvalidPort},
+		{"[2001:db8::1]:99999", ErrCodeInvalidPort},
+		{"example.com:", ErrCodeInvalidHost},
+		{":80", ErrCodeInvalidHost},
+		{"[2001:db8::1", ErrCodeInvalidHost},
+		{"[2001:db8::1]80", ErrCodeInvalidHost},
+		{"[2001:db8::1]:", ErrCodeInvalidHost},
+		{"[example.com]:80", ErrCodeInvalidHost},
+		{"2001:db8::1:443:x", ErrCodeInvalidHost},
+		{"exa mple.com", ErrCodeInvalidHost},
+		{"a..b", ErrCodeInvalidHost},
+		{"-foo.example", ErrCodeInvalidHost},
+		{"a*b.example", ErrCodeInvalidHost},
+		{strings.Repeat("x", 64) + ".example", ErrCodeInvalidHost},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			_, err := ParseTarget(tt.in)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if code := ErrorCode(err); code != tt.code {
+				t.Errorf("code = %q, want %q (%v)", code, tt.code, err)
+			}
+			var te *TargetError
+			if !errors.As(err, &te) || te.Input != tt.in {
+				t.Errorf("TargetError input = %+v", te)
+			}
+		})
+	}
+}
+
+func TestParseHostPort(t *testing.T) {
+	tests := []struct {
+		in      string
+		address string
+		code    string
+	}{
+		{"example.com:443", "example.com:443", ""},
+		{"[2001:db8::1]:53", "[2001:db8::1]:53", ""},
+		{"https://example.com", "example.com:443", ""},
+		{"example.com", "", ErrCodeMissingPort},
+		{"2001:db8::1", "", ErrCodeMissingPort},
+	}
+	for _, tt := range tests {
+		got, err := ParseHostPort(tt.in)
+		if tt.code != "" {
+			if ErrorCode(err) != tt.code {
+				t.Errorf("ParseHostPort(%q) err = %v, want code %q", tt.in, err, tt.code)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseHostPort(%q): %v", tt.in, err)
+			continue
+		}
+		if got.Address() != tt.address {
+			t.Errorf("ParseHostPort(%q).Address() = %q, want %q", tt.in, got.Address(), tt.address)
+		}
+	}
+}
+
+func TestErrorCodeNonTarget(t *testing.T) {
+	if code := ErrorCode(errors.New("other")); code != "" {
+		t.Errorf("ErrorCode = %q", code)
+	}
+	if code := ErrorCode(nil); code != "" {
+		t.Errorf("ErrorCode(nil) = %q", code)
+	}
+}
@@ -0,0 +1,31 @@
+package pinger
+
+import (
+	"errors"
+	"net"
+	"syscall"
+)
+
+// setDontFragment 关闭路径MTU发现的本地分片，使内核对发出的包设置DF位
+func setDontFragment(conn net.PacketConn, v6 bool) error {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return errors.New("不支持的连接类型")
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var serr error
+	err = raw.Control(func(fd uintptr) {
+		if v6 {
+			serr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_MTU_DISCOVER, syscall.IPV6_PMTUDISC_DO)
+		} else {
+			serr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_MTU_DISCOVER, syscall.IP_PMTUDISC_DO)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return serr
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package pinger
+
+import (
+	"errors"
+	"net"
+)
+
+func setDontFragment(conn net.PacketConn, v6 bool) error {
+	return errors.New("当前系统不支持设置DF位")
+}
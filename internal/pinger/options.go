@@ -0,0 +1,139 @@
+package pinger
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"time"
+
+	"linkmaster-node/internal/netutil"
+)
+
+// 参数的安全范围，超出范围的值会被收敛到边界
+const (
+	MinCount       = 1
+	MaxCount       = 100
+	MinInterval    = 200 * time.Millisecond // 非root用户的系统ping不接受更小的间隔，也避免持续ping每秒推送过多结果
+	MaxInterval    = 10 * time.Second
+	MaxSize        = 8192
+	MinTimeout     = 100 * time.Millisecond
+	MaxTimeout     = 10 * time.Second
+	MaxTTL         = 255
+	MaxPingRuntime = 60 * time.Second // 一次ping（count × interval）的最长发送时间
+)
+
+// ParseOptions 从测试请求的 params 中解析ping参数
+//
+// 支持的参数：count、interval_ms、size（负载字节数）、ttl、timeout_ms（单个包的等待时间）、
+// dont_fragment、source（源地址）、ip_version（4/6/auto）
+func ParseOptions(params map[string]interface{}) (Options, error) {
+	opts := DefaultOptions()
+
+	if count, ok := params["count"].(float64); ok {
+		opts.Count = clampInt(int(count), MinCount, MaxCount)
+	}
+	if interval, ok := params["interval_ms"].(float64); ok {
+		opts.Interval = clampDuration(time.Duration(interval)*time.Millisecond, MinInterval, MaxInterval)
+	}
+	if size, ok := params["size"].(float64); ok {
+		opts.Size = clampInt(int(size), 0, MaxSize)
+	}
+	if ttl, ok := params["ttl"].(float64); ok {
+		opts.TTL = clampInt(int(ttl), 1, MaxTTL)
+	}
+	if timeout, ok := params["timeout_ms"].(float64); ok {
+		opts.Timeout = clampDuration(time.Duration(timeout)*time.Millisecond, MinTimeout, MaxTimeout)
+	}
+	if df, ok := params["dont_fragment"].(bool); ok {
+		opts.DontFragment = df
+	}
+
+	// 限制总发送时间，避免单次请求占用过久
+	if time.Duration(opts.Count-1)*opts.Interval > MaxPingRuntime {
+		opts.Count = int(MaxPingRuntime/opts.Interval) + 1
+	}
+
+	family, err := netutil.ParseFamily(params)
+	if err != nil {
+		return opts, err
+	}
+	if family == netutil.FamilyDual {
+		// 双栈由调用方拆分为两次ping
+		family = netutil.FamilyAuto
+	}
+	opts.Family = family
+
+	if source, ok := params["source"].(string); ok && source != "" {
+		ip := net.ParseIP(source)
+		if ip == nil {
+			return opts, fmt.Errorf("source必须是IP地址: %s", source)
+		}
+		if !opts.Family.Match(ip) {
+			return opts, fmt.Errorf("source地址 %s 与ip_version不匹配", source)
+		}
+		opts.Source = ip
+	}
+
+	return opts, nil
+}
+
+// Settings 返回实际生效的参数，用于在结果中回显
+func (o Options) Settings() map[string]interface{} {
+	settings := map[string]interface{}{
+		"count":         o.Count,
+		"interval_ms":   o.Interval.Milliseconds(),
+		"size":          o.Size,
+		"ttl":           o.TTL,
+		"timeout_ms":    o.Timeout.Milliseconds(),
+		"dont_fragment": o.DontFragment,
+		"source":        "",
+	}
+	if o.Source != nil {
+		settings["source"] = o.Source.String()
+	}
+	return settings
+}
+
+// ExecArgs 返回等价的系统 ping 命令参数（不含目标），用于回退到 ping 命令
+func (o Options) ExecArgs() []string {
+	args := []string{
+		"-c", strconv.Itoa(o.Count),
+		"-i", strconv.FormatFloat(o.Interval.Seconds(), 'f', -1, 64),
+		"-s", strconv.Itoa(o.Size),
+		"-W", strconv.Itoa(int(math.Ceil(o.Timeout.Seconds()))),
+	}
+	if o.TTL > 0 {
+		args = append(args, "-t", strconv.Itoa(o.TTL))
+	}
+	if o.DontFragment {
+		args = append(args, "-M", "do")
+	}
+	if o.Source != nil {
+		args = append(args, "-I", o.Source.String())
+	}
+	if flag := o.Family.Flag(); flag != "" {
+		args = append(args, flag)
+	}
+	return args
+}
+
+func clampInt(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+func clampDuration(v, min, max time.Duration) time.Duration {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
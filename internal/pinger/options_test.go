@@ -0,0 +1,99 @@
+package pinger
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"linkmaster-node/internal/netutil"
+)
+
+func TestParseOptionsClamp(t *testing.T) {
+	tests := []struct {
+		name     string
+		params   map[string]interface{}
+		count    int
+		interval time.Duration
+		timeout  time.Duration
+		size     int
+		ttl      int
+	}{
+		{"defaults", map[string]interface{}{}, 10, 500 * time.Millisecond, 2 * time.Second, 56, 0},
+		{"lower bounds", map[string]interface{}{"count": float64(0), "interval_ms": float64(10), "timeout_ms": float64(1), "size": float64(-1), "ttl": float64(0)},
+			MinCount, MinInterval, MinTimeout, 0, 1},
+		// 低于200ms的间隔收敛到 MinInterval
+		{"interval floor", map[string]interface{}{"interval_ms": float64(150)}, 10, 200 * time.Millisecond, 2 * time.Second, 56, 0},
+		{"upper bounds", map[string]interface{}{"count": float64(1000), "interval_ms": float64(60000), "timeout_ms": float64(60000), "size": float64(65535), "ttl": float64(1000)},
+			7, MaxInterval, MaxTimeout, MaxSize, MaxTTL},
+		// (count-1) × interval 不超过 MaxPingRuntime
+		{"runtime", map[string]interface{}{"count": float64(100), "interval_ms": float64(1000)}, 61, time.Second, 2 * time.Second, 56, 0},
+		{"within runtime", map[string]interface{}{"count": float64(100), "interval_ms": float64(600)}, 100, 600 * time.Millisecond, 2 * time.Second, 56, 0},
+	}
+	for _, tt := range tests {
+		opts, err := ParseOptions(tt.params)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if opts.Count != tt.count || opts.Interval != tt.interval || opts.Timeout != tt.timeout || opts.Size != tt.size || opts.TTL != tt.ttl {
+			t.Errorf("%s: count/interval/timeout/size/ttl = %d/%v/%v/%d/%d, want %d/%v/%v/%d/%d", tt.name,
+				opts.Count, opts.Interval, opts.Timeout, opts.Size, opts.TTL,
+				tt.count, tt.interval, tt.timeout, tt.size, tt.ttl)
+		}
+	}
+}
+
+func TestParseOptionsFamily(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  map[string]interface{}
+		family  netutil.Family
+		source  string
+		wantErr bool
+	}{
+		{"dual as auto", map[string]interface{}{"ip_version": "dual"}, netutil.FamilyAuto, "", false},
+		{"ipv6 source", map[string]interface{}{"ip_version": float64(6), "source": "2001:db8::1"}, netutil.FamilyIPv6, "2001:db8::1", false},
+		{"auto source", map[string]interface{}{"source": "192.0.2.1"}, netutil.FamilyAuto, "192.0.2.1", false},
+		{"source mismatch", map[string]interface{}{"ip_version": "4", "source": "2001:db8::1"}, netutil.FamilyAuto, "", true},
+		{"source not ip", map[string]interface{}{"source": "eth0"}, netutil.FamilyAuto, "", true},
+		{"invalid ip_version", map[string]interface{}{"ip_version": "5"}, netutil.FamilyAuto, "", true},
+	}
+	for _, tt := range tests {
+		opts, err := ParseOptions(tt.params)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if opts.Family != tt.family || opts.Settings()["source"] != tt.source {
+			t.Errorf("%s: family = %v, source = %v", tt.name, opts.Family, opts.Source)
+		}
+	}
+}
+
+func TestExecArgs(t *testing.T) {
+	tests := []struct {
+		name   string
+		params map[string]interface{}
+		want   string
+	}{
+		{"defaults", map[string]interface{}{}, "-c 10 -i 0.5 -s 56 -W 2"},
+		// 超时按秒向上取整
+		{"timeout rounds up", map[string]interface{}{"count": float64(3), "interval_ms": float64(200), "timeout_ms": float64(1500)}, "-c 3 -i 0.2 -s 56 -W 2"},
+		{"ttl and df", map[string]interface{}{"ttl": float64(64), "dont_fragment": true, "size": float64(1472)}, "-c 10 -i 0.5 -s 1472 -W 2 -t 64 -M do"},
+		{"source ipv4", map[string]interface{}{"ip_version": "4", "source": "192.0.2.1"}, "-c 10 -i 0.5 -s 56 -W 2 -I 192.0.2.1 -4"},
+		{"ipv6", map[string]interface{}{"ip_version": "ipv6", "interval_ms": float64(1000)}, "-c 10 -i 1 -s 56 -W 2 -6"},
+	}
+	for _, tt := range tests {
+		opts, err := ParseOptions(tt.params)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := strings.Join(opts.ExecArgs(), " "); got != tt.want {
+			t.Errorf("%s: ExecArgs = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,441 @@
+// Package pinger 使用原生ICMP套接字实现 ping，优先使用非特权的数据报ICMP套接字
+// （需要 net.ipv4.ping_group_range 允许），否则使用原始套接字
+package pinger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"linkmaster-node/internal/netutil"
+	"linkmaster-node/internal/stats"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// ErrUnavailable 无法创建任何ICMP套接字（既无 ping_group_range 权限也无 CAP_NET_RAW），调用方应回退到 ping 命令
+var ErrUnavailable = errors.New("无法创建ICMP套接字")
+
+const (
+	protocolICMP     = 1
+	protocolIPv6ICMP = 58
+)
+
+// Options ping参数
+type Options struct {
+	Family   netutil.Family
+	Count    int
+	Interval time.Duration
+	Timeout  time.Duration // 单个包的等待时间
+	Size     int           // ICMP负载字节数
+	TTL      int           // 发出包的TTL/HopLimit，0表示使用系统默认值
+	// DontFragment 设置DF位（IPv6为禁止分片），超过路径MTU的包将发送失败
+	DontFragment bool
+	Source       net.IP // 源地址，为空时由系统选择
+}
+
+// DefaultOptions 与 `ping -c 10 -i 0.5` 一致的默认参数
+func DefaultOptions() Options {
+	return Options{
+		Family:   netutil.FamilyAuto,
+		Count:    10,
+		Interval: 500 * time.Millisecond,
+		Timeout:  2 * time.Second,
+		Size:     56,
+	}
+}
+
+// Reply 单个包的结果
+type Reply struct {
+	Seq  int
+	RTT  time.Duration
+	TTL  int    // 回复包的TTL/HopLimit，未知时为0
+	From string // 回复来源地址
+	Size int    // 回复的ICMP报文字节数
+	Lost bool   // 超时未收到回复
+}
+
+// Result 一次ping的结果
+type Result struct {
+	Host       string
+	IP         string
+	Size       int
+	Sent       int
+	Received   int
+	Replies    []Reply // 按序号排列
+	Privileged bool    // 是否使用了原始套接字
+	Elapsed    time.Duration
+}
+
+// Ping 向 host 发送 Count 个 echo 请求，onReply 不为空时每个包有结果（收到回复或超时）后立即回调
+func Ping(ctx context.Context, host string, opts Options, onReply func(Reply)) (*Result, error) {
+	if opts.Count <= 0 {
+		opts.Count = 1
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 2 * time.Second
+	}
+
+	// 指定了源地址时，目标地址族必须与源地址一致
+	family := opts.Family
+	if opts.Source != nil && family == netutil.FamilyAuto {
+		family = netutil.FamilyIPv4
+		if opts.Source.To4() == nil {
+			family = netutil.FamilyIPv6
+		}
+	}
+	ip, err := family.LookupPrimaryIP(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	p, err := newSession(ip, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer p.close()
+
+	result := &Result{Host: host, IP: ip.String(), Size: opts.Size, Privileged: p.privileged}
+	start := time.Now()
+	replies := p.run(ctx, opts, onReply)
+	result.Elapsed = time.Since(start)
+
+	result.Replies = replies
+	result.Sent = len(replies)
+	for _, r := range replies {
+		if !r.Lost {
+			result.Received++
+		}
+	}
+	return result, nil
+}
+
+// session 一个目标上的ICMP会话
+type session struct {
+	dst        net.IP
+	v6         bool
+	conn       *icmp.PacketConn
+	privileged bool
+	id         int
+}
+
+func newSession(dst net.IP, opts Options) (*session, error) {
+	s := &session{dst: dst, v6: dst.To4() == nil, id: rand.Intn(0xffff) + 1}
+	networks := []string{"udp4", "ip4:icmp"}
+	laddr := "0.0.0.0"
+	if s.v6 {
+		networks = []string{"udp6", "ip6:ipv6-icmp"}
+		laddr = "::"
+	}
+	if opts.Source != nil {
+		laddr = opts.Source.String()
+	}
+
+	var lastErr error
+	for i, network := range networks {
+		conn, err := icmp.ListenPacket(network, laddr)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		s.conn = conn
+		s.privileged = i == 1
+		break
+	}
+	if s.conn == nil {
+		// 源地址不是本机地址时不属于权限问题，不应回退到ping命令
+		if opts.Source != nil && errors.Is(lastErr, syscall.EADDRNOTAVAIL) {
+			return nil, fmt.Errorf("source地址 %s 不是本机地址", opts.Source)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, lastErr)
+	}
+	if err := s.configure(opts); err != nil {
+		s.conn.Close()
+		return nil, err
+	}
+
+	// 接收回复的TTL/HopLimit
+	if s.v6 {
+		s.conn.IPv6PacketConn().SetControlMessage(ipv6.FlagHopLimit, true)
+	} else {
+		s.conn.IPv4PacketConn().SetControlMessage(ipv4.FlagTTL, true)
+	}
+	return s, nil
+}
+
+// configure 设置发出包的TTL和DF位
+func (s *session) configure(opts Options) error {
+	if opts.TTL > 0 {
+		var err error
+		if s.v6 {
+			err = s.conn.IPv6PacketConn().SetHopLimit(opts.TTL)
+		} else {
+			err = s.conn.IPv4PacketConn().SetTTL(opts.TTL)
+		}
+		if err != nil {
+			return fmt.Errorf("设置TTL失败: %v", err)
+		}
+	}
+	if opts.DontFragment {
+		var conn net.PacketConn
+		if s.v6 {
+			conn = s.conn.IPv6PacketConn().PacketConn
+		} else {
+			conn = s.conn.IPv4PacketConn().PacketConn
+		}
+		if err := setDontFragment(conn, s.v6); err != nil {
+			return fmt.Errorf("设置DF位失败: %v", err)
+		}
+	}
+	return nil
+}
+
+func (s *session) close() {
+	s.conn.Close()
+}
+
+func (s *session) dstAddr() net.Addr {
+	if s.privileged {
+		return &net.IPAddr{IP: s.dst}
+	}
+	return &net.UDPAddr{IP: s.dst}
+}
+
+// send 发送一个 echo 请求
+func (s *session) send(seq, size int) error {
+	var typ icmp.Type = ipv4.ICMPTypeEcho
+	if s.v6 {
+		typ = ipv6.ICMPTypeEchoRequest
+	}
+	payload := make([]byte, size)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+	msg := icmp.Message{
+		Type: typ,
+		Body: &icmp.Echo{ID: s.id, Seq: seq, Data: payload},
+	}
+	b, err := msg.Marshal(nil)
+	if err != nil {
+		return err
+	}
+	_, err = s.conn.WriteTo(b, s.dstAddr())
+	return err
+}
+
+// read 读取一个回复，返回 echo reply 的序号、TTL、来源和报文长度
+func (s *session) read(buf []byte) (seq, ttl int, from net.IP, n int, ok bool, err error) {
+	var src net.Addr
+	proto := protocolICMP
+	if s.v6 {
+		proto = protocolIPv6ICMP
+		var cm *ipv6.ControlMessage
+		n, cm, src, err = s.conn.IPv6PacketConn().ReadFrom(buf)
+		if cm != nil {
+			ttl = cm.HopLimit
+		}
+	} else {
+		var cm *ipv4.ControlMessage
+		n, cm, src, err = s.conn.IPv4PacketConn().ReadFrom(buf)
+		if cm != nil {
+			ttl = cm.TTL
+		}
+	}
+	if err != nil {
+		return
+	}
+
+	switch addr := src.(type) {
+	case *net.UDPAddr:
+		from = addr.IP
+	case *net.IPAddr:
+		from = addr.IP
+	}
+	if from == nil || !from.Equal(s.dst) {
+		return
+	}
+
+	msg, perr := icmp.ParseMessage(proto, buf[:n])
+	if perr != nil {
+		return
+	}
+	if msg.Type != ipv4.ICMPTypeEchoReply && msg.Type != ipv6.ICMPTypeEchoReply {
+		return
+	}
+	echo, isEcho := msg.Body.(*icmp.Echo)
+	if !isEcho {
+		return
+	}
+	// 数据报套接字的ID由内核改写并过滤，原始套接字需要自行匹配
+	if s.privileged && echo.ID != s.id {
+		return
+	}
+	return echo.Seq, ttl, from, n, true, nil
+}
+
+// run 按间隔发送所有请求并收集结果
+func (s *session) run(ctx context.Context, opts Options, onReply func(Reply)) []Reply {
+	var mu sync.Mutex
+	sent := make(map[int]time.Time)
+	done := make(map[int]Reply)
+	finish := func(r Reply) bool {
+		// 调用方需持有 mu；返回 false 表示该包已有结果
+		if _, exists := done[r.Seq]; exists {
+			return false
+		}
+		done[r.Seq] = r
+		return true
+	}
+	// notify 在释放 mu 之后调用回调，避免慢回调阻塞发送和接收
+	notify := func(replies ...Reply) {
+		if onReply == nil {
+			return
+		}
+		for _, r := range replies {
+			onReply(r)
+		}
+	}
+
+	sendDone := make(chan struct{})
+	go func() {
+		defer close(sendDone)
+		for seq := 1; seq <= opts.Count; seq++ {
+			if seq > 1 {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(opts.Interval):
+				}
+			}
+			mu.Lock()
+			sent[seq] = time.Now()
+			mu.Unlock()
+			if err := s.send(seq, opts.Size); err != nil {
+				r := Reply{Seq: seq, Lost: true}
+				mu.Lock()
+				ok := finish(r)
+				mu.Unlock()
+				if ok {
+					notify(r)
+				}
+			}
+		}
+	}()
+
+	buf := make([]byte, 65536)
+	for {
+		// 先确认发送是否结束，再统计完成情况，避免最后一个包在两步之间发出而被遗漏
+		sending := true
+		select {
+		case <-sendDone:
+			sending = false
+		default:
+		}
+
+		// 检查超时的包
+		var expired []Reply
+		mu.Lock()
+		now := time.Now()
+		for seq, at := range sent {
+			if _, ok := done[seq]; !ok && now.Sub(at) > opts.Timeout {
+				r := Reply{Seq: seq, Lost: true}
+				finish(r)
+				expired = append(expired, r)
+			}
+		}
+		allDone := len(done) == len(sent)
+		mu.Unlock()
+		notify(expired...)
+
+		if !sending && allDone {
+			return sortedReplies(done)
+		}
+		if ctx.Err() != nil {
+			mu.Lock()
+			defer mu.Unlock()
+			return sortedReplies(done)
+		}
+
+		s.conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		seq, ttl, from, n, ok, err := s.read(buf)
+		// 收到回复后立即记录时间，不计入等待锁的时间
+		received := time.Now()
+		if err != nil || !ok {
+			continue
+		}
+		var reply *Reply
+		mu.Lock()
+		if at, exists := sent[seq]; exists {
+			r := Reply{Seq: seq, RTT: received.Sub(at), TTL: ttl, From: from.String(), Size: n}
+			if finish(r) {
+				reply = &r
+			}
+		}
+		mu.Unlock()
+		if reply != nil {
+			notify(*reply)
+		}
+	}
+}
+
+func sortedReplies(done map[int]Reply) []Reply {
+	replies := make([]Reply, 0, len(done))
+	for _, r := range done {
+		replies = append(replies, r)
+	}
+	sort.Slice(replies, func(i, j int) bool { return replies[i].Seq < replies[j].Seq })
+	return replies
+}
+
+// LossPercent 丢包率（百分比）
+func (r *Result) LossPercent() float64 {
+	if r.Sent == 0 {
+		return 100
+	}
+	return float64(r.Sent-r.Received) / float64(r.Sent) * 100
+}
+
+// Text 生成与 iputils ping 相同格式的文本输出，用于 header 字段
+func (r *Result) Text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "PING %s (%s) %d(%d) bytes of data.\n", r.Host, r.IP, r.Size, r.Size+r.headerLen())
+	for _, reply := range r.Replies {
+		if reply.Lost {
+			continue
+		}
+		fmt.Fprintf(&b, "%d bytes from %s: icmp_seq=%d ttl=%d time=%.3f ms\n", reply.Size, reply.From, reply.Seq, reply.TTL, stats.Milliseconds(reply.RTT))
+	}
+	fmt.Fprintf(&b, "\n--- %s ping statistics ---\n", r.Host)
+	fmt.Fprintf(&b, "%d packets transmitted, %d received, %g%% packet loss, time %dms\n",
+		r.Sent, r.Received, r.LossPercent(), r.Elapsed.Milliseconds())
+	if s := stats.Summarize(r.Samples()); s.Received > 0 {
+		fmt.Fprintf(&b, "rtt min/avg/max/mdev = %.3f/%.3f/%.3f/%.3f ms\n", s.Min, s.Avg, s.Max, s.StdDev)
+	}
+	return b.String()
+}
+
+// Samples 按序号返回每个包的延迟（毫秒）和是否丢失，用于 stats.Summarize
+func (r *Result) Samples() (rtts []float64, lost []bool) {
+	rtts = make([]float64, len(r.Replies))
+	lost = make([]bool, len(r.Replies))
+	for i, reply := range r.Replies {
+		rtts[i] = stats.Milliseconds(reply.RTT)
+		lost[i] = reply.Lost
+	}
+	return rtts, lost
+}
+
+func (r *Result) headerLen() int {
+	if strings.Contains(r.IP, ":") {
+		return 48 // IPv6头 + ICMPv6头
+	}
+	return 28 // IPv4头 + ICMP头
+}
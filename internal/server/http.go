@@ -34,6 +34,7 @@ func NewHTTPServer(cfg *config.Config) *HTTPServer {
 
 	// 初始化持续测试处理器
 	handler.InitContinuousHandler(cfg)
+	handler.InitDnsHandler(cfg)
 	
 	// 启动任务清理goroutine
 	handler.StartTaskCleanup()
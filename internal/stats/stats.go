@@ -0,0 +1,183 @@
+// Package stats 计算延迟样本的统计信息（最小/平均/最大、标准差、抖动和百分位），
+// 供 ping、tcping 和持续测试共用，所有数值的单位都是毫秒
+package stats
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Summary 一组延迟样本的统计结果
+type Summary struct {
+	Sent     int // 样本总数（含丢失）
+	Received int // 有效样本数
+	Min      float64
+	Avg      float64
+	Max      float64
+	StdDev   float64 // 总体标准差，与 ping 的 mdev 一致
+	// Jitter RFC 3550 的到达间隔抖动估计：J += (|D| - J) / 16，D为相邻两个有效样本的延迟差
+	Jitter float64
+	// JitterMean 相邻有效样本延迟差绝对值的平均值，样本较少时比 Jitter 更直观
+	JitterMean float64
+	P50        float64
+	P90        float64
+	P95        float64
+	P99        float64
+}
+
+// Milliseconds 将时长转换为毫秒浮点数，保留亚毫秒精度
+func Milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// Summarize 按发送顺序统计延迟样本（毫秒），lost 中为 true 的样本视为丢失不参与计算；
+// lost 为 nil 时所有样本都有效
+func Summarize(rtts []float64, lost []bool) Summary {
+	s := Summary{Sent: len(rtts)}
+	valid := make([]float64, 0, len(rtts))
+	for i, v := range rtts {
+		if lost != nil && i < len(lost) && lost[i] {
+			continue
+		}
+		valid = append(valid, v)
+	}
+	s.Received = len(valid)
+	if len(valid) == 0 {
+		return s
+	}
+
+	// 抖动按发送顺序计算
+	var sumDiff float64
+	for i := 1; i < len(valid); i++ {
+		d := math.Abs(valid[i] - valid[i-1])
+		s.Jitter += (d - s.Jitter) / 16
+		sumDiff += d
+	}
+	if len(valid) > 1 {
+		s.JitterMean = sumDiff / float64(len(valid)-1)
+	}
+
+	sum, sumSq := 0.0, 0.0
+	for _, v := range valid {
+		sum += v
+		sumSq += v * v
+	}
+	s.Avg = sum / float64(len(valid))
+	if variance := sumSq/float64(len(valid)) - s.Avg*s.Avg; variance > 0 {
+		s.StdDev = math.Sqrt(variance)
+	}
+
+	sorted := append([]float64(nil), valid...)
+	sort.Float64s(sorted)
+	s.Min = sorted[0]
+	s.Max = sorted[len(sorted)-1]
+	s.P50 = percentile(sorted, 50)
+	s.P90 = percentile(sorted, 90)
+	s.P95 = percentile(sorted, 95)
+	s.P99 = percentile(sorted, 99)
+	return s
+}
+
+// percentile 对已排序的样本做线性插值求百分位
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 1 {
+		return sorted[0]
+	}
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	if lower == upper {
+		return sorted[lower]
+	}
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
+
+// LossPercent 丢包率（百分比）
+func (s Summary) LossPercent() float64 {
+	if s.Sent == 0 {
+		return 0
+	}
+	return float64(s.Sent-s.Received) / float64(s.Sent) * 100
+}
+
+// Fields 返回结果中使用的统计字段，没有有效样本时返回 nil
+func (s Summary) Fields() map[string]interface{} {
+	if s.Received == 0 {
+		return nil
+	}
+	return map[string]interface{}{
+		"time_min":    Round(s.Min),
+		"time_avg":    Round(s.Avg),
+		"time_max":    Round(s.Max),
+		"time_mdev":   Round(s.StdDev),
+		"jitter":      Round(s.Jitter),
+		"jitter_mean": Round(s.JitterMean),
+		"p50":         Round(s.P50),
+		"p90":         Round(s.P90),
+		"p95":         Round(s.P95),
+		"p99":         Round(s.P99),
+	}
+}
+
+// RTTs 返回逐包延迟数组（毫秒，保留3位小数），丢失的包为 nil
+func RTTs(rtts []float64, lost []bool) []interface{} {
+	values := make([]interface{}, len(rtts))
+	for i, v := range rtts {
+		if lost != nil && i < len(lost) && lost[i] {
+			continue
+		}
+		values[i] = Round(v)
+	}
+	return values
+}
+
+// Round 保留3位小数（微秒精度）
+func Round(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
+
+// Window 保存最近若干个样本，用于持续测试中的滚动统计
+type Window struct {
+	mu   sync.Mutex
+	size int
+	rtts []float64
+	lost []bool
+}
+
+// NewWindow 创建最多保存 size 个样本的滚动窗口
+func NewWindow(size int) *Window {
+	return &Window{size: size}
+}
+
+// Add 添加一个样本，ok 为 false 表示丢失
+func (w *Window) Add(rtt float64, ok bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.rtts = append(w.rtts, rtt)
+	w.lost = append(w.lost, !ok)
+	if len(w.rtts) > w.size {
+		w.rtts = w.rtts[len(w.rtts)-w.size:]
+		w.lost = w.lost[len(w.lost)-w.size:]
+	}
+}
+
+// Summary 统计窗口内的样本
+func (w *Window) Summary() Summary {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return Summarize(w.rtts, w.lost)
+}
+
+// Fields 返回窗口统计字段，额外包含样本数和丢包率
+func (w *Window) Fields() map[string]interface{} {
+	s := w.Summary()
+	fields := s.Fields()
+	if fields == nil {
+		fields = map[string]interface{}{}
+	}
+	fields["samples"] = s.Sent
+	fields["packets_losrat"] = Round(s.LossPercent())
+	return fields
+}
@@ -0,0 +1,189 @@
+package stats
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestSummarize(t *testing.T) {
+	tests := []struct {
+		name string
+		rtts []float64
+		lost []bool
+		want Summary
+	}{
+		{
+			name: "empty",
+			want: Summary{},
+		},
+		{
+			name: "all lost",
+			rtts: []float64{1, 2, 3},
+			lost: []bool{true, true, true},
+			want: Summary{Sent: 3},
+		},
+		{
+			name: "single sample",
+			rtts: []float64{5},
+			want: Summary{Sent: 1, Received: 1, Min: 5, Avg: 5, Max: 5, P50: 5, P90: 5, P95: 5, P99: 5},
+		},
+		{
+			name: "single sample after loss",
+			rtts: []float64{0, 5},
+			lost: []bool{true, false},
+			want: Summary{Sent: 2, Received: 1, Min: 5, Avg: 5, Max: 5, P50: 5, P90: 5, P95: 5, P99: 5},
+		},
+		{
+			// 总体标准差：均值5，方差4
+			name: "stddev",
+			rtts: []float64{2, 4, 4, 4, 5, 5, 7, 9},
+			want: Summary{Sent: 8, Received: 8, Min: 2, Avg: 5, Max: 9, StdDev: 2,
+				Jitter: 0.378552682697773, JitterMean: 1,
+				P50: 4.5, P90: 7.6, P95: 8.3, P99: 8.86},
+		},
+		{
+			// lost 比样本短时，多出的样本视为有效
+			name: "short lost slice",
+			rtts: []float64{1, 3},
+			lost: []bool{false},
+			want: Summary{Sent: 2, Received: 2, Min: 1, Avg: 2, Max: 3, StdDev: 1,
+				Jitter: 0.125, JitterMean: 2,
+				P50: 2, P90: 2.8, P95: 2.9, P99: 2.98},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Summarize(tt.rtts, tt.lost)
+			if got.Sent != tt.want.Sent || got.Received != tt.want.Received {
+				t.Fatalf("sent/received = %d/%d, want %d/%d", got.Sent, got.Received, tt.want.Sent, tt.want.Received)
+			}
+			fields := []struct {
+				name      string
+				got, want float64
+			}{
+				{"Min", got.Min, tt.want.Min},
+				{"Avg", got.Avg, tt.want.Avg},
+				{"Max", got.Max, tt.want.Max},
+				{"StdDev", got.StdDev, tt.want.StdDev},
+				{"Jitter", got.Jitter, tt.want.Jitter},
+				{"JitterMean", got.JitterMean, tt.want.JitterMean},
+				{"P50", got.P50, tt.want.P50},
+				{"P90", got.P90, tt.want.P90},
+				{"P95", got.P95, tt.want.P95},
+				{"P99", got.P99, tt.want.P99},
+			}
+			for _, f := range fields {
+				if !almostEqual(f.got, f.want) {
+					t.Errorf("%s = %v, want %v", f.name, f.got, f.want)
+				}
+			}
+		})
+	}
+}
+
+func TestSummarizeNoValidSamples(t *testing.T) {
+	s := Summarize([]float64{1, 2}, []bool{true, true})
+	if s.LossPercent() != 100 {
+		t.Errorf("LossPercent = %v, want 100", s.LossPercent())
+	}
+	if s.Fields() != nil {
+		t.Errorf("Fields = %v, want nil", s.Fields())
+	}
+	if s := Summarize(nil, nil); s.LossPercent() != 0 {
+		t.Errorf("empty LossPercent = %v, want 0", s.LossPercent())
+	}
+}
+
+func TestJitter(t *testing.T) {
+	tests := []struct {
+		name       string
+		rtts       []float64
+		lost       []bool
+		jitter     float64
+		jitterMean float64
+	}{
+		// J1 = 10/16；J2 = J1 + (10 - J1)/16
+		{"rfc3550", []float64{10, 20, 10}, nil, 1.2109375, 10},
+		{"constant", []float64{7, 7, 7, 7}, nil, 0, 0},
+		// 丢失的样本不参与相邻差值
+		{"skip lost", []float64{10, 99, 20}, []bool{false, true, false}, 0.625, 10},
+		{"single", []float64{10}, nil, 0, 0},
+		// 延迟下降时按绝对值计算
+		{"decreasing", []float64{30, 14}, nil, 1, 16},
+	}
+	for _, tt := range tests {
+		s := Summarize(tt.rtts, tt.lost)
+		if !almostEqual(s.Jitter, tt.jitter) || !almostEqual(s.JitterMean, tt.jitterMean) {
+			t.Errorf("%s: jitter = %v/%v, want %v/%v", tt.name, s.Jitter, s.JitterMean, tt.jitter, tt.jitterMean)
+		}
+	}
+}
+
+func TestPercentiles(t *testing.T) {
+	tests := []struct {
+		name               string
+		rtts               []float64
+		p50, p90, p95, p99 float64
+	}{
+		{"two", []float64{20, 10}, 15, 19, 19.5, 19.9},
+		{"three unsorted", []float64{3, 1, 2}, 2, 2.8, 2.9, 2.98},
+		{"four", []float64{4, 1, 3, 2}, 2.5, 3.7, 3.85, 3.97},
+		{"five", []float64{1, 2, 3, 4, 5}, 3, 4.6, 4.8, 4.96},
+		{"equal", []float64{8, 8, 8}, 8, 8, 8, 8},
+		{"outlier", []float64{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 100}, 1, 1, 50.5, 90.1},
+	}
+	for _, tt := range tests {
+		s := Summarize(tt.rtts, nil)
+		got := []float64{s.P50, s.P90, s.P95, s.P99}
+		want := []float64{tt.p50, tt.p90, tt.p95, tt.p99}
+		for i := range got {
+			if !almostEqual(got[i], want[i]) {
+				t.Errorf("%s: percentiles = %v, want %v", tt.name, got, want)
+				break
+			}
+		}
+	}
+}
+
+func TestWindow(t *testing.T) {
+	w := NewWindow(3)
+	if f := w.Fields(); f["samples"] != 0 || f["packets_losrat"] != 0.0 || f["time_avg"] != nil {
+		t.Errorf("empty window fields = %v", f)
+	}
+
+	w.Add(1, true)
+	w.Add(2, false)
+	w.Add(3, true)
+	w.Add(4, true) // 移出最早的样本 1
+	s := w.Summary()
+	if s.Sent != 3 || s.Received != 2 || s.Min != 3 || s.Max != 4 {
+		t.Errorf("summary = %+v", s)
+	}
+	f := w.Fields()
+	if f["samples"] != 3 || f["packets_losrat"] != 33.333 || f["time_min"] != 3.0 {
+		t.Errorf("fields = %v", f)
+	}
+
+	w.Add(5, true) // 移出丢失的样本
+	if s := w.Summary(); s.Sent != 3 || s.Received != 3 || s.Min != 3 || s.Max != 5 || s.LossPercent() != 0 {
+		t.Errorf("summary after eviction = %+v", s)
+	}
+}
+
+func TestRTTs(t *testing.T) {
+	got := RTTs([]float64{1.23456, 2, 3}, []bool{false, true})
+	if len(got) != 3 || got[0] != 1.235 || got[1] != nil || got[2] != 3.0 {
+		t.Errorf("RTTs = %v", got)
+	}
+}
+
+func TestMilliseconds(t *testing.T) {
+	if got := Milliseconds(1500 * time.Microsecond); got != 1.5 {
+		t.Errorf("Milliseconds = %v, want 1.5", got)
+	}
+}
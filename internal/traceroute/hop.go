@@ -0,0 +1,156 @@
+// Package traceroute 描述路由追踪的逐跳结果
+package traceroute
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Probe 一个探测包的结果
+type Probe struct {
+	IP         string  // 回复来源，超时为空
+	RTT        float64 // 毫秒
+	Timeout    bool    // 超时未收到回复（*）
+	Annotation string  // ICMP不可达标注，如 !H、!N、!X
+}
+
+// Hop 一跳的结果
+type Hop struct {
+	TTL    int
+	Probes []Probe
+}
+
+// Result 一次路由追踪的结果
+type Result struct {
+	Target     string // 目标主机
+	IP         string // 目标IP
+	MaxHops    int
+	PacketSize int // 探测包的IP层总长度
+	Hops       []Hop
+	Reached    bool // 是否到达目标
+}
+
+// annotations ICMP不可达标注的含义
+var annotations = map[string]string{
+	"!H": "主机不可达",
+	"!N": "网络不可达",
+	"!P": "协议不可达",
+	"!S": "源路由失败",
+	"!F": "需要分片",
+	"!X": "通信被管理禁止",
+	"!V": "主机优先级违规",
+	"!C": "优先级截止",
+	"!U": "目标网络未知",
+	"!W": "目标主机未知",
+	"!I": "源主机被隔离",
+	"!A": "网络被管理禁止",
+	"!Z": "主机被管理禁止",
+	"!T": "服务类型不可达",
+}
+
+// AnnotationText 返回标注的中文说明，未知标注（如 !<code>）返回 ICMP 代码本身
+func AnnotationText(annotation string) string {
+	if text, ok := annotations[annotation]; ok {
+		return text
+	}
+	return "ICMP不可达 " + annotation
+}
+
+// IPs 返回本跳所有回复来源（去重，按出现顺序）
+func (h Hop) IPs() []string {
+	ips := make([]string, 0, 1)
+	seen := make(map[string]bool)
+	for _, p := range h.Probes {
+		if p.IP != "" && !seen[p.IP] {
+			seen[p.IP] = true
+			ips = append(ips, p.IP)
+		}
+	}
+	return ips
+}
+
+// Map 返回本跳的结构化结果
+func (h Hop) Map() map[string]interface{} {
+	probes := make([]map[string]interface{}, 0, len(h.Probes))
+	rtts := make([]interface{}, 0, len(h.Probes))
+	timeouts := 0
+	annotationList := make([]string, 0)
+	for _, p := range h.Probes {
+		probe := map[string]interface{}{
+			"timeout": p.Timeout,
+		}
+		if p.Timeout {
+			timeouts++
+			rtts = append(rtts, nil)
+		} else {
+			probe["ip"] = p.IP
+			probe["rtt"] = p.RTT
+			rtts = append(rtts, p.RTT)
+		}
+		if p.Annotation != "" {
+			probe["annotation"] = p.Annotation
+			probe["annotation_text"] = AnnotationText(p.Annotation)
+			annotationList = append(annotationList, p.Annotation)
+		}
+		probes = append(probes, probe)
+	}
+	return map[string]interface{}{
+		"hop":         h.TTL,
+		"ips":         h.IPs(),
+		"probes":      probes,
+		"rtts":        rtts,
+		"timeouts":    timeouts,
+		"annotations": annotationList,
+	}
+}
+
+// HopMaps 返回所有跳的结构化结果
+func (r *Result) HopMaps() []map[string]interface{} {
+	hops := make([]map[string]interface{}, 0, len(r.Hops))
+	for _, h := range r.Hops {
+		hops = append(hops, h.Map())
+	}
+	return hops
+}
+
+// Text 生成与 traceroute -n 相同格式的文本输出
+func (r *Result) Text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "traceroute to %s (%s), %d hops max, %d byte packets\n", r.Target, r.IP, r.MaxHops, r.PacketSize)
+	for _, h := range r.Hops {
+		fmt.Fprintf(&b, "%2d ", h.TTL)
+		lastIP := ""
+		for _, p := range h.Probes {
+			if p.Timeout {
+				b.WriteString(" *")
+				continue
+			}
+			if p.IP != lastIP {
+				fmt.Fprintf(&b, " %s", p.IP)
+				lastIP = p.IP
+			}
+			fmt.Fprintf(&b, "  %.3f ms", p.RTT)
+			if p.Annotation != "" {
+				fmt.Fprintf(&b, " %s", p.Annotation)
+			}
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// checkReached 任意一跳的回复来源是目标IP即视为到达
+func (r *Result) checkReached() {
+	r.Reached = false
+	if r.IP == "" {
+		return
+	}
+	for _, h := range r.Hops {
+		for _, p := range h.Probes {
+			if p.IP == r.IP {
+				r.Reached = true
+				return
+			}
+		}
+	}
+}
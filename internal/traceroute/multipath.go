@@ -0,0 +1,188 @@
+package traceroute
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// multipathConcurrency 同时进行的流数量，过多会触发路由器的ICMP限速
+const multipathConcurrency = 4
+
+// MultipathResult 多路径探测的结果，每个流是一次流标识不同的 Paris 追踪
+type MultipathResult struct {
+	Target string
+	IP     string
+	Flows  []*Result
+}
+
+// RunMultipath 用 opts.Flows 个不同的流标识分别追踪，枚举负载均衡下的不同路径
+func RunMultipath(ctx context.Context, host string, opts Options) (*MultipathResult, error) {
+	ip, err := opts.Family.LookupPrimaryIP(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	opts.Paris = true
+
+	result := &MultipathResult{Target: host, IP: ip.String(), Flows: make([]*Result, opts.Flows)}
+	errs := make([]error, opts.Flows)
+	sem := make(chan struct{}, multipathConcurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Flows; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			// 每次追踪使用新的套接字，源端口/ICMP校验和不同，即不同的流
+			result.Flows[i], errs[i] = trace(ctx, host, ip, opts)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// pathHops 流经过的路径，每跳取第一个回复来源，没有回复为 "*"
+func pathHops(r *Result) []string {
+	hops := make([]string, 0, len(r.Hops))
+	for _, h := range r.Hops {
+		ip := "*"
+		if ips := h.IPs(); len(ips) > 0 {
+			ip = ips[0]
+		}
+		hops = append(hops, ip)
+	}
+	return hops
+}
+
+// Paths 返回每个流的路径以及不同路径的数量，相同路径的流有相同的 path_id
+func (m *MultipathResult) Paths() ([]map[string]interface{}, int) {
+	ids := make(map[string]int)
+	paths := make([]map[string]interface{}, 0, len(m.Flows))
+	for i, r := range m.Flows {
+		hops := pathHops(r)
+		key := strings.Join(hops, ",")
+		id, ok := ids[key]
+		if !ok {
+			id = len(ids)
+			ids[key] = id
+		}
+		paths = append(paths, map[string]interface{}{
+			"flow":    i,
+			"path_id": id,
+			"hops":    hops,
+			"reached": r.Reached,
+		})
+	}
+	return paths, len(ids)
+}
+
+// Graph 将所有流的路径合并为逐跳的图：节点为（跳数, IP），边连接同一个流中相邻的两个有回复的节点，
+// 中间有未回复的跳时 gap 为跳过的跳数
+func (m *MultipathResult) Graph() map[string]interface{} {
+	type node struct {
+		hop   int
+		ip    string
+		flows []int
+	}
+	type edge struct {
+		from, to string
+		gap      int
+		flows    []int
+	}
+	nodes := make(map[string]*node)
+	edges := make(map[string]*edge)
+
+	for flow, r := range m.Flows {
+		var prev []string
+		prevTTL := 0
+		for _, h := range r.Hops {
+			ips := h.IPs()
+			if len(ips) == 0 {
+				continue
+			}
+			current := make([]string, 0, len(ips))
+			for _, ip := range ips {
+				id := fmt.Sprintf("%d|%s", h.TTL, ip)
+				n, ok := nodes[id]
+				if !ok {
+					n = &node{hop: h.TTL, ip: ip}
+					nodes[id] = n
+				}
+				n.flows = appendFlow(n.flows, flow)
+				current = append(current, id)
+			}
+			for _, from := range prev {
+				for _, to := range current {
+					key := from + ">" + to
+					e, ok := edges[key]
+					if !ok {
+						e = &edge{from: from, to: to, gap: h.TTL - prevTTL - 1}
+						edges[key] = e
+					}
+					e.flows = appendFlow(e.flows, flow)
+				}
+			}
+			prev = current
+			prevTTL = h.TTL
+		}
+	}
+
+	nodeIDs := make([]string, 0, len(nodes))
+	for id := range nodes {
+		nodeIDs = append(nodeIDs, id)
+	}
+	sort.Slice(nodeIDs, func(i, j int) bool {
+		a, b := nodes[nodeIDs[i]], nodes[nodeIDs[j]]
+		if a.hop != b.hop {
+			return a.hop < b.hop
+		}
+		return a.ip < b.ip
+	})
+	nodeList := make([]map[string]interface{}, 0, len(nodes))
+	for _, id := range nodeIDs {
+		n := nodes[id]
+		nodeList = append(nodeList, map[string]interface{}{
+			"id":    id,
+			"hop":   n.hop,
+			"ip":    n.ip,
+			"flows": n.flows,
+		})
+	}
+
+	edgeKeys := make([]string, 0, len(edges))
+	for key := range edges {
+		edgeKeys = append(edgeKeys, key)
+	}
+	sort.Strings(edgeKeys)
+	edgeList := make([]map[string]interface{}, 0, len(edges))
+	for _, key := range edgeKeys {
+		e := edges[key]
+		edgeList = append(edgeList, map[string]interface{}{
+			"from":  e.from,
+			"to":    e.to,
+			"gap":   e.gap,
+			"flows": e.flows,
+		})
+	}
+
+	return map[string]interface{}{
+		"nodes": nodeList,
+		"edges": edgeList,
+	}
+}
+
+func appendFlow(flows []int, flow int) []int {
+	if len(flows) > 0 && flows[len(flows)-1] == flow {
+		return flows
+	}
+	return append(flows, flow)
+}
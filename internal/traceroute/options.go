@@ -0,0 +1,187 @@
+package traceroute
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"linkmaster-node/internal/netutil"
+)
+
+// Protocol 探测包协议
+type Protocol string
+
+const (
+	ProtocolUDP  Protocol = "udp"  // UDP到递增的高端口，默认
+	ProtocolICMP Protocol = "icmp" // ICMP echo
+	ProtocolTCP  Protocol = "tcp"  // TCP SYN到指定端口
+)
+
+const (
+	DefaultUDPPort = 33434
+	DefaultTCPPort = 80
+	MaxHopsLimit   = 64
+	MaxQueries     = 10
+	MinWait        = 100 * time.Millisecond
+	MaxWait        = 10 * time.Second
+	MaxParallel    = 64
+	DefaultFlows   = 8
+	MaxFlows       = 32
+)
+
+// Options 路由追踪参数
+type Options struct {
+	Protocol Protocol
+	Port     int // UDP为起始目的端口（每个探测递增），TCP为目的端口
+	MaxHops  int
+	Queries  int           // 每跳探测次数
+	Wait     time.Duration // 单个探测的等待时间
+	FirstTTL int
+	Parallel int // 同时在途的探测数
+	Family   netutil.Family
+	// Paris 保持流标识不变（Paris traceroute）：UDP固定端口并用校验和区分探测，
+	// ICMP固定校验和，避免负载均衡把同一次追踪的探测分到不同路径上
+	Paris bool
+	// Multipath 用 Flows 个不同的流标识分别做 Paris 追踪，枚举等价多路径（ECMP）
+	Multipath bool
+	Flows     int
+}
+
+// DefaultOptions 与 `traceroute -n -m 30` 一致的默认参数
+func DefaultOptions() Options {
+	return Options{
+		Protocol: ProtocolUDP,
+		Port:     DefaultUDPPort,
+		MaxHops:  30,
+		Queries:  3,
+		Wait:     3 * time.Second,
+		FirstTTL: 1,
+		Parallel: 16,
+		Family:   netutil.FamilyAuto,
+		Flows:    DefaultFlows,
+	}
+}
+
+// ParseOptions 从测试请求的 params 中解析路由追踪参数，超出范围的值会被收敛到边界
+//
+// 支持的参数：protocol（udp/icmp/tcp）、port、max_hops、queries、wait_ms、first_ttl、parallel、
+// paris、multipath、flows、ip_version（4/6/auto）
+func ParseOptions(params map[string]interface{}) (Options, error) {
+	opts := DefaultOptions()
+
+	if protocol, ok := params["protocol"].(string); ok && protocol != "" {
+		switch Protocol(strings.ToLower(protocol)) {
+		case ProtocolUDP:
+			opts.Protocol = ProtocolUDP
+		case ProtocolICMP:
+			opts.Protocol = ProtocolICMP
+		case ProtocolTCP:
+			opts.Protocol = ProtocolTCP
+			opts.Port = DefaultTCPPort
+		default:
+			return opts, fmt.Errorf("不支持的探测协议: %s", protocol)
+		}
+	}
+	if port, ok := params["port"].(float64); ok {
+		if port < 1 || port > 65535 {
+			return opts, fmt.Errorf("端口超出范围: %v", port)
+		}
+		opts.Port = int(port)
+	}
+	if maxHops, ok := params["max_hops"].(float64); ok {
+		opts.MaxHops = clamp(int(maxHops), 1, MaxHopsLimit)
+	}
+	if queries, ok := params["queries"].(float64); ok {
+		opts.Queries = clamp(int(queries), 1, MaxQueries)
+	}
+	if wait, ok := params["wait_ms"].(float64); ok {
+		opts.Wait = time.Duration(wait) * time.Millisecond
+		if opts.Wait < MinWait {
+			opts.Wait = MinWait
+		}
+		if opts.Wait > MaxWait {
+			opts.Wait = MaxWait
+		}
+	}
+	if firstTTL, ok := params["first_ttl"].(float64); ok {
+		opts.FirstTTL = clamp(int(firstTTL), 1, opts.MaxHops)
+	}
+	if parallel, ok := params["parallel"].(float64); ok {
+		opts.Parallel = clamp(int(parallel), 1, MaxParallel)
+	}
+
+	if paris, ok := params["paris"].(bool); ok {
+		opts.Paris = paris
+	}
+	if multipath, ok := params["multipath"].(bool); ok && multipath {
+		// 多路径探测的每个流都需要保持流标识不变
+		opts.Multipath = true
+		opts.Paris = true
+	}
+	if flows, ok := params["flows"].(float64); ok {
+		opts.Flows = clamp(int(flows), 2, MaxFlows)
+	}
+
+	family, err := netutil.ParseFamily(params)
+	if err != nil {
+		return opts, err
+	}
+	if family == netutil.FamilyDual {
+		// 双栈由调用方拆分为两次追踪
+		family = netutil.FamilyAuto
+	}
+	opts.Family = family
+
+	return opts, nil
+}
+
+// Settings 返回实际生效的参数，用于在结果中回显
+func (o Options) Settings() map[string]interface{} {
+	return map[string]interface{}{
+		"protocol":  string(o.Protocol),
+		"port":      o.Port,
+		"max_hops":  o.MaxHops,
+		"queries":   o.Queries,
+		"wait_ms":   o.Wait.Milliseconds(),
+		"first_ttl": o.FirstTTL,
+		"parallel":  o.Parallel,
+		"paris":     o.Paris,
+		"multipath": o.Multipath,
+		"flows":     o.Flows,
+	}
+}
+
+// ExecArgs 返回等价的 traceroute 命令参数（不含目标），用于回退到 traceroute 命令
+func (o Options) ExecArgs() []string {
+	args := []string{
+		"-n",
+		"-m", strconv.Itoa(o.MaxHops),
+		"-q", strconv.Itoa(o.Queries),
+		"-w", strconv.FormatFloat(o.Wait.Seconds(), 'f', -1, 64),
+		"-f", strconv.Itoa(o.FirstTTL),
+		"-N", strconv.Itoa(o.Parallel),
+	}
+	switch o.Protocol {
+	case ProtocolUDP:
+		args = append(args, "-p", strconv.Itoa(o.Port))
+	case ProtocolICMP:
+		args = append(args, "-I")
+	case ProtocolTCP:
+		args = append(args, "-T", "-p", strconv.Itoa(o.Port))
+	}
+	if flag := o.Family.Flag(); flag != "" {
+		args = append(args, flag)
+	}
+	return args
+}
+
+func clamp(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
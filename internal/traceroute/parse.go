@@ -0,0 +1,68 @@
+package traceroute
+
+import (
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	headerRe = regexp.MustCompile(`^traceroute6? to (\S+) \(([0-9a-fA-F.:]+)\)`)
+	hopRe    = regexp.MustCompile(`^\s*(\d+)\s+(.*)$`)
+)
+
+// ParseOutput 解析 traceroute 命令（-n）的文本输出，例如：
+//
+//	traceroute to example.com (93.184.216.34), 30 hops max, 60 byte packets
+//	 1  192.168.1.1  0.345 ms  0.300 ms  0.290 ms
+//	 2  * * *
+//	 3  10.0.0.1  1.234 ms 10.0.0.2  1.512 ms !H  *
+func ParseOutput(output string) *Result {
+	result := &Result{}
+	for _, line := range strings.Split(output, "\n") {
+		if m := headerRe.FindStringSubmatch(line); m != nil {
+			result.Target = m[1]
+			result.IP = m[2]
+			continue
+		}
+		m := hopRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		ttl, _ := strconv.Atoi(m[1])
+		result.Hops = append(result.Hops, Hop{TTL: ttl, Probes: parseProbes(strings.Fields(m[2]))})
+	}
+	result.checkReached()
+	return result
+}
+
+// parseProbes 解析一跳中的探测结果，回复来源在RTT之前出现并对后续探测有效
+func parseProbes(fields []string) []Probe {
+	probes := make([]Probe, 0, 3)
+	currentIP := ""
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+		switch {
+		case field == "*":
+			probes = append(probes, Probe{Timeout: true})
+		case strings.HasPrefix(field, "!"):
+			if len(probes) > 0 {
+				probes[len(probes)-1].Annotation = field
+			}
+		case net.ParseIP(strings.Trim(field, "()")) != nil:
+			currentIP = strings.Trim(field, "()")
+		default:
+			rtt, err := strconv.ParseFloat(field, 64)
+			if err != nil {
+				// 不使用 -n 时的主机名等其他字段
+				continue
+			}
+			if i+1 < len(fields) && fields[i+1] == "ms" {
+				i++
+			}
+			probes = append(probes, Probe{IP: currentIP, RTT: rtt})
+		}
+	}
+	return probes
+}
@@ -0,0 +1,111 @@
+package traceroute
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseOutput(t *testing.T) {
+	output := `traceroute to example.com (93.184.216.34), 30 hops max, 60 byte packets
+ 1  192.168.1.1  0.345 ms  0.300 ms  0.290 ms
+ 2  * * *
+ 3  10.0.0.1  1.234 ms 10.0.0.2  1.512 ms !H  *
+ 4  93.184.216.34  10.100 ms  9.900 ms  10.000 ms
+`
+	got := ParseOutput(output)
+	want := &Result{
+		Target:  "example.com",
+		IP:      "93.184.216.34",
+		Reached: true,
+		Hops: []Hop{
+			{TTL: 1, Probes: []Probe{{IP: "192.168.1.1", RTT: 0.345}, {IP: "192.168.1.1", RTT: 0.3}, {IP: "192.168.1.1", RTT: 0.29}}},
+			{TTL: 2, Probes: []Probe{{Timeout: true}, {Timeout: true}, {Timeout: true}}},
+			{TTL: 3, Probes: []Probe{{IP: "10.0.0.1", RTT: 1.234}, {IP: "10.0.0.2", RTT: 1.512, Annotation: "!H"}, {Timeout: true}}},
+			{TTL: 4, Probes: []Probe{{IP: "93.184.216.34", RTT: 10.1}, {IP: "93.184.216.34", RTT: 9.9}, {IP: "93.184.216.34", RTT: 10}}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseOutput =\n%+v\nwant\n%+v", got, want)
+	}
+	if ips := got.Hops[2].IPs(); !reflect.DeepEqual(ips, []string{"10.0.0.1", "10.0.0.2"}) {
+		t.Errorf("IPs = %v", ips)
+	}
+}
+
+func TestParseOutputCases(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		target  string
+		ip      string
+		reached bool
+		hops    []Hop
+	}{
+		{
+			name:   "empty",
+			output: "",
+			hops:   nil,
+		},
+		{
+			name:   "IPv6",
+			output: "traceroute6 to ipv6.example (2001:db8::10), 30 hops max, 80 byte packets\n 1  2001:db8::1  0.5 ms  0.4 ms  0.6 ms\n 2  2001:db8::10  2.0 ms * 2.1 ms\n",
+			target: "ipv6.example", ip: "2001:db8::10", reached: true,
+			hops: []Hop{
+				{TTL: 1, Probes: []Probe{{IP: "2001:db8::1", RTT: 0.5}, {IP: "2001:db8::1", RTT: 0.4}, {IP: "2001:db8::1", RTT: 0.6}}},
+				{TTL: 2, Probes: []Probe{{IP: "2001:db8::10", RTT: 2}, {Timeout: true}, {IP: "2001:db8::10", RTT: 2.1}}},
+			},
+		},
+		{
+			name:   "hostnames without -n",
+			output: "traceroute to 192.0.2.9 (192.0.2.9), 30 hops max, 60 byte packets\n 1  router.lan (192.168.1.1)  0.3 ms  0.2 ms  0.2 ms\n",
+			target: "192.0.2.9", ip: "192.0.2.9", reached: false,
+			hops: []Hop{
+				{TTL: 1, Probes: []Probe{{IP: "192.168.1.1", RTT: 0.3}, {IP: "192.168.1.1", RTT: 0.2}, {IP: "192.168.1.1", RTT: 0.2}}},
+			},
+		},
+		{
+			// 没有探测结果的跳（输出被截断等），mtr 统计需要能处理
+			name:   "hop without probes",
+			output: "traceroute to 192.0.2.9 (192.0.2.9), 30 hops max, 60 byte packets\n 1  \n 2  192.0.2.9  1.0 ms\n",
+			target: "192.0.2.9", ip: "192.0.2.9", reached: true,
+			hops: []Hop{
+				{TTL: 1, Probes: []Probe{}},
+				{TTL: 2, Probes: []Probe{{IP: "192.0.2.9", RTT: 1}}},
+			},
+		},
+		{
+			name:   "annotation without probe and unknown code",
+			output: " 1  !X 192.0.2.1  5.0 ms !<10>\n",
+			hops: []Hop{
+				{TTL: 1, Probes: []Probe{{IP: "192.0.2.1", RTT: 5, Annotation: "!<10>"}}},
+			},
+		},
+		{
+			name:   "noise lines ignored",
+			output: "traceroute: Warning: multiple interfaces found\nsome text\n 1  * * *\n",
+			hops: []Hop{
+				{TTL: 1, Probes: []Probe{{Timeout: true}, {Timeout: true}, {Timeout: true}}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseOutput(tt.output)
+			if got.Target != tt.target || got.IP != tt.ip || got.Reached != tt.reached {
+				t.Errorf("target=%q ip=%q reached=%v", got.Target, got.IP, got.Reached)
+			}
+			if !reflect.DeepEqual(got.Hops, tt.hops) {
+				t.Errorf("hops =\n%+v\nwant\n%+v", got.Hops, tt.hops)
+			}
+		})
+	}
+}
+
+func TestAnnotationText(t *testing.T) {
+	if got := AnnotationText("!H"); got != "主机不可达" {
+		t.Errorf("AnnotationText(!H) = %q", got)
+	}
+	if got := AnnotationText("!<10>"); got != "ICMP不可达 !<10>" {
+		t.Errorf("AnnotationText(!<10>) = %q", got)
+	}
+}
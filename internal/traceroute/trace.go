@@ -0,0 +1,659 @@
+package traceroute
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"linkmaster-node/internal/stats"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// ErrUnavailable 无法创建原始套接字（需要root或CAP_NET_RAW），调用方应回退到 traceroute 命令
+var ErrUnavailable = errors.New("无法创建原始ICMP套接字")
+
+const (
+	protocolICMP     = 1
+	protocolTCP      = 6
+	protocolUDP      = 17
+	protocolIPv6ICMP = 58
+
+	payloadSize = 32 // UDP和ICMP探测的负载字节数，与 traceroute 默认的60字节包一致
+)
+
+// Run 追踪到 host 的路径，需要原始ICMP套接字接收超时和不可达报文
+func Run(ctx context.Context, host string, opts Options) (*Result, error) {
+	ip, err := opts.Family.LookupPrimaryIP(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	return trace(ctx, host, ip, opts)
+}
+
+// trace 对已解析的目标IP执行一次追踪
+func trace(ctx context.Context, host string, ip net.IP, opts Options) (*Result, error) {
+	t, err := newTracer(ip, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer t.close()
+
+	result := &Result{
+		Target:     host,
+		IP:         ip.String(),
+		MaxHops:    opts.MaxHops,
+		PacketSize: t.packetSize(),
+	}
+	result.Hops = t.run(ctx)
+	result.checkReached()
+	return result, nil
+}
+
+// tracer 一次路由追踪的套接字和探测状态
+type tracer struct {
+	opts Options
+	dst  net.IP
+	v6   bool
+
+	icmpConn *icmp.PacketConn // 接收ICMP超时/不可达报文，ICMP模式下也用于发送
+	udpConn  net.PacketConn   // UDP模式的发送套接字
+	tcpConn  net.PacketConn   // TCP模式的原始套接字，发送SYN并接收SYN-ACK/RST
+	tcpLn    net.Listener     // TCP模式下占用源端口，避免与本机已有连接冲突
+	src      net.IP           // 本机源地址，用于TCP和Paris模式UDP的校验和
+	id       int              // ICMP echo标识，TCP模式下为源端口
+	udpPort  int              // UDP发送套接字的本地端口
+	seqBase  uint32           // TCP序号基数
+	flowSum  uint16           // Paris模式下ICMP报文保持不变的校验和
+
+	mu     sync.Mutex
+	probes []*probeState
+	keys   map[uint32]int // 探测标识 -> 探测序号
+	wake   chan struct{}
+}
+
+// probeState 单个探测的状态
+type probeState struct {
+	ttl    int
+	sent   bool
+	sentAt time.Time
+	done   bool
+	final  bool // 收到目标回复或不可达报文，不再需要更大的TTL
+	result Probe
+}
+
+func newTracer(dst net.IP, opts Options) (*tracer, error) {
+	t := &tracer{
+		opts:    opts,
+		dst:     dst,
+		v6:      dst.To4() == nil,
+		id:      33000 + rand.Intn(30000),
+		seqBase: rand.Uint32(),
+		flowSum: uint16(rand.Intn(0xffff)),
+		keys:    make(map[uint32]int),
+		wake:    make(chan struct{}, 1),
+	}
+
+	icmpNetwork, laddr := "ip4:icmp", "0.0.0.0"
+	if t.v6 {
+		icmpNetwork, laddr = "ip6:ipv6-icmp", "::"
+	}
+	conn, err := icmp.ListenPacket(icmpNetwork, laddr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	t.icmpConn = conn
+
+	switch opts.Protocol {
+	case ProtocolUDP:
+		network := "udp4"
+		if t.v6 {
+			network = "udp6"
+		}
+		udpConn, err := net.ListenPacket(network, net.JoinHostPort(laddr, "0"))
+		if err != nil {
+			t.close()
+			return nil, err
+		}
+		t.udpConn = udpConn
+		t.udpPort = udpConn.LocalAddr().(*net.UDPAddr).Port
+		if opts.Paris {
+			if t.src, err = sourceIP(dst); err != nil {
+				t.close()
+				return nil, err
+			}
+		}
+	case ProtocolTCP:
+		network := "ip4:tcp"
+		if t.v6 {
+			network = "ip6:tcp"
+		}
+		tcpConn, err := net.ListenPacket(network, laddr)
+		if err != nil {
+			t.close()
+			return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+		}
+		t.tcpConn = tcpConn
+		if t.src, err = sourceIP(dst); err != nil {
+			t.close()
+			return nil, err
+		}
+		// 原始套接字不占用端口，由内核分配并保留一个本地端口作为源端口
+		ln, err := net.Listen("tcp", net.JoinHostPort(t.src.String(), "0"))
+		if err != nil {
+			t.close()
+			return nil, err
+		}
+		t.tcpLn = ln
+		t.id = ln.Addr().(*net.TCPAddr).Port
+	}
+	return t, nil
+}
+
+// sourceIP 通过UDP“连接”获取路由选择的源地址（不会发送数据）
+func sourceIP(dst net.IP) (net.IP, error) {
+	conn, err := net.Dial("udp", net.JoinHostPort(dst.String(), "9"))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP, nil
+}
+
+func (t *tracer) close() {
+	t.icmpConn.Close()
+	if t.udpConn != nil {
+		t.udpConn.Close()
+	}
+	if t.tcpConn != nil {
+		t.tcpConn.Close()
+	}
+	if t.tcpLn != nil {
+		t.tcpLn.Close()
+	}
+}
+
+// packetSize 探测包的IP层总长度
+func (t *tracer) packetSize() int {
+	header := 20
+	if t.v6 {
+		header = 40
+	}
+	if t.opts.Protocol == ProtocolTCP {
+		return header + 20
+	}
+	return header + 8 + payloadSize
+}
+
+// run 按TTL顺序发送探测，最多 Parallel 个同时在途，收到目标回复后不再发送更大TTL的探测
+func (t *tracer) run(ctx context.Context) []Hop {
+	total := (t.opts.MaxHops - t.opts.FirstTTL + 1) * t.opts.Queries
+	t.probes = make([]*probeState, total)
+	for i := range t.probes {
+		t.probes[i] = &probeState{ttl: t.opts.FirstTTL + i/t.opts.Queries}
+	}
+
+	go t.readICMP()
+	if t.tcpConn != nil {
+		go t.readTCP()
+	}
+
+	next := 0
+	for {
+		t.mu.Lock()
+		now := time.Now()
+		inFlight := 0
+		stopTTL := 0
+		for _, p := range t.probes[:next] {
+			if !p.done {
+				if now.Sub(p.sentAt) > t.opts.Wait {
+					p.done = true
+					p.result = Probe{Timeout: true}
+				} else {
+					inFlight++
+				}
+			}
+			if p.done && p.final && (stopTTL == 0 || p.ttl < stopTTL) {
+				stopTTL = p.ttl
+			}
+		}
+		for inFlight < t.opts.Parallel && next < total && (stopTTL == 0 || t.probes[next].ttl <= stopTTL) {
+			p := t.probes[next]
+			t.keys[t.key(next)] = next
+			p.sent = true
+			p.sentAt = time.Now()
+			if err := t.send(next); err != nil {
+				p.done = true
+				p.result = Probe{Timeout: true}
+			} else {
+				inFlight++
+			}
+			next++
+		}
+		finished := inFlight == 0 && (next == total || (stopTTL != 0 && t.probes[next].ttl > stopTTL))
+		t.mu.Unlock()
+
+		if finished || ctx.Err() != nil {
+			break
+		}
+		select {
+		case <-ctx.Done():
+		case <-t.wake:
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.hops()
+}
+
+// hops 将探测结果按TTL分组，截止到目标所在的一跳；调用方需持有 mu
+func (t *tracer) hops() []Hop {
+	lastTTL := 0
+	for _, p := range t.probes {
+		if !p.sent {
+			break
+		}
+		if p.final && p.done {
+			lastTTL = p.ttl
+			break
+		}
+		lastTTL = p.ttl
+	}
+
+	hops := make([]Hop, 0, lastTTL)
+	for _, p := range t.probes {
+		if !p.sent || p.ttl > lastTTL {
+			break
+		}
+		if len(hops) == 0 || hops[len(hops)-1].TTL != p.ttl {
+			hops = append(hops, Hop{TTL: p.ttl})
+		}
+		result := p.result
+		if !p.done {
+			// 被取消时仍在等待的探测
+			result = Probe{Timeout: true}
+		}
+		hops[len(hops)-1].Probes = append(hops[len(hops)-1].Probes, result)
+	}
+	return hops
+}
+
+// key 探测的标识：ICMP为echo序号，UDP为目的端口（Paris模式为校验和），TCP为相对序号
+func (t *tracer) key(n int) uint32 {
+	if t.opts.Protocol == ProtocolUDP {
+		if t.opts.Paris {
+			return uint32(n + 1)
+		}
+		return uint32(t.dstPort(n))
+	}
+	return uint32(n)
+}
+
+// dstPort UDP模式下第 n 个探测的目的端口，Paris模式固定不变，否则递增并在超过65535时回绕到1024以上
+func (t *tracer) dstPort(n int) int {
+	if t.opts.Paris {
+		return t.opts.Port
+	}
+	port := t.opts.Port + n
+	if port > 65535 {
+		port = 1024 + (port-65536)%(65536-1024)
+	}
+	return port
+}
+
+// setTTL 设置发送套接字的TTL/HopLimit
+func (t *tracer) setTTL(ttl int) error {
+	var conn net.PacketConn
+	switch t.opts.Protocol {
+	case ProtocolICMP:
+		if t.v6 {
+			return t.icmpConn.IPv6PacketConn().SetHopLimit(ttl)
+		}
+		return t.icmpConn.IPv4PacketConn().SetTTL(ttl)
+	case ProtocolUDP:
+		conn = t.udpConn
+	case ProtocolTCP:
+		conn = t.tcpConn
+	}
+	if t.v6 {
+		return ipv6.NewPacketConn(conn).SetHopLimit(ttl)
+	}
+	return ipv4.NewPacketConn(conn).SetTTL(ttl)
+}
+
+// send 发送第 n 个探测；调用方需持有 mu
+func (t *tracer) send(n int) error {
+	if err := t.setTTL(t.probes[n].ttl); err != nil {
+		return err
+	}
+	payload := make([]byte, payloadSize)
+	for i := range payload {
+		payload[i] = byte(0x40 + i)
+	}
+
+	switch t.opts.Protocol {
+	case ProtocolICMP:
+		var typ icmp.Type = ipv4.ICMPTypeEcho
+		if t.v6 {
+			typ = ipv6.ICMPTypeEchoRequest
+		}
+		if t.opts.Paris {
+			// 用负载前两个字节抵消序号的变化，使校验和（负载均衡计算流标识的字段）保持不变
+			binary.BigEndian.PutUint16(payload, onesAdd(t.flowSum, ^uint16(n)))
+		}
+		msg := icmp.Message{Type: typ, Body: &icmp.Echo{ID: t.id, Seq: n, Data: payload}}
+		b, err := msg.Marshal(nil)
+		if err != nil {
+			return err
+		}
+		_, err = t.icmpConn.WriteTo(b, &net.IPAddr{IP: t.dst})
+		return err
+	case ProtocolUDP:
+		if t.opts.Paris {
+			t.setUDPChecksum(payload, uint16(t.key(n)))
+		}
+		_, err := t.udpConn.WriteTo(payload, &net.UDPAddr{IP: t.dst, Port: t.dstPort(n)})
+		return err
+	default:
+		_, err := t.tcpConn.WriteTo(t.tcpSYN(n), &net.IPAddr{IP: t.dst})
+		return err
+	}
+}
+
+// tcpSYN 构造第 n 个探测的TCP SYN报文，序号携带探测序号
+func (t *tracer) tcpSYN(n int) []byte {
+	b := make([]byte, 20)
+	binary.BigEndian.PutUint16(b[0:], uint16(t.id))
+	binary.BigEndian.PutUint16(b[2:], uint16(t.opts.Port))
+	binary.BigEndian.PutUint32(b[4:], t.seqBase+uint32(n))
+	b[12] = 5 << 4 // 数据偏移：20字节
+	b[13] = 0x02   // SYN
+	binary.BigEndian.PutUint16(b[14:], 65535)
+	binary.BigEndian.PutUint16(b[16:], checksum(pseudoHeader(t.src, t.dst, protocolTCP, len(b)), b))
+	return b
+}
+
+// setUDPChecksum 调整负载前两个字节，使内核计算出的UDP校验和等于 sum，
+// Paris模式下端口固定，用校验和区分探测
+func (t *tracer) setUDPChecksum(payload []byte, sum uint16) {
+	length := 8 + len(payload)
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint16(header[0:], uint16(t.udpPort))
+	binary.BigEndian.PutUint16(header[2:], uint16(t.opts.Port))
+	binary.BigEndian.PutUint16(header[4:], uint16(length))
+	payload[0], payload[1] = 0, 0
+	// 其余部分的反码和为 S，需要 S + x = ^sum
+	partial := ^checksum(pseudoHeader(t.src, t.dst, protocolUDP, length), header, payload)
+	binary.BigEndian.PutUint16(payload, onesAdd(^sum, ^partial))
+}
+
+// readICMP 接收ICMP报文直到套接字关闭
+func (t *tracer) readICMP() {
+	proto := protocolICMP
+	if t.v6 {
+		proto = protocolIPv6ICMP
+	}
+	buf := make([]byte, 1500)
+	for {
+		n, peer, err := t.icmpConn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		at := time.Now()
+		msg, err := icmp.ParseMessage(proto, buf[:n])
+		if err != nil {
+			continue
+		}
+		t.handleICMP(msg, addrIP(peer), at)
+	}
+}
+
+func (t *tracer) handleICMP(msg *icmp.Message, from net.IP, at time.Time) {
+	switch msg.Type {
+	case ipv4.ICMPTypeEchoReply, ipv6.ICMPTypeEchoReply:
+		echo, ok := msg.Body.(*icmp.Echo)
+		if t.opts.Protocol != ProtocolICMP || !ok || echo.ID != t.id || !from.Equal(t.dst) {
+			return
+		}
+		t.complete(uint32(echo.Seq), from, "", true, at)
+	case ipv4.ICMPTypeTimeExceeded, ipv6.ICMPTypeTimeExceeded:
+		body, ok := msg.Body.(*icmp.TimeExceeded)
+		if !ok {
+			return
+		}
+		if key, ok := t.matchQuoted(body.Data); ok {
+			t.complete(key, from, "", false, at)
+		}
+	case ipv4.ICMPTypeDestinationUnreachable, ipv6.ICMPTypeDestinationUnreachable:
+		body, ok := msg.Body.(*icmp.DstUnreach)
+		if !ok {
+			return
+		}
+		if key, ok := t.matchQuoted(body.Data); ok {
+			t.complete(key, from, unreachableAnnotation(t.v6, msg.Code), true, at)
+		}
+	}
+}
+
+// matchQuoted 从ICMP差错报文引用的原始数据包中取出探测标识
+func (t *tracer) matchQuoted(data []byte) (uint32, bool) {
+	var proto int
+	var quotedDst net.IP
+	var payload []byte
+	if t.v6 {
+		if len(data) < 48 || data[0]>>4 != 6 {
+			return 0, false
+		}
+		proto = int(data[6])
+		quotedDst = net.IP(data[24:40])
+		payload = data[40:]
+	} else {
+		if len(data) < 20 || data[0]>>4 != 4 {
+			return 0, false
+		}
+		ihl := int(data[0]&0x0f) * 4
+		if len(data) < ihl+8 {
+			return 0, false
+		}
+		proto = int(data[9])
+		quotedDst = net.IP(data[16:20])
+		payload = data[ihl:]
+	}
+	if !quotedDst.Equal(t.dst) || len(payload) < 8 {
+		return 0, false
+	}
+
+	srcPort := int(binary.BigEndian.Uint16(payload[0:2]))
+	dstPort := int(binary.BigEndian.Uint16(payload[2:4]))
+	switch t.opts.Protocol {
+	case ProtocolICMP:
+		echoType := byte(ipv4.ICMPTypeEcho)
+		if t.v6 {
+			echoType = byte(ipv6.ICMPTypeEchoRequest)
+		}
+		if (proto != protocolICMP && proto != protocolIPv6ICMP) || payload[0] != echoType || int(binary.BigEndian.Uint16(payload[4:6])) != t.id {
+			return 0, false
+		}
+		return uint32(binary.BigEndian.Uint16(payload[6:8])), true
+	case ProtocolUDP:
+		if proto != protocolUDP || srcPort != t.udpPort {
+			return 0, false
+		}
+		if t.opts.Paris {
+			if dstPort != t.opts.Port {
+				return 0, false
+			}
+			return uint32(binary.BigEndian.Uint16(payload[6:8])), true
+		}
+		return uint32(dstPort), true
+	default:
+		if proto != protocolTCP || srcPort != t.id || dstPort != t.opts.Port {
+			return 0, false
+		}
+		return binary.BigEndian.Uint32(payload[4:8]) - t.seqBase, true
+	}
+}
+
+// readTCP 接收目标回复的SYN-ACK或RST，直到套接字关闭
+func (t *tracer) readTCP() {
+	buf := make([]byte, 1500)
+	for {
+		n, peer, err := t.tcpConn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		at := time.Now()
+		from := addrIP(peer)
+		if n < 20 || !from.Equal(t.dst) {
+			continue
+		}
+		srcPort := int(binary.BigEndian.Uint16(buf[0:2]))
+		dstPort := int(binary.BigEndian.Uint16(buf[2:4]))
+		flags := buf[13]
+		if srcPort != t.opts.Port || dstPort != t.id || (flags&0x04 == 0 && flags&0x12 != 0x12) {
+			continue
+		}
+		ack := binary.BigEndian.Uint32(buf[8:12])
+		t.complete(ack-1-t.seqBase, from, "", true, at)
+	}
+}
+
+// complete 记录探测结果
+func (t *tracer) complete(key uint32, from net.IP, annotation string, final bool, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n, ok := t.keys[key]
+	if !ok {
+		return
+	}
+	p := t.probes[n]
+	if !p.sent || p.done {
+		return
+	}
+	p.done = true
+	p.final = final
+	p.result = Probe{IP: from.String(), RTT: stats.Round(stats.Milliseconds(at.Sub(p.sentAt))), Annotation: annotation}
+	select {
+	case t.wake <- struct{}{}:
+	default:
+	}
+}
+
+// unreachableAnnotation 将ICMP不可达代码转换为 traceroute 的标注，端口不可达表示到达目标，没有标注
+func unreachableAnnotation(v6 bool, code int) string {
+	if v6 {
+		switch code {
+		case 0:
+			return "!N"
+		case 1, 5, 6:
+			return "!X"
+		case 2:
+			return "!S"
+		case 3:
+			return "!H"
+		case 4:
+			return ""
+		}
+		return fmt.Sprintf("!<%d>", code)
+	}
+	switch code {
+	case 0:
+		return "!N"
+	case 1:
+		return "!H"
+	case 2:
+		return "!P"
+	case 3:
+		return ""
+	case 4:
+		return "!F"
+	case 5:
+		return "!S"
+	case 6:
+		return "!U"
+	case 7:
+		return "!W"
+	case 8:
+		return "!I"
+	case 9:
+		return "!A"
+	case 10:
+		return "!Z"
+	case 11, 12:
+		return "!T"
+	case 13:
+		return "!X"
+	case 14:
+		return "!V"
+	case 15:
+		return "!C"
+	}
+	return fmt.Sprintf("!<%d>", code)
+}
+
+func addrIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.IPAddr:
+		return a.IP
+	case *net.UDPAddr:
+		return a.IP
+	}
+	return nil
+}
+
+// pseudoHeader 计算TCP/UDP校验和使用的伪首部
+func pseudoHeader(src, dst net.IP, proto, length int) []byte {
+	if src4, dst4 := src.To4(), dst.To4(); src4 != nil && dst4 != nil {
+		b := make([]byte, 12)
+		copy(b[0:4], src4)
+		copy(b[4:8], dst4)
+		b[9] = byte(proto)
+		binary.BigEndian.PutUint16(b[10:], uint16(length))
+		return b
+	}
+	b := make([]byte, 40)
+	copy(b[0:16], src.To16())
+	copy(b[16:32], dst.To16())
+	binary.BigEndian.PutUint32(b[32:], uint32(length))
+	b[39] = byte(proto)
+	return b
+}
+
+// onesAdd 16位反码加法
+func onesAdd(a, b uint16) uint16 {
+	sum := uint32(a) + uint32(b)
+	return uint16(sum&0xffff + sum>>16)
+}
+
+// checksum 互联网校验和（RFC 1071）
+func checksum(parts ...[]byte) uint16 {
+	var sum uint32
+	var odd bool
+	var last byte
+	for _, part := range parts {
+		for _, c := range part {
+			if odd {
+				sum += uint32(last)<<8 | uint32(c)
+			} else {
+				last = c
+			}
+			odd = !odd
+		}
+	}
+	if odd {
+		sum += uint32(last) << 8
+	}
+	for sum>>16 != 0 {
+		sum = sum&0xffff + sum>>16
+	}
+	return ^uint16(sum)
+}
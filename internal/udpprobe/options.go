@@ -0,0 +1,190 @@
+package udpprobe
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"linkmaster-node/internal/dnsprobe"
+	"linkmaster-node/internal/netutil"
+)
+
+// 参数的安全范围，超出范围的值会被收敛到边界
+const (
+	MinCount       = 1
+	MaxCount       = 100
+	MinInterval    = 100 * time.Millisecond
+	MaxInterval    = 10 * time.Second
+	MinTimeout     = 100 * time.Millisecond
+	MaxTimeout     = 10 * time.Second
+	MaxPayloadSize = 8192
+	MaxRuntime     = 60 * time.Second // 一次测试（count × (timeout + interval)）的最长时间
+)
+
+// Options UDP探测的参数
+type Options struct {
+	Family    netutil.Family
+	Count     int
+	Interval  time.Duration
+	Timeout   time.Duration // 单次探测等待应答的时间
+	Template  string        // 内置负载模板，为空时使用 Payload
+	Payload   []byte        // 自定义负载
+	DNSName   string        // dns 模板查询的域名
+	Community string        // snmp 模板的团体名
+}
+
+// DefaultOptions 返回默认参数
+func DefaultOptions() Options {
+	return Options{
+		Family:    netutil.FamilyAuto,
+		Count:     10,
+		Interval:  500 * time.Millisecond,
+		Timeout:   2 * time.Second,
+		DNSName:   ".",
+		Community: "public",
+	}
+}
+
+// ParseOptions 从测试请求的 params 中解析探测参数
+//
+// 支持的参数：template（dns/ntp/snmp/stun/quic）或 payload（配合 encoding：hex（默认）、base64、text），
+// count、interval_ms、timeout_ms、dns_name、community、ip_version（4/6/auto）
+func ParseOptions(params map[string]interface{}) (Options, error) {
+	opts := DefaultOptions()
+
+	if count, ok := params["count"].(float64); ok {
+		opts.Count = clampInt(int(count), MinCount, MaxCount)
+	}
+	if interval, ok := params["interval_ms"].(float64); ok {
+		opts.Interval = clampDuration(time.Duration(interval)*time.Millisecond, MinInterval, MaxInterval)
+	}
+	if timeout, ok := params["timeout_ms"].(float64); ok {
+		opts.Timeout = clampDuration(time.Duration(timeout)*time.Millisecond, MinTimeout, MaxTimeout)
+	}
+	// 限制总时间，避免单次请求占用过久
+	if time.Duration(opts.Count)*(opts.Timeout+opts.Interval) > MaxRuntime {
+		opts.Count = max(int(MaxRuntime/(opts.Timeout+opts.Interval)), MinCount)
+	}
+
+	if name, ok := params["dns_name"].(string); ok && strings.TrimSpace(name) != "" {
+		opts.DNSName = strings.TrimSpace(name)
+	}
+	if community, ok := params["community"].(string); ok && community != "" {
+		opts.Community = community
+	}
+
+	template, _ := params["template"].(string)
+	template = strings.ToLower(strings.TrimSpace(template))
+	payload, hasPayload := params["payload"].(string)
+	switch {
+	case template != "" && hasPayload:
+		return opts, errors.New("template 和 payload 不能同时使用")
+	case template != "":
+		if _, ok := templates[template]; !ok {
+			return opts, fmt.Errorf("不支持的负载模板: %s", template)
+		}
+		opts.Template = template
+		if template == TemplateDNS {
+			if _, err := dnsprobe.NewQuery(dnsprobe.Fqdn(opts.DNSName), dnsprobe.TypeNS, dnsprobe.ClassIN, true).Pack(); err != nil {
+				return opts, err
+			}
+		}
+	case hasPayload:
+		data, err := decodePayload(payload, params["encoding"])
+		if err != nil {
+			return opts, err
+		}
+		if len(data) > MaxPayloadSize {
+			return opts, fmt.Errorf("负载超过 %d 字节", MaxPayloadSize)
+		}
+		opts.Payload = data
+	default:
+		return opts, errors.New("需要指定 template 或 payload")
+	}
+
+	family, err := netutil.ParseFamily(params)
+	if err != nil {
+		return opts, err
+	}
+	if family == netutil.FamilyDual {
+		// 双栈由调用方拆分为两次测试
+		family = netutil.FamilyAuto
+	}
+	opts.Family = family
+	return opts, nil
+}
+
+// decodePayload 按 encoding 解码自定义负载
+func decodePayload(payload string, encoding interface{}) ([]byte, error) {
+	enc, _ := encoding.(string)
+	switch strings.ToLower(enc) {
+	case "", "hex":
+		// 允许空格和冒号分隔，如 "de ad be ef"、"de:ad:be:ef"
+		clean := strings.NewReplacer(" ", "", ":", "", "\n", "", "\t", "").Replace(payload)
+		clean = strings.TrimPrefix(strings.TrimPrefix(clean, "0x"), "0X")
+		data, err := hex.DecodeString(clean)
+		if err != nil {
+			return nil, fmt.Errorf("负载不是有效的十六进制: %v", err)
+		}
+		return data, nil
+	case "base64":
+		data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(payload))
+		if err != nil {
+			return nil, fmt.Errorf("负载不是有效的base64: %v", err)
+		}
+		return data, nil
+	case "text":
+		return []byte(payload), nil
+	}
+	return nil, fmt.Errorf("不支持的负载编码: %s", enc)
+}
+
+// DefaultPort 返回负载模板对应服务的默认端口，自定义负载时为0
+func (o Options) DefaultPort() int {
+	if t, ok := templates[o.Template]; ok {
+		return t.port
+	}
+	return 0
+}
+
+// Settings 返回实际生效的参数，用于在结果中回显
+func (o Options) Settings() map[string]interface{} {
+	settings := map[string]interface{}{
+		"count":       o.Count,
+		"interval_ms": o.Interval.Milliseconds(),
+		"timeout_ms":  o.Timeout.Milliseconds(),
+		"template":    o.Template,
+	}
+	switch o.Template {
+	case "":
+		settings["payload_size"] = len(o.Payload)
+	case TemplateDNS:
+		settings["dns_name"] = o.DNSName
+	case TemplateSNMP:
+		settings["community"] = o.Community
+	}
+	return settings
+}
+
+func clampInt(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+func clampDuration(v, min, max time.Duration) time.Duration {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
@@ -0,0 +1,126 @@
+package udpprobe
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"linkmaster-node/internal/netutil"
+)
+
+func TestParseOptionsPayload(t *testing.T) {
+	tests := []struct {
+		name     string
+		payload  string
+		encoding interface{}
+		want     []byte
+	}{
+		{"hex default", "deadbeef", nil, []byte{0xde, 0xad, 0xbe, 0xef}},
+		{"hex spaces", "de ad be ef", "hex", []byte{0xde, 0xad, 0xbe, 0xef}},
+		{"hex colons", "de:ad:BE:EF", "HEX", []byte{0xde, 0xad, 0xbe, 0xef}},
+		{"hex prefix", "0x0102", nil, []byte{1, 2}},
+		{"base64", "aGVsbG8=", "base64", []byte("hello")},
+		{"base64 trailing newline", "aGVsbG8=\n", "base64", []byte("hello")},
+		{"text", "ping\n", "text", []byte("ping\n")},
+	}
+	for _, tt := range tests {
+		params := map[string]interface{}{"payload": tt.payload}
+		if tt.encoding != nil {
+			params["encoding"] = tt.encoding
+		}
+		opts, err := ParseOptions(params)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !bytes.Equal(opts.Payload, tt.want) || opts.Template != "" {
+			t.Errorf("%s: payload = %x, template = %q", tt.name, opts.Payload, opts.Template)
+		}
+	}
+}
+
+func TestParseOptionsErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		params map[string]interface{}
+	}{
+		{"template and payload", map[string]interface{}{"template": "dns", "payload": "00"}},
+		{"neither", map[string]interface{}{"count": float64(3)}},
+		{"unknown template", map[string]interface{}{"template": "ssdp"}},
+		{"invalid hex", map[string]interface{}{"payload": "xyz"}},
+		{"odd hex", map[string]interface{}{"payload": "abc"}},
+		{"invalid base64", map[string]interface{}{"payload": "not base64!", "encoding": "base64"}},
+		{"unknown encoding", map[string]interface{}{"payload": "00", "encoding": "binary"}},
+		{"payload too large", map[string]interface{}{"payload": strings.Repeat("x", MaxPayloadSize+1), "encoding": "text"}},
+		{"invalid dns name", map[string]interface{}{"template": "dns", "dns_name": strings.Repeat("a", 64) + ".com"}},
+		{"invalid ip_version", map[string]interface{}{"template": "ntp", "ip_version": "5"}},
+	}
+	for _, tt := range tests {
+		if _, err := ParseOptions(tt.params); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
+
+func TestParseOptionsClamp(t *testing.T) {
+	tests := []struct {
+		name     string
+		params   map[string]interface{}
+		count    int
+		interval time.Duration
+		timeout  time.Duration
+	}{
+		{"defaults", map[string]interface{}{}, 10, 500 * time.Millisecond, 2 * time.Second},
+		{"lower bounds", map[string]interface{}{"count": float64(0), "interval_ms": float64(1), "timeout_ms": float64(1)}, MinCount, MinInterval, MinTimeout},
+		{"upper bounds", map[string]interface{}{"count": float64(1000), "interval_ms": float64(60000), "timeout_ms": float64(60000)}, 3, MaxInterval, MaxTimeout},
+		// count × (timeout + interval) 不超过 MaxRuntime
+		{"runtime", map[string]interface{}{"count": float64(100)}, 24, 500 * time.Millisecond, 2 * time.Second},
+		{"within runtime", map[string]interface{}{"count": float64(100), "interval_ms": float64(100), "timeout_ms": float64(200)}, 100, 100 * time.Millisecond, 200 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		tt.params["template"] = "ntp"
+		opts, err := ParseOptions(tt.params)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if opts.Count != tt.count || opts.Interval != tt.interval || opts.Timeout != tt.timeout {
+			t.Errorf("%s: count/interval/timeout = %d/%v/%v, want %d/%v/%v",
+				tt.name, opts.Count, opts.Interval, opts.Timeout, tt.count, tt.interval, tt.timeout)
+		}
+	}
+}
+
+func TestParseOptionsTemplate(t *testing.T) {
+	opts, err := ParseOptions(map[string]interface{}{
+		"template":   " DNS ",
+		"dns_name":   " example.com ",
+		"community":  "private",
+		"ip_version": "dual",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Template != TemplateDNS || opts.DNSName != "example.com" || opts.Community != "private" {
+		t.Errorf("opts = %+v", opts)
+	}
+	// 双栈由调用方拆分，单次探测按 auto 处理
+	if opts.Family != netutil.FamilyAuto {
+		t.Errorf("family = %v, want auto", opts.Family)
+	}
+	if opts.DefaultPort() != 53 {
+		t.Errorf("DefaultPort = %d, want 53", opts.DefaultPort())
+	}
+	if s := opts.Settings(); s["dns_name"] != "example.com" || s["template"] != TemplateDNS {
+		t.Errorf("settings = %v", s)
+	}
+
+	opts, err = ParseOptions(map[string]interface{}{"payload": "00", "ip_version": float64(6)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Family != netutil.FamilyIPv6 || opts.DefaultPort() != 0 || opts.Settings()["payload_size"] != 1 {
+		t.Errorf("opts = %+v", opts)
+	}
+}
@@ -0,0 +1,115 @@
+// Package udpprobe 向 host:port 发送UDP负载并等待应答，通过已连接的UDP套接字感知ICMP端口不可达
+package udpprobe
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"syscall"
+	"time"
+)
+
+// 单次探测的结果
+const (
+	StatusReply           = "reply"            // 收到应答
+	StatusTimeout         = "timeout"          // 超时未收到应答（端口可能开放但未响应，或被过滤）
+	StatusPortUnreachable = "port_unreachable" // 收到ICMP端口不可达，端口关闭
+	StatusUnreachable     = "unreachable"      // 收到ICMP主机或网络不可达
+	StatusError           = "error"            // 其他错误
+)
+
+// maxReplySize 读取应答的缓冲区大小
+const maxReplySize = 65535
+
+// Attempt 一次探测
+type Attempt struct {
+	Seq    int
+	Status string
+	RTT    time.Duration // 收到应答或ICMP错误的时间
+	Size   int           // 应答字节数
+	Reply  []byte
+	Info   map[string]interface{} // 按模板解析的应答内容
+	Err    error
+}
+
+// Run 向 address（ip:port）依次发送 opts.Count 次探测；每次使用新的套接字，避免迟到的应答被计入下一次
+func Run(ctx context.Context, address string, opts Options) []Attempt {
+	attempts := make([]Attempt, 0, opts.Count)
+	for i := 0; i < opts.Count; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return attempts
+			case <-time.After(opts.Interval):
+			}
+		}
+		attempt := probe(ctx, address, opts)
+		attempt.Seq = i + 1
+		attempts = append(attempts, attempt)
+	}
+	return attempts
+}
+
+// newPayload 返回一次探测发送的负载，模板负载每次重新生成（新的事务ID、时间戳等）
+func (o Options) newPayload() []byte {
+	if t, ok := templates[o.Template]; ok {
+		return t.build(o)
+	}
+	return o.Payload
+}
+
+func probe(ctx context.Context, address string, opts Options) Attempt {
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, opts.Family.Network("udp"), address)
+	if err != nil {
+		return Attempt{Status: StatusError, Err: err}
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Unix(1, 0)) })
+	defer stop()
+
+	payload := opts.newPayload()
+	tmpl, hasTemplate := templates[opts.Template]
+	sent := time.Now()
+	if _, err := conn.Write(payload); err != nil {
+		return classify(err, time.Since(sent))
+	}
+	buf := make([]byte, maxReplySize)
+	for {
+		n, err := conn.Read(buf)
+		received := time.Now()
+		if err != nil {
+			return classify(err, received.Sub(sent))
+		}
+		reply := buf[:n]
+		// 忽略与本次请求不对应的报文（如迟到的旧应答）
+		if hasTemplate && tmpl.match != nil && !tmpl.match(payload, reply) {
+			continue
+		}
+		attempt := Attempt{Status: StatusReply, RTT: received.Sub(sent), Size: n, Reply: append([]byte(nil), reply...)}
+		if hasTemplate {
+			attempt.Info, attempt.Err = tmpl.decode(payload, reply, sent, received)
+		}
+		return attempt
+	}
+}
+
+// classify 根据读写错误判断探测结果；已连接的UDP套接字会把收到的ICMP错误作为读写错误返回
+func classify(err error, elapsed time.Duration) Attempt {
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return Attempt{Status: StatusPortUnreachable, RTT: elapsed, Err: errors.New("端口不可达")}
+	case errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH):
+		return Attempt{Status: StatusUnreachable, RTT: elapsed, Err: err}
+	case errors.Is(err, os.ErrDeadlineExceeded):
+		return Attempt{Status: StatusTimeout, Err: errors.New("等待应答超时")}
+	}
+	return Attempt{Status: StatusError, Err: err}
+}
@@ -0,0 +1,383 @@
+package udpprobe
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"linkmaster-node/internal/dnsprobe"
+)
+
+// 内置的负载模板
+const (
+	TemplateDNS  = "dns"  // 递归查询 dns_name 的NS记录
+	TemplateNTP  = "ntp"  // NTPv4 客户端请求，RFC 5905
+	TemplateSNMP = "snmp" // SNMPv2c GetRequest sysDescr.0
+	TemplateSTUN = "stun" // STUN Binding 请求，RFC 8489
+	TemplateQUIC = "quic" // 使用保留版本号的QUIC Initial，服务器应回复版本协商包，RFC 9000 第6节
+)
+
+// template 一种负载模板：构造每次探测的负载、判断应答是否对应本次请求、解析应答
+type template struct {
+	port   int
+	build  func(opts Options) []byte
+	match  func(payload, reply []byte) bool
+	decode func(payload, reply []byte, sent, received time.Time) (map[string]interface{}, error)
+}
+
+var templates = map[string]template{
+	TemplateDNS:  {port: 53, build: buildDNS, match: matchDNS, decode: decodeDNS},
+	TemplateNTP:  {port: 123, build: buildNTP, match: matchNTP, decode: decodeNTP},
+	TemplateSNMP: {port: 161, build: buildSNMP, decode: decodeSNMP},
+	TemplateSTUN: {port: 3478, build: buildSTUN, match: matchSTUN, decode: decodeSTUN},
+	TemplateQUIC: {port: 443, build: buildQUIC, decode: decodeQUIC},
+}
+
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	rand.Read(b)
+	return b
+}
+
+func buildDNS(opts Options) []byte {
+	query := dnsprobe.NewQuery(dnsprobe.Fqdn(opts.DNSName), dnsprobe.TypeNS, dnsprobe.ClassIN, true)
+	packed, _ := query.Pack()
+	return packed
+}
+
+func matchDNS(payload, reply []byte) bool {
+	return len(reply) >= 12 && bytes.Equal(reply[:2], payload[:2])
+}
+
+func decodeDNS(payload, reply []byte, sent, received time.Time) (map[string]interface{}, error) {
+	msg, err := dnsprobe.Unpack(reply)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"rcode":      dnsprobe.RcodeString(msg.Rcode()),
+		"flags":      msg.Flags(),
+		"answer":     len(msg.Answer),
+		"authority":  len(msg.Authority),
+		"additional": len(msg.Additional),
+	}, nil
+}
+
+// ntpEpochOffset 1900-01-01 到 1970-01-01 的秒数
+const ntpEpochOffset = 2208988800
+
+func ntpTimestamp(t time.Time) uint64 {
+	secs := uint64(t.Unix() + ntpEpochOffset)
+	frac := uint64(t.Nanosecond()) << 32 / 1e9
+	return secs<<32 | frac
+}
+
+func ntpTime(ts uint64) time.Time {
+	secs := int64(ts>>32) - ntpEpochOffset
+	nanos := int64((ts & 0xffffffff) * 1e9 >> 32)
+	return time.Unix(secs, nanos)
+}
+
+func buildNTP(opts Options) []byte {
+	b := make([]byte, 48)
+	b[0] = 0<<6 | 4<<3 | 3 // LI=0，VN=4，Mode=3（客户端）
+	binary.BigEndian.PutUint64(b[40:], ntpTimestamp(time.Now()))
+	return b
+}
+
+// matchNTP 服务器应答的 Originate Timestamp 应等于请求的 Transmit Timestamp
+func matchNTP(payload, reply []byte) bool {
+	return len(reply) >= 48 && bytes.Equal(reply[24:32], payload[40:48])
+}
+
+func decodeNTP(payload, reply []byte, sent, received time.Time) (map[string]interface{}, error) {
+	stratum := reply[1]
+	refID := reply[12:16]
+	ref := net.IP(refID).String()
+	if stratum <= 1 {
+		// 0 为 Kiss-o'-Death 代码，1 为参考源标识，均为ASCII
+		ref = strings.TrimRight(string(refID), "\x00")
+	}
+	t2 := ntpTime(binary.BigEndian.Uint64(reply[32:]))
+	t3 := ntpTime(binary.BigEndian.Uint64(reply[40:]))
+	// 时钟偏差 ((t2-t1)+(t3-t4))/2，往返延迟 (t4-t1)-(t3-t2)
+	offset := (t2.Sub(sent) + t3.Sub(received)) / 2
+	delay := received.Sub(sent) - t3.Sub(t2)
+	return map[string]interface{}{
+		"version":     reply[0] >> 3 & 0x7,
+		"mode":        reply[0] & 0x7,
+		"leap":        reply[0] >> 6,
+		"stratum":     stratum,
+		"reference":   ref,
+		"server_time": t3.UTC().Format(time.RFC3339Nano),
+		"offset_ms":   float64(offset.Microseconds()) / 1000,
+		"delay_ms":    float64(delay.Microseconds()) / 1000,
+	}, nil
+}
+
+// sysDescrOID 1.3.6.1.2.1.1.1.0 的BER编码
+var sysDescrOID = []byte{0x2b, 0x06, 0x01, 0x02, 0x01, 0x01, 0x01, 0x00}
+
+// berTLV 编码一个BER的 tag-length-value
+func berTLV(tag byte, value []byte) []byte {
+	out := []byte{tag}
+	switch n := len(value); {
+	case n < 0x80:
+		out = append(out, byte(n))
+	case n < 0x100:
+		out = append(out, 0x81, byte(n))
+	default:
+		out = append(out, 0x82, byte(n>>8), byte(n))
+	}
+	return append(out, value...)
+}
+
+func buildSNMP(opts Options) []byte {
+	requestID := randomBytes(4)
+	requestID[0] &= 0x7f // 保持为正数
+	varbind := berTLV(0x30, append(berTLV(0x06, sysDescrOID), 0x05, 0x00))
+	pdu := bytes.Join([][]byte{
+		berTLV(0x02, requestID),
+		berTLV(0x02, []byte{0}), // error-status
+		berTLV(0x02, []byte{0}), // error-index
+		berTLV(0x30, varbind),
+	}, nil)
+	return berTLV(0x30, bytes.Join([][]byte{
+		berTLV(0x02, []byte{1}), // version: 1 为 v2c
+		berTLV(0x04, []byte(opts.Community)),
+		berTLV(0xa0, pdu), // GetRequest-PDU
+	}, nil))
+}
+
+// berReader 按顺序读取BER编码的元素
+type berReader struct {
+	data []byte
+}
+
+func (r *berReader) next() (byte, []byte, error) {
+	if len(r.data) < 2 {
+		return 0, nil, fmt.Errorf("BER数据过短")
+	}
+	tag, length, off := r.data[0], int(r.data[1]), 2
+	if length&0x80 != 0 {
+		n := length & 0x7f
+		if n == 0 || n > 2 || len(r.data) < 2+n {
+			return 0, nil, fmt.Errorf("BER长度无效")
+		}
+		length = 0
+		for _, b := range r.data[2 : 2+n] {
+			length = length<<8 | int(b)
+		}
+		off += n
+	}
+	if len(r.data) < off+length {
+		return 0, nil, fmt.Errorf("BER数据过短")
+	}
+	value := r.data[off : off+length]
+	r.data = r.data[off+length:]
+	return tag, value, nil
+}
+
+// berInt 解析BER整数
+func berInt(b []byte) int {
+	v := 0
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		v = -1
+	}
+	for _, c := range b {
+		v = v<<8 | int(c)
+	}
+	return v
+}
+
+// decodeSNMP 解析 Response-PDU 中的 error-status 和第一个变量的值
+func decodeSNMP(payload, reply []byte, sent, received time.Time) (map[string]interface{}, error) {
+	fail := fmt.Errorf("不是有效的SNMP应答")
+	msg := &berReader{data: reply}
+	tag, body, err := msg.next()
+	if err != nil || tag != 0x30 {
+		return nil, fail
+	}
+	fields := &berReader{data: body}
+	_, version, err := fields.next()
+	if err != nil {
+		return nil, fail
+	}
+	_, community, err := fields.next()
+	if err != nil {
+		return nil, fail
+	}
+	tag, pduBody, err := fields.next()
+	if err != nil || tag != 0xa2 {
+		return nil, fail
+	}
+	pdu := &berReader{data: pduBody}
+	var ints [3]int // request-id、error-status、error-index
+	for i := range ints {
+		_, v, err := pdu.next()
+		if err != nil {
+			return nil, fail
+		}
+		ints[i] = berInt(v)
+	}
+	result := map[string]interface{}{
+		"version":      berInt(version) + 1,
+		"community":    string(community),
+		"error_status": ints[1],
+	}
+	if _, varbinds, err := pdu.next(); err == nil {
+		if _, varbind, err := (&berReader{data: varbinds}).next(); err == nil {
+			vb := &berReader{data: varbind}
+			vb.next() // OID
+			if tag, value, err := vb.next(); err == nil && tag == 0x04 {
+				result["sys_descr"] = string(value)
+			}
+		}
+	}
+	return result, nil
+}
+
+const stunMagicCookie = 0x2112a442
+
+func buildSTUN(opts Options) []byte {
+	b := make([]byte, 20)
+	binary.BigEndian.PutUint16(b, 0x0001) // Binding Request，无属性
+	binary.BigEndian.PutUint32(b[4:], stunMagicCookie)
+	copy(b[8:], randomBytes(12))
+	return b
+}
+
+// matchSTUN 比较 magic cookie 和事务ID
+func matchSTUN(payload, reply []byte) bool {
+	return len(reply) >= 20 && bytes.Equal(reply[4:20], payload[4:20])
+}
+
+// decodeSTUN 解析 Binding 应答中映射的公网地址和服务器软件
+func decodeSTUN(payload, reply []byte, sent, received time.Time) (map[string]interface{}, error) {
+	result := map[string]interface{}{}
+	switch binary.BigEndian.Uint16(reply) {
+	case 0x0101:
+		result["class"] = "success"
+	case 0x0111:
+		result["class"] = "error"
+	default:
+		return nil, fmt.Errorf("不是STUN Binding应答")
+	}
+	attrs := reply[20:]
+	if length := int(binary.BigEndian.Uint16(reply[2:])); length < len(attrs) {
+		attrs = attrs[:length]
+	}
+	for len(attrs) >= 4 {
+		typ, length := binary.BigEndian.Uint16(attrs), int(binary.BigEndian.Uint16(attrs[2:]))
+		if len(attrs) < 4+length {
+			break
+		}
+		value := attrs[4 : 4+length]
+		switch typ {
+		case 0x0020: // XOR-MAPPED-ADDRESS
+			if addr := stunAddress(value, reply[4:20]); addr != "" {
+				result["mapped_address"] = addr
+			}
+		case 0x0001: // MAPPED-ADDRESS
+			if _, ok := result["mapped_address"]; !ok {
+				if addr := stunAddress(value, nil); addr != "" {
+					result["mapped_address"] = addr
+				}
+			}
+		case 0x8022: // SOFTWARE
+			result["software"] = string(value)
+		case 0x0009: // ERROR-CODE
+			if len(value) >= 4 {
+				result["error_code"] = int(value[2]&0x7)*100 + int(value[3])
+				result["error_reason"] = string(value[4:])
+			}
+		}
+		// 属性按4字节对齐
+		attrs = attrs[min(4+(length+3)/4*4, len(attrs)):]
+	}
+	return result, nil
+}
+
+// stunAddress 解析 (XOR-)MAPPED-ADDRESS，xor 为 magic cookie 加事务ID，为 nil 时不做异或
+func stunAddress(value, xor []byte) string {
+	if len(value) < 8 {
+		return ""
+	}
+	size := net.IPv4len
+	if value[1] == 0x02 {
+		size = net.IPv6len
+	}
+	if len(value) < 4+size {
+		return ""
+	}
+	port := binary.BigEndian.Uint16(value[2:])
+	ip := make(net.IP, size)
+	copy(ip, value[4:4+size])
+	if xor != nil {
+		port ^= stunMagicCookie >> 16
+		for i := range ip {
+			ip[i] ^= xor[i]
+		}
+	}
+	return net.JoinHostPort(ip.String(), fmt.Sprint(port))
+}
+
+// quicGreaseVersion 形如 0x?a?a?a?a 的保留版本，服务器不会支持，必须回复版本协商
+const quicGreaseVersion = 0x1a2a3a4a
+
+// quicMinInitialSize 客户端 Initial 数据报的最小长度（RFC 9000 第14.1节）
+const quicMinInitialSize = 1200
+
+func buildQUIC(opts Options) []byte {
+	b := []byte{0xc0} // 长包头，固定位，类型 Initial
+	b = binary.BigEndian.AppendUint32(b, quicGreaseVersion)
+	b = append(b, 8)
+	b = append(b, randomBytes(8)...) // Destination Connection ID
+	b = append(b, 8)
+	b = append(b, randomBytes(8)...) // Source Connection ID
+	b = append(b, 0)                 // Token Length
+	// Length 使用2字节变长整数，覆盖其后全部内容
+	rest := quicMinInitialSize - len(b) - 2
+	b = binary.BigEndian.AppendUint16(b, 0x4000|uint16(rest))
+	return append(b, randomBytes(rest)...)
+}
+
+var quicVersionNames = map[uint32]string{
+	0x00000001: "v1",
+	0x6b3343cf: "v2",
+	0xff00001d: "draft-29",
+}
+
+// decodeQUIC 解析版本协商包中服务器支持的版本
+func decodeQUIC(payload, reply []byte, sent, received time.Time) (map[string]interface{}, error) {
+	if len(reply) < 7 || reply[0]&0x80 == 0 || binary.BigEndian.Uint32(reply[1:]) != 0 {
+		return nil, fmt.Errorf("不是QUIC版本协商包")
+	}
+	off := 5
+	for i := 0; i < 2; i++ { // 跳过 DCID 和 SCID
+		if off >= len(reply) {
+			return nil, fmt.Errorf("QUIC版本协商包过短")
+		}
+		off += 1 + int(reply[off])
+	}
+	if off > len(reply) {
+		return nil, fmt.Errorf("QUIC版本协商包过短")
+	}
+	versions := []string{}
+	for ; off+4 <= len(reply); off += 4 {
+		v := binary.BigEndian.Uint32(reply[off:])
+		if v&0x0f0f0f0f == 0x0a0a0a0a {
+			continue // 服务器的GREASE版本
+		}
+		name := fmt.Sprintf("0x%08x", v)
+		if n, ok := quicVersionNames[v]; ok {
+			name = n
+		}
+		versions = append(versions, name)
+	}
+	return map[string]interface{}{"versions": versions}, nil
+}
@@ -0,0 +1,430 @@
+package udpprobe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func unhex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestBERReader(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		tag     byte
+		value   []byte
+		wantErr bool
+	}{
+		{"short form", []byte{0x04, 0x02, 'h', 'i', 0xff}, 0x04, []byte("hi"), false},
+		{"long form 1 byte", append([]byte{0x04, 0x81, 0x80}, make([]byte, 0x80)...), 0x04, make([]byte, 0x80), false},
+		{"long form 2 bytes", append([]byte{0x04, 0x82, 0x01, 0x00}, make([]byte, 0x100)...), 0x04, make([]byte, 0x100), false},
+		{"empty value", []byte{0x05, 0x00}, 0x05, []byte{}, false},
+		{"too short", []byte{0x04}, 0, nil, true},
+		{"indefinite length", []byte{0x30, 0x80, 0x00, 0x00}, 0, nil, true},
+		{"length of length too large", []byte{0x04, 0x83, 0x00, 0x00, 0x01, 0x00}, 0, nil, true},
+		{"truncated length", []byte{0x04, 0x82, 0x01}, 0, nil, true},
+		{"truncated value", []byte{0x04, 0x05, 'a', 'b'}, 0, nil, true},
+		{"long form truncated value", []byte{0x04, 0x81, 0x90, 0x00}, 0, nil, true},
+	}
+	for _, tt := range tests {
+		r := &berReader{data: tt.data}
+		tag, value, err := r.next()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && (tag != tt.tag || !bytes.Equal(value, tt.value)) {
+			t.Errorf("%s: next = %#x %v", tt.name, tag, value)
+		}
+	}
+
+	// 连续读取，读完后返回错误
+	r := &berReader{data: []byte{0x02, 0x01, 0x05, 0x04, 0x00}}
+	if tag, v, err := r.next(); err != nil || tag != 0x02 || berInt(v) != 5 {
+		t.Errorf("first = %#x %v %v", tag, v, err)
+	}
+	if tag, _, err := r.next(); err != nil || tag != 0x04 {
+		t.Errorf("second = %#x %v", tag, err)
+	}
+	if _, _, err := r.next(); err == nil {
+		t.Error("expected error at end of data")
+	}
+}
+
+func TestBERInt(t *testing.T) {
+	tests := []struct {
+		in   []byte
+		want int
+	}{
+		{nil, 0},
+		{[]byte{0x00}, 0},
+		{[]byte{0x7f}, 127},
+		{[]byte{0x00, 0x80}, 128},
+		{[]byte{0x80}, -128},
+		{[]byte{0xff}, -1},
+		{[]byte{0xff, 0x7f}, -129},
+		{[]byte{0x12, 0x34, 0x56, 0x78}, 0x12345678},
+	}
+	for _, tt := range tests {
+		if got := berInt(tt.in); got != tt.want {
+			t.Errorf("berInt(%x) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+// snmpResponse 构造 SNMPv2c Response-PDU，value 为 sysDescr.0 的值（含tag）
+func snmpResponse(community string, errorStatus byte, value []byte) []byte {
+	varbind := berTLV(0x30, append(berTLV(0x06, sysDescrOID), value...))
+	pdu := bytes.Join([][]byte{
+		berTLV(0x02, []byte{0x12, 0x34}),
+		berTLV(0x02, []byte{errorStatus}),
+		berTLV(0x02, []byte{0}),
+		berTLV(0x30, varbind),
+	}, nil)
+	return berTLV(0x30, bytes.Join([][]byte{
+		berTLV(0x02, []byte{1}),
+		berTLV(0x04, []byte(community)),
+		berTLV(0xa2, pdu),
+	}, nil))
+}
+
+func TestDecodeSNMP(t *testing.T) {
+	long := strings.Repeat("x", 300) // 需要两字节长度
+	tests := []struct {
+		name  string
+		reply []byte
+		want  map[string]interface{}
+	}{
+		{"sysDescr", snmpResponse("public", 0, berTLV(0x04, []byte("Linux router 6.1"))),
+			map[string]interface{}{"version": 2, "community": "public", "error_status": 0, "sys_descr": "Linux router 6.1"}},
+		{"long sysDescr", snmpResponse("public", 0, berTLV(0x04, []byte(long))),
+			map[string]interface{}{"version": 2, "community": "public", "error_status": 0, "sys_descr": long}},
+		// noSuchObject 异常值没有 sys_descr
+		{"no such object", snmpResponse("private", 0, []byte{0x80, 0x00}),
+			map[string]interface{}{"version": 2, "community": "private", "error_status": 0}},
+		{"error status", snmpResponse("public", 2, []byte{0x05, 0x00}),
+			map[string]interface{}{"version": 2, "community": "public", "error_status": 2}},
+	}
+	for _, tt := range tests {
+		got, err := decodeSNMP(nil, tt.reply, time.Time{}, time.Time{})
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: decodeSNMP = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// 请求报文本身（GetRequest-PDU）不是应答
+	if _, err := decodeSNMP(nil, buildSNMP(DefaultOptions()), time.Time{}, time.Time{}); err == nil {
+		t.Error("GetRequest should not decode as a response")
+	}
+	for _, bad := range [][]byte{nil, {0x04, 0x00}, {0x30, 0x03, 0x02, 0x01, 0x01}, []byte("not ber")} {
+		if _, err := decodeSNMP(nil, bad, time.Time{}, time.Time{}); err == nil {
+			t.Errorf("decodeSNMP(%x) expected error", bad)
+		}
+	}
+
+	// 任意截断都只返回错误
+	reply := snmpResponse("public", 0, berTLV(0x04, []byte("Linux")))
+	for i := range reply {
+		if _, err := decodeSNMP(nil, reply[:i], time.Time{}, time.Time{}); err == nil {
+			t.Errorf("truncated to %d bytes: expected error", i)
+		}
+	}
+}
+
+// RFC 5769 第2.2、2.3节测试向量中的事务ID和属性
+const (
+	stunTestTxID  = "b7e7a701bc34d686fa87dfae"
+	stunXorIPv4   = "0020 0008 0001 a147 e112a643"
+	stunXorIPv6   = "0020 0014 0002 a147 0113a9fa a5d3f179 bc25f4b5 bed2b9d9"
+	stunSoftware  = "8022 000b 74657374 20766563 746f7220" // "test vector"，补齐到4字节
+	stunIntegrity = "0008 0014 00000000 00000000 00000000 00000000 00000000"
+	stunFinger    = "8028 0004 c07d4c96"
+)
+
+func stunMessage(t *testing.T, typ uint16, attrs ...string) []byte {
+	t.Helper()
+	body := unhex(t, strings.Join(attrs, ""))
+	b := binary.BigEndian.AppendUint16(nil, typ)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(body)))
+	b = binary.BigEndian.AppendUint32(b, stunMagicCookie)
+	b = append(b, unhex(t, stunTestTxID)...)
+	return append(b, body...)
+}
+
+func TestDecodeSTUN(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply []byte
+		want  map[string]interface{}
+	}{
+		{"ipv4", stunMessage(t, 0x0101, stunSoftware, stunXorIPv4, stunIntegrity, stunFinger),
+			map[string]interface{}{"class": "success", "software": "test vector", "mapped_address": "192.0.2.1:32853"}},
+		{"ipv6", stunMessage(t, 0x0101, stunSoftware, stunXorIPv6, stunIntegrity, stunFinger),
+			map[string]interface{}{"class": "success", "software": "test vector", "mapped_address": "[2001:db8:1234:5678:11:2233:4455:6677]:32853"}},
+		// 不异或的 MAPPED-ADDRESS，XOR-MAPPED-ADDRESS 优先
+		{"mapped address", stunMessage(t, 0x0101, "0001 0008 0001 8055 c0000201"),
+			map[string]interface{}{"class": "success", "mapped_address": "192.0.2.1:32853"}},
+		{"xor preferred", stunMessage(t, 0x0101, "0001 0008 0001 0050 c6336401", stunXorIPv4),
+			map[string]interface{}{"class": "success", "mapped_address": "192.0.2.1:32853"}},
+		{"error response", stunMessage(t, 0x0111, "0009 000b 00000414 556e6b6e6f776e 00"),
+			map[string]interface{}{"class": "error", "error_code": 420, "error_reason": "Unknown"}},
+		// 属性长度超出报文时停止解析
+		{"attribute overrun", stunMessage(t, 0x0101, stunXorIPv4, "8022 0040 7465"),
+			map[string]interface{}{"class": "success", "mapped_address": "192.0.2.1:32853"}},
+		// 地址值过短时忽略
+		{"short address", stunMessage(t, 0x0101, "0020 0004 0001 a147"),
+			map[string]interface{}{"class": "success"}},
+		{"ipv6 family with ipv4 length", stunMessage(t, 0x0101, "0020 0008 0002 a147 e112a643"),
+			map[string]interface{}{"class": "success"}},
+	}
+	for _, tt := range tests {
+		got, err := decodeSTUN(nil, tt.reply, time.Time{}, time.Time{})
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: decodeSTUN = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// 报文头中的长度小于实际内容时只解析声明的部分
+	reply := stunMessage(t, 0x0101, stunXorIPv4, stunSoftware)
+	binary.BigEndian.PutUint16(reply[2:], 12)
+	if got, _ := decodeSTUN(nil, reply, time.Time{}, time.Time{}); got["software"] != nil || got["mapped_address"] != "192.0.2.1:32853" {
+		t.Errorf("declared length: %v", got)
+	}
+
+	if _, err := decodeSTUN(nil, stunMessage(t, 0x0001), time.Time{}, time.Time{}); err == nil {
+		t.Error("binding request should not decode as a response")
+	}
+
+	// 匹配后的任意截断不会出错
+	full := stunMessage(t, 0x0101, stunSoftware, stunXorIPv6, stunFinger)
+	for i := 20; i < len(full); i++ {
+		if _, err := decodeSTUN(nil, full[:i], time.Time{}, time.Time{}); err != nil {
+			t.Errorf("truncated to %d bytes: %v", i, err)
+		}
+	}
+}
+
+func TestMatchSTUN(t *testing.T) {
+	request := buildSTUN(DefaultOptions())
+	if len(request) != 20 || binary.BigEndian.Uint16(request) != 0x0001 || binary.BigEndian.Uint32(request[4:]) != stunMagicCookie {
+		t.Fatalf("request = %x", request)
+	}
+	reply := append([]byte{0x01, 0x01, 0, 0}, request[4:]...)
+	if !matchSTUN(request, reply) {
+		t.Error("reply with same transaction ID should match")
+	}
+	other := append([]byte(nil), reply...)
+	other[19] ^= 1
+	if matchSTUN(request, other) {
+		t.Error("different transaction ID should not match")
+	}
+	if matchSTUN(request, reply[:19]) {
+		t.Error("short reply should not match")
+	}
+}
+
+// quicVersionNegotiation 构造版本协商包
+func quicVersionNegotiation(versions ...uint32) []byte {
+	b := []byte{0x80 | 0x2a}
+	b = binary.BigEndian.AppendUint32(b, 0)
+	b = append(b, 8, 1, 2, 3, 4, 5, 6, 7, 8) // DCID
+	b = append(b, 4, 9, 9, 9, 9)             // SCID
+	for _, v := range versions {
+		b = binary.BigEndian.AppendUint32(b, v)
+	}
+	return b
+}
+
+func TestDecodeQUIC(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply []byte
+		want  []string
+	}{
+		{"known versions", quicVersionNegotiation(0x00000001, 0x6b3343cf, 0xff00001d), []string{"v1", "v2", "draft-29"}},
+		// 跳过服务器的GREASE版本，未知版本显示为十六进制
+		{"grease and unknown", quicVersionNegotiation(0x5a6a7a8a, 0x00000001, 0xfaceb002), []string{"v1", "0xfaceb002"}},
+		{"no versions", quicVersionNegotiation(), []string{}},
+		// 末尾不足4字节的部分忽略
+		{"trailing bytes", append(quicVersionNegotiation(0x00000001), 0xff, 0xff), []string{"v1"}},
+	}
+	for _, tt := range tests {
+		got, err := decodeQUIC(nil, tt.reply, time.Time{}, time.Time{})
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got["versions"], tt.want) {
+			t.Errorf("%s: versions = %v, want %v", tt.name, got["versions"], tt.want)
+		}
+	}
+
+	bad := map[string][]byte{
+		"empty":            nil,
+		"short header":     append([]byte{0x40}, make([]byte, 10)...),
+		"nonzero version":  append([]byte{0xc0, 0, 0, 0, 1}, make([]byte, 10)...),
+		"dcid overrun":     {0x80, 0, 0, 0, 0, 20, 1},
+		"scid overrun":     {0x80, 0, 0, 0, 0, 1, 1, 9, 1},
+		"missing scid len": {0x80, 0, 0, 0, 0, 2, 1, 2},
+	}
+	for name, reply := range bad {
+		if _, err := decodeQUIC(nil, reply, time.Time{}, time.Time{}); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+
+	// 任意截断不会越界
+	full := quicVersionNegotiation(0x00000001, 0x6b3343cf)
+	for i := range full {
+		decodeQUIC(nil, full[:i], time.Time{}, time.Time{})
+	}
+
+	// 构造的 Initial 达到最小长度，Length 字段覆盖其后的全部内容
+	initial := buildQUIC(DefaultOptions())
+	if len(initial) != quicMinInitialSize || binary.BigEndian.Uint32(initial[1:]) != quicGreaseVersion {
+		t.Errorf("initial = %d bytes, version %x", len(initial), initial[1:5])
+	}
+	if length := int(binary.BigEndian.Uint16(initial[24:]) & 0x3fff); length != len(initial)-26 {
+		t.Errorf("initial length field = %d, want %d", length, len(initial)-26)
+	}
+}
+
+func TestNTPTime(t *testing.T) {
+	tests := []struct {
+		ts   uint64
+		want time.Time
+	}{
+		{ntpEpochOffset << 32, time.Unix(0, 0)},
+		{(ntpEpochOffset+1)<<32 | 0x80000000, time.Unix(1, 500000000)},
+		{0xe9a3a8c0 << 32, time.Date(2024, 3, 19, 6, 1, 36, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got := ntpTime(tt.ts); !got.Equal(tt.want) {
+			t.Errorf("ntpTime(%#x) = %v, want %v", tt.ts, got, tt.want)
+		}
+	}
+
+	// 往返转换的误差小于1纳秒精度
+	for _, tm := range []time.Time{time.Unix(1700000000, 123456789), time.Unix(0, 1), time.Date(2036, 1, 1, 0, 0, 0, 999999999, time.UTC)} {
+		if d := tm.Sub(ntpTime(ntpTimestamp(tm))); d < 0 || d > time.Nanosecond {
+			t.Errorf("round trip %v differs by %v", tm, d)
+		}
+	}
+}
+
+// ntpReply 构造服务器应答：originate 为请求的发送时间戳
+func ntpReply(payload []byte, stratum byte, refID string, receive, transmit time.Time) []byte {
+	b := make([]byte, 48)
+	b[0] = 0<<6 | 4<<3 | 4 // 服务器
+	b[1] = stratum
+	copy(b[12:16], refID)
+	copy(b[24:32], payload[40:48])
+	binary.BigEndian.PutUint64(b[32:], ntpTimestamp(receive))
+	binary.BigEndian.PutUint64(b[40:], ntpTimestamp(transmit))
+	return b
+}
+
+func TestNTP(t *testing.T) {
+	payload := buildNTP(DefaultOptions())
+	if len(payload) != 48 || payload[0] != 0x23 {
+		t.Fatalf("request = %x", payload)
+	}
+
+	// 服务器时钟快2秒，往返各250ms，服务器处理125ms
+	sent := time.Unix(1700000000, 0)
+	received := sent.Add(625 * time.Millisecond)
+	receive := sent.Add(2*time.Second + 250*time.Millisecond)
+	transmit := receive.Add(125 * time.Millisecond)
+
+	reply := ntpReply(payload, 1, "GPS", receive, transmit)
+	if !matchNTP(payload, reply) {
+		t.Fatal("reply should match request")
+	}
+	got, err := decodeNTP(payload, reply, sent, received)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"version":     byte(4),
+		"mode":        byte(4),
+		"leap":        byte(0),
+		"stratum":     byte(1),
+		"reference":   "GPS",
+		"server_time": "2023-11-14T22:13:22.375Z",
+		"offset_ms":   2000.0,
+		"delay_ms":    500.0,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("decodeNTP = %v, want %v", got, want)
+	}
+
+	// stratum ≥ 2 时参考标识为上游服务器的IPv4地址，0 为 Kiss-o'-Death 代码
+	tests := []struct {
+		stratum byte
+		refID   string
+		want    string
+	}{
+		{2, "\xc0\x00\x02\x01", "192.0.2.1"},
+		{0, "RATE", "RATE"},
+	}
+	for _, tt := range tests {
+		got, _ := decodeNTP(payload, ntpReply(payload, tt.stratum, tt.refID, receive, transmit), sent, received)
+		if got["reference"] != tt.want {
+			t.Errorf("stratum %d reference = %v, want %s", tt.stratum, got["reference"], tt.want)
+		}
+	}
+
+	// originate 与请求不一致或应答过短时不匹配
+	other := append([]byte(nil), reply...)
+	other[31] ^= 1
+	if matchNTP(payload, other) {
+		t.Error("mismatched originate timestamp should not match")
+	}
+	if matchNTP(payload, reply[:47]) {
+		t.Error("short reply should not match")
+	}
+}
+
+func TestDNSTemplate(t *testing.T) {
+	opts := DefaultOptions()
+	opts.DNSName = "example.com"
+	payload := buildDNS(opts)
+	if len(payload) < 12 {
+		t.Fatalf("payload = %x", payload)
+	}
+	reply := append([]byte(nil), payload...)
+	reply[2] |= 0x80 // QR
+	if !matchDNS(payload, reply) {
+		t.Error("reply with same ID should match")
+	}
+	reply[0] ^= 1
+	if matchDNS(payload, reply) {
+		t.Error("different ID should not match")
+	}
+	if matchDNS(payload, payload[:11]) {
+		t.Error("short reply should not match")
+	}
+	if _, err := decodeDNS(payload, payload[:5], time.Time{}, time.Time{}); err == nil {
+		t.Error("truncated DNS reply should fail")
+	}
+}
@@ -20,6 +20,8 @@ cd "$SCRIPT_DIR"
 
 # 配置
 BINARY_NAME="agent"
+GO_MIN_VERSION="1.23"  # 编译所需的最低 Go 版本（与 go.mod 一致）
+GO_INSTALL_DIR="${SCRIPT_DIR}/.go"  # install.sh 下载的 Go 工具链目录
 LOG_FILE="node.log"
 PID_FILE="node.pid"
 BACKEND_URL="${BACKEND_URL:-http://localhost:8080}"
@@ -39,6 +41,13 @@ get_pid() {
     fi
 }
 
+# 检查 go 命令的版本是否满足 GO_MIN_VERSION
+go_version_ok() {
+    local version
+    version=$(go env GOVERSION 2>/dev/null | sed 's/^go//')
+    [ -n "$version" ] && [ "$(printf '%s\n%s\n' "$GO_MIN_VERSION" "$version" | sort -V | head -1)" = "$GO_MIN_VERSION" ]
+}
+
 # 拉取最新源码并编译
 update_and_build() {
     echo -e "${BLUE}拉取最新源码...${NC}"
@@ -62,11 +71,18 @@ update_and_build() {
         echo -e "${YELLOW}可能原因: 网络问题、权限问题或本地有未提交的更改${NC}"
     fi
     
-    # 检查 Go 环境
+    # 检查 Go 环境，优先使用 install.sh 安装在源码目录下的工具链
+    if [ -x "${GO_INSTALL_DIR}/bin/go" ]; then
+        export PATH="${GO_INSTALL_DIR}/bin:$PATH"
+    fi
     if ! command -v go > /dev/null 2>&1; then
         echo -e "${RED}错误: 未找到 Go 环境，无法编译${NC}"
         exit 1
     fi
+    if ! go_version_ok; then
+        echo -e "${RED}错误: Go 版本低于 ${GO_MIN_VERSION}，无法编译，请重新运行 install.sh 安装${NC}"
+        exit 1
+    fi
     
     # 更新依赖
     echo -e "${BLUE}更新 Go 依赖...${NC}"